@@ -2,6 +2,7 @@ package benchmarker
 
 import (
 	"errors"
+	"io"
 	"strings"
 	"sync"
 	"time"
@@ -9,19 +10,45 @@ import (
 	. "github.com/cloudfoundry-community/pat/workloads"
 )
 
+// TraceId, SpanId and ParentId (the span of the whole iteration) are only
+// set when TraceIterations is true. When a step is retried, Duration runs
+// from the start of the first attempt to the end of the last, including the
+// backoff between them, and FirstAttempt is how long the first one took.
 type StepResult struct {
-	Command  string
-	Duration time.Duration
+	Command      string
+	Duration     time.Duration
+	Requests     []RequestTrace
+	Start        time.Time
+	TraceId      string
+	SpanId       string
+	ParentId     string
+	Attempts     int
+	FirstAttempt time.Duration
 }
 
+// Whether each iteration is given a trace id, which is propagated to the
+// servers its steps make requests to. Set when traces are exported.
+var TraceIterations = false
+
 type IterationResult struct {
-	Duration time.Duration
-	Steps    []StepResult
-	Error    error
+	Duration  time.Duration
+	Steps     []StepResult
+	Error     error
+	Scheduled *Scheduled
+}
+
+// When, on which worker and with which workload an iteration started, so an
+// experiment can be replayed on the same timeline. Offset is from the start
+// of the experiment, and is filled in by its sampler from Start.
+type Scheduled struct {
+	Start    time.Time `json:"-"`
+	Offset   time.Duration
+	Worker   int
+	Workload string
 }
 
 type Worker interface {
-	Time(experiment string) IterationResult
+	Time(experiment string, thinkTime Delay) IterationResult
 	AddWorkloadStep(workload WorkloadStep)
 	Visit(fn func(WorkloadStep))
 	Validate(name string) (result bool, err error)
@@ -29,32 +56,126 @@ type Worker interface {
 
 type LocalWorker struct {
 	Experiments map[string]WorkloadStep
+	slots       []bool
+	lock        sync.Mutex
 }
 
 func NewWorker() *LocalWorker {
-	return &LocalWorker{make(map[string]WorkloadStep)}
+	return &LocalWorker{Experiments: make(map[string]WorkloadStep)}
 }
 
 func (self *LocalWorker) AddWorkloadStep(workload WorkloadStep) {
 	self.Experiments[workload.Name] = workload
 }
 
-func (self *LocalWorker) Time(experiment string) (result IterationResult) {
+// Runs the steps of the experiment one after another, waiting for the think
+// time (if any) between them. The think time is not included in the timings
+// of the steps or of the iteration. Each attempt at a retried step starts
+// from the context as it was before the step, so that nothing a failed
+// attempt left behind (such as the guid of an app it half pushed) is used by
+// the next. Context values which are io.Closers, such as an iteration's own
+// HTTP client, are closed when the iteration ends.
+func (self *LocalWorker) Time(experiment string, thinkTime Delay) (result IterationResult) {
 	experiments := strings.Split(experiment, ",")
 	var start = time.Now()
-	context := make(map[string]interface{})
-	for _, e := range experiments {
-		stepTime, err := Time(func() error { return self.Experiments[e].Fn(context) })
-		result.Steps = append(result.Steps, StepResult{e, stepTime})
+	slot := self.claimSlot()
+	defer self.releaseSlot(slot)
+	context := map[string]interface{}{"worker": slot}
+	defer closeContext(context)
+	result.Scheduled = &Scheduled{Start: start, Worker: slot, Workload: experiment}
+	var traceId, iterationSpan string
+	if TraceIterations {
+		traceId, iterationSpan = NewTraceId(), NewSpanId()
+	}
+	var thought time.Duration
+	for i, e := range experiments {
+		if i > 0 {
+			thought += thinkTime.Wait()
+		}
+
+		requests := &RequestLog{}
+		if traceId != "" {
+			requests.TraceId, requests.SpanId = traceId, NewSpanId()
+		}
+		context["requests"] = requests
+		step := self.Experiments[e]
+		var attempts int
+		var first time.Duration
+		stepStart := time.Now()
+		before := copyContext(context)
+		stepTime, err := Time(func() (err error) {
+			attempts, first, err = step.Retry.Run(func() error {
+				restoreContext(context, before)
+				return step.Fn(context)
+			})
+			return
+		})
+		result.Steps = append(result.Steps, StepResult{e, stepTime, requests.Requests(), stepStart, traceId, requests.SpanId, iterationSpan, attempts, first})
 		if err != nil {
 			result.Error = err
 			break
 		}
 	}
-	result.Duration = time.Now().Sub(start)
+	result.Duration = time.Now().Sub(start) - thought
 	return
 }
 
+func copyContext(context map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(context))
+	for key, value := range context {
+		copied[key] = value
+	}
+
+	return copied
+}
+
+// Puts back the values in before, and removes (closing them if need be) the
+// ones added since.
+func restoreContext(context map[string]interface{}, before map[string]interface{}) {
+	for key, value := range context {
+		if _, ok := before[key]; !ok {
+			if closer, ok := value.(io.Closer); ok {
+				closer.Close()
+			}
+			delete(context, key)
+		}
+	}
+
+	for key, value := range before {
+		context[key] = value
+	}
+}
+
+func closeContext(context map[string]interface{}) {
+	for _, value := range context {
+		if closer, ok := value.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
+// Each iteration that is running at once is given a distinct worker number
+// (the lowest one free) in its context, so steps can share state, such as
+// which user to log in as, between iterations on the same worker.
+func (self *LocalWorker) claimSlot() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for i, used := range self.slots {
+		if !used {
+			self.slots[i] = true
+			return i
+		}
+	}
+	self.slots = append(self.slots, true)
+	return len(self.slots) - 1
+}
+
+func (self *LocalWorker) releaseSlot(slot int) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.slots[slot] = false
+}
+
 func (self *LocalWorker) Visit(fn func(WorkloadStep)) {
 	for _, e := range self.Experiments {
 		fn(e)
@@ -95,13 +216,38 @@ func Counted(out chan<- int, fn func()) func() {
 	}
 }
 
-func TimedWithWorker(out chan<- IterationResult, worker Worker, experiment string) func() {
+func TimedWithWorker(out chan<- IterationResult, worker Worker, experiment string, thinkTime Delay) func() {
 	return func() {
-		time := worker.Time(experiment)
+		time := worker.Time(experiment, thinkTime)
 		out <- time
 	}
 }
 
+// Runs a task for each scheduled iteration, no earlier than its offset from
+// when ExecuteSchedule was called. The iterations of each worker run one
+// after another, in the order given, so a worker which falls behind the
+// schedule starts its next iteration late rather than running two at once.
+func ExecuteSchedule(schedule []Scheduled, task func(Scheduled)) {
+	start := time.Now()
+	byWorker := make(map[int][]Scheduled)
+	for _, s := range schedule {
+		byWorker[s.Worker] = append(byWorker[s.Worker], s)
+	}
+
+	var wg sync.WaitGroup
+	for _, iterations := range byWorker {
+		wg.Add(1)
+		go func(iterations []Scheduled) {
+			defer wg.Done()
+			for _, s := range iterations {
+				time.Sleep(s.Offset - time.Now().Sub(start))
+				task(s)
+			}
+		}(iterations)
+	}
+	wg.Wait()
+}
+
 func Once(fn func()) <-chan func() {
 	return Repeat(1, fn)
 }
@@ -154,12 +300,23 @@ func Execute(tasks <-chan func()) {
 }
 
 func ExecuteConcurrently(workers int, tasks <-chan func()) {
+	ExecuteConcurrentlyWithPacing(workers, nil, tasks)
+}
+
+// Like ExecuteConcurrently, but each worker waits for the pacing delay
+// between finishing one task and taking the next.
+func ExecuteConcurrentlyWithPacing(workers int, pacing Delay, tasks <-chan func()) {
 	var wg sync.WaitGroup
 	for i := 0; i < workers; i++ {
 		wg.Add(1)
 		go func(t <-chan func()) {
 			defer wg.Done()
+			first := true
 			for task := range t {
+				if !first {
+					pacing.Wait()
+				}
+				first = false
 				task()
 			}
 		}(tasks)
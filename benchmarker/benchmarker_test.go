@@ -2,6 +2,7 @@ package benchmarker
 
 import (
 	"errors"
+	"sync"
 	"time"
 	. "github.com/cloudfoundry-community/pat/workloads"
 	. "github.com/onsi/ginkgo"
@@ -27,7 +28,7 @@ var _ = Describe("Benchmarker", func() {
 				}
 			}(result)
 
-			TimedWithWorker(ch, &DummyWorker{}, "three")()
+			TimedWithWorker(ch, &DummyWorker{}, "three", nil)()
 			Ω((<-result).Seconds()).Should(BeNumerically("==", 3))
 		})
 	})
@@ -60,21 +61,21 @@ var _ = Describe("Benchmarker", func() {
 			It("Times a function by name", func() {
 				worker := NewWorker()
 				worker.AddWorkloadStep(Step("foo", func() error { time.Sleep(1 * time.Second); return nil }, ""))
-				result := worker.Time("foo")
+				result := worker.Time("foo", nil)
 				Ω(result.Duration.Seconds()).Should(BeNumerically("~", 1, 0.1))
 			})
 
 			It("Sets the function command name in the response struct", func() {
 				worker := NewWorker()
 				worker.AddWorkloadStep(Step("foo", func() error { time.Sleep(1 * time.Second); return nil }, ""))
-				result := worker.Time("foo")
+				result := worker.Time("foo", nil)
 				Ω(result.Steps[0].Command).Should(Equal("foo"))
 			})
 
 			It("Returns any errors", func() {
 				worker := NewWorker()
 				worker.AddWorkloadStep(Step("foo", func() error { return errors.New("Foo") }, ""))
-				result := worker.Time("foo")
+				result := worker.Time("foo", nil)
 				Ω(result.Error).Should(HaveOccurred())
 			})
 
@@ -100,9 +101,82 @@ var _ = Describe("Benchmarker", func() {
 				worker := NewWorker()
 				worker.AddWorkloadStep(StepWithContext("foo", func(ctx map[string]interface{}) error { context = ctx; ctx["a"] = 1; return nil }, ""))
 				worker.AddWorkloadStep(StepWithContext("bar", func(ctx map[string]interface{}) error { ctx["a"] = ctx["a"].(int) + 2; return nil }, ""))
-				worker.Time("foo")
+				worker.Time("foo", nil)
 				Ω(context).Should(HaveKey("a"))
 			})
+
+			It("Records the requests each step makes", func() {
+				worker := NewWorker()
+				worker.AddWorkloadStep(StepWithContext("foo", func(ctx map[string]interface{}) error {
+					ctx["requests"].(*RequestLog).Add(RequestTrace{Method: "GET", Url: "http://example.com", Status: 200})
+					return nil
+				}, ""))
+				result := worker.Time("foo", nil)
+				Ω(result.Steps[0].Requests).Should(HaveLen(1))
+				Ω(result.Steps[0].Requests[0].Url).Should(Equal("http://example.com"))
+			})
+
+			Context("When iterations are traced", func() {
+				BeforeEach(func() {
+					TraceIterations = true
+				})
+
+				AfterEach(func() {
+					TraceIterations = false
+				})
+
+				It("Gives the iteration a trace id, and each step a span in it", func() {
+					worker := NewWorker()
+					var logs []*RequestLog
+					step := func(ctx map[string]interface{}) error {
+						logs = append(logs, ctx["requests"].(*RequestLog))
+						return nil
+					}
+					worker.AddWorkloadStep(StepWithContext("foo", step, ""))
+					worker.AddWorkloadStep(StepWithContext("bar", step, ""))
+					result := worker.Time("foo,bar", nil)
+
+					Ω(result.Steps[0].TraceId).Should(HaveLen(32))
+					Ω(result.Steps[1].TraceId).Should(Equal(result.Steps[0].TraceId))
+					Ω(result.Steps[0].ParentId).Should(HaveLen(16))
+					Ω(result.Steps[1].ParentId).Should(Equal(result.Steps[0].ParentId))
+					Ω(result.Steps[0].SpanId).ShouldNot(Equal(result.Steps[1].SpanId))
+					Ω(logs[1].TraceId).Should(Equal(result.Steps[1].TraceId))
+					Ω(logs[1].SpanId).Should(Equal(result.Steps[1].SpanId))
+				})
+			})
+
+			It("Gives iterations running at the same time different worker numbers", func() {
+				started := make(chan int)
+				release := make(chan bool)
+				worker := NewWorker()
+				worker.AddWorkloadStep(StepWithContext("foo", func(ctx map[string]interface{}) error {
+					started <- ctx["worker"].(int)
+					<-release
+					return nil
+				}, ""))
+
+				go worker.Time("foo", nil)
+				go worker.Time("foo", nil)
+				workers := []int{<-started, <-started}
+				Ω(workers).Should(ConsistOf(0, 1))
+				close(release)
+
+				Eventually(func() int {
+					go worker.Time("foo", nil)
+					return <-started
+				}).Should(Equal(0))
+			})
+
+			It("Records when, on which worker and with which workload the iteration started", func() {
+				worker := NewWorker()
+				worker.AddWorkloadStep(Step("foo", func() error { return nil }, ""))
+				before := time.Now()
+				result := worker.Time("foo", nil)
+				Ω(result.Scheduled.Start).Should(BeTemporally("~", before, time.Second))
+				Ω(result.Scheduled.Worker).Should(Equal(0))
+				Ω(result.Scheduled.Workload).Should(Equal("foo"))
+			})
 		})
 
 		Describe("When multiple steps are provided separated by commas", func() {
@@ -113,7 +187,7 @@ var _ = Describe("Benchmarker", func() {
 				worker = NewWorker()
 				worker.AddWorkloadStep(Step("foo", func() error { time.Sleep(1 * time.Second); return nil }, ""))
 				worker.AddWorkloadStep(Step("bar", func() error { time.Sleep(1 * time.Second); return nil }, ""))
-				result = worker.Time("foo,bar")
+				result = worker.Time("foo,bar", nil)
 			})
 
 			It("Reports the total time", func() {
@@ -154,7 +228,7 @@ var _ = Describe("Benchmarker", func() {
 				worker.AddWorkloadStep(Step("foo", func() error { time.Sleep(1 * time.Second); return nil }, ""))
 				worker.AddWorkloadStep(Step("bar", func() error { time.Sleep(1 * time.Second); return nil }, ""))
 				worker.AddWorkloadStep(Step("errors", func() error { return errors.New("fishfinger system overflow") }, ""))
-				result = worker.Time("foo,errors,bar")
+				result = worker.Time("foo,errors,bar", nil)
 			})
 
 			It("Records the error", func() {
@@ -173,6 +247,84 @@ var _ = Describe("Benchmarker", func() {
 		})
 	})
 
+	Describe("When a step has a retry policy", func() {
+		var (
+			worker   *LocalWorker
+			failures int
+			failWith error
+			calls    int
+			seen     []interface{}
+			closed   int
+		)
+
+		BeforeEach(func() {
+			calls, seen, closed = 0, nil, 0
+			worker = NewWorker()
+			step := StepWithContext("flaky", func(ctx map[string]interface{}) error {
+				calls++
+				seen = append(seen, ctx["app_guid"])
+				ctx["app_guid"] = calls
+				ctx["stream"] = &dummyCloser{&closed}
+				if calls <= failures {
+					time.Sleep(50 * time.Millisecond)
+					return failWith
+				}
+				return nil
+			}, "")
+			step.Retry = RetryPolicy{Attempts: 3, Backoff: "10ms", RetryOn: []string{"5xx"}}
+			worker.AddWorkloadStep(step)
+		})
+
+		Context("And it recovers", func() {
+			BeforeEach(func() {
+				failures, failWith = 2, &ReplyError{Code: 502, Message: "502 Bad Gateway"}
+			})
+
+			It("Records the attempts and the latency of the first one", func() {
+				result := worker.Time("flaky", nil)
+				Ω(result.Error).ShouldNot(HaveOccurred())
+				Ω(result.Steps[0].Attempts).Should(Equal(3))
+				Ω(result.Steps[0].FirstAttempt.Seconds()).Should(BeNumerically("~", 0.05, 0.02))
+				Ω(result.Steps[0].Duration.Seconds()).Should(BeNumerically(">", 0.12))
+			})
+
+			It("Starts each attempt from the context as it was before the step", func() {
+				worker.Time("flaky", nil)
+				Ω(seen).Should(Equal([]interface{}{nil, nil, nil}))
+			})
+
+			It("Closes what each failed attempt left in the context, and the rest when the iteration ends", func() {
+				worker.Time("flaky", nil)
+				Ω(closed).Should(Equal(3))
+			})
+		})
+
+		Context("And it fails with an error the policy does not retry", func() {
+			BeforeEach(func() {
+				failures, failWith = 2, &ReplyError{Code: 404, Message: "404 Not Found"}
+			})
+
+			It("Fails after the first attempt", func() {
+				result := worker.Time("flaky", nil)
+				Ω(result.Error).Should(HaveOccurred())
+				Ω(result.Steps[0].Attempts).Should(Equal(1))
+			})
+		})
+
+		Context("And it keeps failing", func() {
+			BeforeEach(func() {
+				failures, failWith = 5, &ReplyError{Code: 503, Message: "503 Service Unavailable"}
+			})
+
+			It("Fails after the last attempt", func() {
+				result := worker.Time("flaky", nil)
+				Ω(result.Error).Should(HaveOccurred())
+				Ω(result.Steps[0].Attempts).Should(Equal(3))
+				Ω(calls).Should(Equal(3))
+			})
+		})
+	})
+
 	Describe("Counted", func() {
 		It("Sends +1 when the function is called, and -1 when it ends", func() {
 			ch := make(chan int)
@@ -243,6 +395,61 @@ var _ = Describe("Benchmarker", func() {
 		})
 	})
 
+	Describe("Think time", func() {
+		It("Waits between steps, without counting the wait in the step or iteration timings", func() {
+			worker := NewWorker()
+			worker.AddWorkloadStep(Step("foo", func() error { return nil }, ""))
+			worker.AddWorkloadStep(Step("bar", func() error { return nil }, ""))
+			thinkTime, _ := ParseDelay("constant:500ms")
+
+			var result IterationResult
+			wall, _ := Time(func() error {
+				result = worker.Time("foo,bar,foo", thinkTime)
+				return nil
+			})
+			Ω(wall.Seconds()).Should(BeNumerically("~", 1, 0.2))
+			Ω(result.Duration.Seconds()).Should(BeNumerically("<", 0.2))
+			for _, step := range result.Steps {
+				Ω(step.Duration.Seconds()).Should(BeNumerically("<", 0.1))
+			}
+		})
+	})
+
+	Describe("Pacing", func() {
+		It("Waits between the tasks each worker runs, but not before the first", func() {
+			pacing, _ := ParseDelay("1s")
+			result, _ := Time(func() error {
+				ExecuteConcurrentlyWithPacing(2, pacing, Repeat(4, func() {}))
+				return nil
+			})
+			Ω(result.Seconds()).Should(BeNumerically("~", 1, 0.5))
+		})
+	})
+
+	Describe("ExecuteSchedule", func() {
+		It("Runs each iteration at its offset, and each worker's iterations one after another", func() {
+			var lock sync.Mutex
+			started := make(map[string]time.Duration)
+			start := time.Now()
+			ExecuteSchedule([]Scheduled{
+				{Offset: 0, Worker: 0, Workload: "a"},
+				{Offset: 500 * time.Millisecond, Worker: 1, Workload: "b"},
+				{Offset: 100 * time.Millisecond, Worker: 0, Workload: "c"},
+			}, func(s Scheduled) {
+				lock.Lock()
+				started[s.Workload] = time.Now().Sub(start)
+				lock.Unlock()
+				if s.Workload == "a" {
+					time.Sleep(time.Second)
+				}
+			})
+
+			Ω(started["a"].Seconds()).Should(BeNumerically("~", 0, 0.1))
+			Ω(started["b"].Seconds()).Should(BeNumerically("~", 0.5, 0.1))
+			Ω(started["c"].Seconds()).Should(BeNumerically("~", 1, 0.1))
+		})
+	})
+
 	Describe("Repeat Concurrently", func() {
 		Context("with 1 worker", func() {
 			It("Runs in series", func() {
@@ -268,7 +475,7 @@ var _ = Describe("Benchmarker", func() {
 
 type DummyWorker struct{}
 
-func (*DummyWorker) Time(experiment string) IterationResult {
+func (*DummyWorker) Time(experiment string, thinkTime Delay) IterationResult {
 	var result IterationResult
 	if experiment == "three" {
 		result.Duration = 3 * time.Second
@@ -288,3 +495,12 @@ func (d *DummyWorker) Visit(fn func(WorkloadStep)) {
 func (d *DummyWorker) Validate(name string) (result bool, err error) {
 	return
 }
+
+type dummyCloser struct {
+	closed *int
+}
+
+func (c *dummyCloser) Close() error {
+	*c.closed++
+	return nil
+}
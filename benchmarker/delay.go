@@ -0,0 +1,130 @@
+package benchmarker
+
+import (
+	"bufio"
+	"errors"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long to wait, as think time between the steps of an iteration or
+// pacing between the iterations run by a worker. Each call draws a new value.
+type Delay func() time.Duration
+
+// Parses a delay description:
+//
+//	constant:2s (or just 2s)  always 2 seconds
+//	uniform:1s-5s             evenly distributed between 1 and 5 seconds
+//	exponential:3s            exponentially distributed with a mean of 3 seconds
+//	recorded:FILE             one of the durations listed in FILE, one per line,
+//	                          picked at random (e.g. think times seen in production)
+//
+// An empty description is no delay, and returns nil.
+func ParseDelay(description string) (Delay, error) {
+	if description == "" {
+		return nil, nil
+	}
+
+	kind, arg := "constant", description
+	if i := strings.Index(description, ":"); i >= 0 {
+		kind, arg = description[:i], description[i+1:]
+	}
+
+	switch kind {
+	case "constant":
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func() time.Duration { return d }, nil
+	case "uniform":
+		bounds := strings.SplitN(arg, "-", 2)
+		if len(bounds) != 2 {
+			return nil, errors.New("A uniform delay needs a range, like uniform:1s-5s")
+		}
+		min, err := time.ParseDuration(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		max, err := time.ParseDuration(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+		if max < min {
+			return nil, errors.New("The range of a uniform delay must not end before it starts")
+		}
+		return func() time.Duration { return min + time.Duration(rand.Int63n(int64(max-min)+1)) }, nil
+	case "exponential":
+		mean, err := time.ParseDuration(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func() time.Duration { return time.Duration(rand.ExpFloat64() * float64(mean)) }, nil
+	case "recorded":
+		recorded, err := readDurations(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func() time.Duration { return recorded[rand.Intn(len(recorded))] }, nil
+	}
+
+	return nil, errors.New("Unknown kind of delay: " + kind)
+}
+
+// Reads one duration per line, either a Go duration ("1.5s") or a number of
+// seconds ("1.5"). Blank lines are skipped.
+func readDurations(path string) ([]time.Duration, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	durations := make([]time.Duration, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if seconds, err := strconv.ParseFloat(line, 64); err == nil {
+			durations = append(durations, time.Duration(seconds*float64(time.Second)))
+			continue
+		}
+
+		d, err := time.ParseDuration(line)
+		if err != nil {
+			return nil, err
+		}
+		durations = append(durations, d)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(durations) == 0 {
+		return nil, errors.New("No durations recorded in " + path)
+	}
+
+	return durations, nil
+}
+
+// Waits for the next delay, if there is one, returning how long it waited.
+func (d Delay) Wait() time.Duration {
+	if d == nil {
+		return 0
+	}
+
+	wait := d()
+	if wait <= 0 {
+		return 0
+	}
+
+	time.Sleep(wait)
+	return wait
+}
@@ -0,0 +1,69 @@
+package benchmarker_test
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/cloudfoundry-community/pat/benchmarker"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Delays", func() {
+	draw := func(description string, n int) []time.Duration {
+		delay, err := ParseDelay(description)
+		Ω(err).ShouldNot(HaveOccurred())
+		draws := make([]time.Duration, n)
+		for i := range draws {
+			draws[i] = delay()
+		}
+		return draws
+	}
+
+	It("Is no delay when empty", func() {
+		delay, err := ParseDelay("")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(delay).Should(BeNil())
+		Ω(delay.Wait()).Should(BeZero())
+	})
+
+	It("Parses a constant delay", func() {
+		Ω(draw("constant:2s", 3)).Should(Equal([]time.Duration{2 * time.Second, 2 * time.Second, 2 * time.Second}))
+		Ω(draw("150ms", 1)).Should(Equal([]time.Duration{150 * time.Millisecond}))
+	})
+
+	It("Parses a uniform delay", func() {
+		for _, d := range draw("uniform:1s-2s", 100) {
+			Ω(d).Should(BeNumerically(">=", time.Second))
+			Ω(d).Should(BeNumerically("<=", 2*time.Second))
+		}
+	})
+
+	It("Parses an exponential delay", func() {
+		var total time.Duration
+		for _, d := range draw("exponential:1s", 2000) {
+			Ω(d).Should(BeNumerically(">=", 0))
+			total += d
+		}
+		Ω((total / 2000).Seconds()).Should(BeNumerically("~", 1, 0.2))
+	})
+
+	It("Draws a recorded delay from a file", func() {
+		file, _ := ioutil.TempFile("", "delays")
+		defer os.Remove(file.Name())
+		file.WriteString("1.5\n\n250ms\n")
+		file.Close()
+
+		for _, d := range draw("recorded:"+file.Name(), 20) {
+			Ω([]time.Duration{1500 * time.Millisecond, 250 * time.Millisecond}).Should(ContainElement(d))
+		}
+	})
+
+	It("Rejects delays it cannot parse", func() {
+		for _, description := range []string{"soon", "constant:soon", "uniform:1s", "uniform:2s-1s", "exponential:", "recorded:/no/such/file", "gaussian:1s"} {
+			_, err := ParseDelay(description)
+			Ω(err).Should(HaveOccurred(), description)
+		}
+	})
+})
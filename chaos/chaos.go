@@ -0,0 +1,134 @@
+package chaos
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/config"
+	"github.com/cloudfoundry-community/pat/experiment"
+)
+
+// A fault to inject at an offset (a duration, such as "5m") from the start
+// of an experiment, described in the "chaos" section of the configuration
+// file. It either runs Command with sh, failing if it exits with an error,
+// or sends a request to Webhook (a POST, unless Method is given, with Body),
+// failing unless it succeeds. Either fails if it takes longer than Timeout.
+type Action struct {
+	At      string `yaml:"at"`
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"`
+	Webhook string `yaml:"webhook"`
+	Method  string `yaml:"method"`
+	Body    string `yaml:"body"`
+}
+
+var actions []Action
+
+var Timeout = 60 * time.Second
+
+func DescribeParameters(config config.Config) {
+	actions = nil
+	config.SectionVar(&actions, "chaos", "faults (at, name, and a command or webhook) to inject at offsets from the start of each experiment")
+}
+
+// The hooks which inject the configured faults.
+func Hooks() ([]experiment.Hook, error) {
+	hooks := make([]experiment.Hook, len(actions))
+	for i, action := range actions {
+		hook, err := action.Hook()
+		if err != nil {
+			return nil, err
+		}
+		hooks[i] = hook
+	}
+
+	return hooks, nil
+}
+
+func (a Action) Hook() (experiment.Hook, error) {
+	at, err := time.ParseDuration(a.At)
+	if err != nil {
+		return experiment.Hook{}, fmt.Errorf("Invalid offset '%s' for chaos action: %s", a.At, err)
+	}
+
+	switch {
+	case a.Command != "" && a.Webhook != "":
+		return experiment.Hook{}, errors.New("A chaos action can have a command or a webhook, not both")
+	case a.Command != "":
+		return experiment.Hook{At: at, Name: a.name(a.Command), Fire: a.run}, nil
+	case a.Webhook != "":
+		return experiment.Hook{At: at, Name: a.name(a.method() + " " + a.Webhook), Fire: a.call}, nil
+	}
+
+	return experiment.Hook{}, errors.New("A chaos action needs a command or a webhook")
+}
+
+func (a Action) name(otherwise string) string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return otherwise
+}
+
+func (a Action) method() string {
+	if a.Method != "" {
+		return strings.ToUpper(a.Method)
+	}
+	return "POST"
+}
+
+// Where the platform allows, the command runs in its own process group, so
+// that when it times out any children it started are killed along with it.
+func (a Action) run() error {
+	var output bytes.Buffer
+	cmd := exec.Command("sh", "-c", a.Command)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	newProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(Timeout):
+		killProcessGroup(cmd)
+		<-done
+		err = fmt.Errorf("timed out after %s", Timeout)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
+
+func (a Action) call() error {
+	req, err := http.NewRequest(a.method(), a.Webhook, strings.NewReader(a.Body))
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return errors.New("Webhook replied " + resp.Status)
+	}
+
+	return nil
+}
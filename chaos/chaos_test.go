@@ -0,0 +1,120 @@
+package chaos_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/cloudfoundry-community/pat/chaos"
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chaos", func() {
+	Describe("A command action", func() {
+		It("Runs the command at its offset", func() {
+			hook, err := Action{At: "5m", Command: "echo killed > /dev/null"}.Hook()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(hook.At).Should(Equal(5 * time.Minute))
+			Ω(hook.Name).Should(Equal("echo killed > /dev/null"))
+			Ω(hook.Fire()).ShouldNot(HaveOccurred())
+		})
+
+		It("Fails with the command's output when the command fails", func() {
+			hook, _ := Action{At: "0s", Name: "kill the api vm", Command: "echo no such vm; exit 1"}.Hook()
+			Ω(hook.Name).Should(Equal("kill the api vm"))
+			err := hook.Fire()
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("no such vm"))
+		})
+
+		Context("When the command takes too long", func() {
+			BeforeEach(func() {
+				Timeout = 500 * time.Millisecond
+			})
+
+			AfterEach(func() {
+				Timeout = 60 * time.Second
+			})
+
+			It("Kills it, and any children it started, and fails", func() {
+				hook, _ := Action{At: "0s", Command: "echo started; sleep 600 & wait"}.Hook()
+				start := time.Now()
+				err := hook.Fire()
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("timed out"))
+				Ω(err.Error()).Should(ContainSubstring("started"))
+				Ω(time.Since(start).Seconds()).Should(BeNumerically("<", 5))
+			})
+		})
+	})
+
+	Describe("A webhook action", func() {
+		var (
+			server   *httptest.Server
+			status   int
+			received *http.Request
+			body     string
+		)
+
+		BeforeEach(func() {
+			status = http.StatusOK
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r
+				b, _ := ioutil.ReadAll(r.Body)
+				body = string(b)
+				w.WriteHeader(status)
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("Calls the webhook with the body, as a POST by default", func() {
+			hook, err := Action{At: "30s", Webhook: server.URL + "/errors", Body: `{"rate": 0.5}`}.Hook()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(hook.Name).Should(Equal("POST " + server.URL + "/errors"))
+			Ω(hook.Fire()).ShouldNot(HaveOccurred())
+			Ω(received.Method).Should(Equal("POST"))
+			Ω(received.URL.Path).Should(Equal("/errors"))
+			Ω(body).Should(Equal(`{"rate": 0.5}`))
+		})
+
+		It("Uses the given method, and fails if the webhook does not succeed", func() {
+			status = http.StatusInternalServerError
+			hook, _ := Action{At: "30s", Webhook: server.URL, Method: "delete"}.Hook()
+			Ω(hook.Fire()).Should(HaveOccurred())
+			Ω(received.Method).Should(Equal("DELETE"))
+		})
+	})
+
+	It("Rejects invalid actions", func() {
+		for _, action := range []Action{{At: "soon", Command: "true"}, {At: "1s"}, {At: "1s", Command: "true", Webhook: "http://example.com"}} {
+			_, err := action.Hook()
+			Ω(err).Should(HaveOccurred())
+		}
+	})
+
+	Describe("The configured hooks", func() {
+		It("Are read from the chaos section", func() {
+			file, _ := ioutil.TempFile("", "chaos")
+			defer os.Remove(file.Name())
+			file.WriteString("chaos:\n  - at: 1m\n    name: kill the api vm\n    command: bosh -d cf stop api/0 --hard\n  - at: 2m\n    webhook: http://fake-cf/errors\n")
+			file.Close()
+
+			flags := config.NewConfig()
+			DescribeParameters(flags)
+			Ω(flags.Parse([]string{"-config", file.Name()})).ShouldNot(HaveOccurred())
+
+			hooks, err := Hooks()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(hooks).Should(HaveLen(2))
+			Ω(hooks[0].Name).Should(Equal("kill the api vm"))
+			Ω(hooks[1].At).Should(Equal(2 * time.Minute))
+		})
+	})
+})
@@ -1,14 +1,18 @@
 package cmdline
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/cloudfoundry-community/pat/benchmarker"
+	"github.com/cloudfoundry-community/pat/chaos"
 	"github.com/cloudfoundry-community/pat/config"
 	. "github.com/cloudfoundry-community/pat/experiment"
 	. "github.com/cloudfoundry-community/pat/laboratory"
+	"github.com/cloudfoundry-community/pat/metrics"
 	"github.com/cloudfoundry-community/pat/store"
+	"github.com/cloudfoundry-community/pat/traffic"
 	"github.com/cloudfoundry-community/pat/workloads"
 )
 
@@ -26,6 +30,11 @@ var params = struct {
 	workload      string
 	interval      int
 	stop          int
+	thinkTime     string
+	pacing        string
+	warmup        int
+	warmupTime    int
+	replay        string
 }{}
 
 var workloadList = workloads.DefaultWorkloadList()
@@ -39,28 +48,84 @@ func InitCommandLineFlags(config config.Config) {
 	config.IntVar(&params.interval, "interval", 0, "repeat a workload at n second interval, to be used with -stop")
 	config.IntVar(&params.stop, "stop", 0, "stop a repeating interval after n second, to be used with -interval")
 	config.BoolVar(&params.listWorkloads, "list-workloads", false, "Lists the available workloads")
+	config.StringVar(&params.thinkTime, "think-time", "", "time to wait between the steps of an iteration, e.g. 2s, uniform:1s-5s, exponential:3s or recorded:FILE (not included in the step timings)")
+	config.IntVar(&params.warmup, "warmup-iterations", 0, "number of iterations, the first to finish, to treat as a warm-up, reported separately and excluded from the results")
+	config.IntVar(&params.warmupTime, "warmup-time", 0, "treat the iterations finishing in the first n seconds as a warm-up, reported separately and excluded from the results")
+	config.StringVar(&params.pacing, "pacing", "", "time each worker waits between iterations, in the same forms as -think-time")
+	config.StringVar(&params.replay, "replay", "", "guid of a stored experiment whose schedule of iterations to replay on the same timeline (see 'pat replay')")
+	suite = Suite{}
+	config.SectionVar(&suite, "suite", "a named list of experiments to run one after another (or in parallel) with a combined report")
 	workloadList.DescribeParameters(config)
 	store.DescribeParameters(config)
+	metrics.DescribeParameters(config)
+	traffic.DescribeParameters(config)
+	chaos.DescribeParameters(config)
 }
 
 func RunCommandLine() error {
-	worker := WorkerFactory()
+	worker, err := WorkerFactory()
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
 	return validateParameters(worker, func() error {
 		return store.WithStore(func(store Store) error {
 
 			lab := LaboratoryFactory(store)
+			if suite.defined() {
+				err := runSuite(lab, worker)
+				if err != nil {
+					fmt.Println(err)
+				}
+				return err
+			}
+
+			experiment := NewExperimentConfiguration(
+				params.iterations, params.concurrency, params.interval, params.stop, worker, params.workload)
+			var err error
+			switch {
+			case params.replay != "" && traffic.Configured():
+				err = errors.New("Give either -replay or -traffic-file, not both")
+			case params.replay != "":
+				experiment, err = replay(lab, worker, params.replay)
+			case traffic.Configured():
+				experiment, err = trafficShape(worker, params.concurrency)
+			}
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
+
+			handlers, err := metrics.Handlers()
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
 
-			handlers := make([]func(<-chan *Sample), 0)
 			if !params.silent {
 				handlers = append(handlers, func(s <-chan *Sample) {
-					display(params.concurrency, params.iterations, params.interval, params.stop, s)
+					display(experiment.Concurrency, experiment.Iterations, experiment.Interval, experiment.Stop, s)
 				})
 			}
 
-			lab.RunWithHandlers(
-				NewRunnableExperiment(
-					NewExperimentConfiguration(
-						params.iterations, params.concurrency, params.interval, params.stop, worker, params.workload)), handlers)
+			if experiment.Hooks, err = chaos.Hooks(); err != nil {
+				fmt.Println(err)
+				return err
+			}
+
+			for _, fixture := range workloadList.Fixtures() {
+				experiment.Fixtures = append(experiment.Fixtures, fixture)
+			}
+			experiment.WarmupIterations, experiment.WarmupTime = params.warmup, params.warmupTime
+			if err := withDelays(&experiment, params.thinkTime, params.pacing); err != nil {
+				fmt.Println(err)
+				return err
+			}
+			if _, err := lab.RunWithHandlers(NewRunnableExperiment(experiment), handlers); err != nil {
+				fmt.Println(err)
+				return err
+			}
 
 			BlockExit()
 			return nil
@@ -68,6 +133,74 @@ func RunCommandLine() error {
 	})
 }
 
+// Configures an experiment which replays the schedule recorded by a stored
+// experiment, so its iterations start at the same offsets, on the same
+// workers and with the same workloads, against whatever is now configured.
+func replay(lab Laboratory, worker benchmarker.Worker, guid string) (experiment ExperimentConfiguration, err error) {
+	samples, err := lab.GetData(guid)
+	if err != nil {
+		return experiment, err
+	}
+
+	schedule := ScheduleOf(samples)
+	if len(schedule) == 0 {
+		return experiment, errors.New("No recorded schedule to replay for experiment " + guid)
+	}
+
+	return scheduled(worker, schedule)
+}
+
+// Configures an experiment which reproduces the mix and rate of the requests
+// in the traffic file, mapped onto workloads by the traffic-mapping rules.
+func trafficShape(worker benchmarker.Worker, concurrency int) (experiment ExperimentConfiguration, err error) {
+	schedule, ignored, err := traffic.ConfiguredSchedule(concurrency)
+	if err != nil {
+		return experiment, err
+	}
+
+	if ignored > 0 {
+		fmt.Printf("Ignoring %d lines of the traffic file which were not requests, or matched no traffic-mapping rule\n", ignored)
+	}
+
+	if len(schedule) == 0 {
+		return experiment, errors.New("No requests in the traffic file matched a traffic-mapping rule")
+	}
+
+	return scheduled(worker, schedule)
+}
+
+// Configures an experiment which runs a schedule of iterations, rather than
+// a number of them.
+func scheduled(worker benchmarker.Worker, schedule []benchmarker.Scheduled) (experiment ExperimentConfiguration, err error) {
+	workers := make(map[int]bool)
+	workloads := make(map[string]bool)
+	for _, s := range schedule {
+		if !workloads[s.Workload] {
+			if ok, err := worker.Validate(s.Workload); !ok {
+				return experiment, fmt.Errorf("Invalid workload in the schedule: '%s'", err)
+			}
+			workloads[s.Workload] = true
+		}
+		workers[s.Worker] = true
+	}
+
+	experiment = NewExperimentConfiguration(len(schedule), len(workers), 0, 0, worker, schedule[0].Workload)
+	experiment.Schedule = schedule
+	return experiment, nil
+}
+
+func withDelays(experiment *ExperimentConfiguration, thinkTime string, pacing string) (err error) {
+	if experiment.ThinkTime, err = benchmarker.ParseDelay(thinkTime); err != nil {
+		return fmt.Errorf("Invalid think time '%s': %s", thinkTime, err)
+	}
+
+	if experiment.Pacing, err = benchmarker.ParseDelay(pacing); err != nil {
+		return fmt.Errorf("Invalid pacing '%s': %s", pacing, err)
+	}
+
+	return nil
+}
+
 func validateParameters(worker benchmarker.Worker, then func() error) error {
 	if params.listWorkloads {
 		worker.Visit(PrintWorkload)
@@ -91,9 +224,9 @@ var LaboratoryFactory = func(store Store) (lab Laboratory) {
 	return
 }
 
-var WorkerFactory = func() (worker benchmarker.Worker) {
+var WorkerFactory = func() (worker benchmarker.Worker, err error) {
 	worker = benchmarker.NewWorker()
-	workloadList.DescribeWorkloads(worker)
+	err = workloadList.DescribeWorkloads(worker)
 	return
 }
 
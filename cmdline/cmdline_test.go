@@ -1,6 +1,11 @@
 package cmdline_test
 
 import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"time"
+
 	"github.com/cloudfoundry-community/pat/benchmarker"
 	. "github.com/cloudfoundry-community/pat/cmdline"
 	"github.com/cloudfoundry-community/pat/config"
@@ -13,11 +18,15 @@ import (
 
 var _ = Describe("Cmdline", func() {
 	var (
-		flags config.Config
-		args  []string
-		lab   *dummyLab
+		flags   config.Config
+		args    []string
+		lab     *dummyLab
+		data    []*experiment.Sample
+		results []*experiment.Sample
+		runErr  error
+		err     error
 	)
-	var workerFactory = func() (worker benchmarker.Worker) {
+	var workerFactory = func() (worker benchmarker.Worker, err error) {
 		worker = benchmarker.NewWorker()
 		worker.AddWorkloadStep(workloads.Step("gcf:push", func() error { return nil }, "a"))
 		return
@@ -27,14 +36,14 @@ var _ = Describe("Cmdline", func() {
 		InitCommandLineFlags(flags)
 		flags.Parse(args)
 		LaboratoryFactory = func(store laboratory.Store) (newLab laboratory.Laboratory) {
-			lab = &dummyLab{}
+			lab = &dummyLab{data: data, results: results, err: runErr}
 			newLab = lab
 			return
 		}
 
 		BlockExit = func() {}
 
-		RunCommandLine()
+		err = RunCommandLine()
 	})
 
 	Describe("When -iterations is supplied", func() {
@@ -62,7 +71,7 @@ var _ = Describe("Cmdline", func() {
 	Describe("When -workload is supplied", func() {
 		BeforeEach(func() {
 			args = []string{"-workload", "login,push"}
-			WorkerFactory = func() (worker benchmarker.Worker) {
+			WorkerFactory = func() (worker benchmarker.Worker, err error) {
 				worker = benchmarker.NewWorker()
 				worker.AddWorkloadStep(workloads.Step("login", func() error { return nil }, "a"))
 				worker.AddWorkloadStep(workloads.Step("push", func() error { return nil }, "a"))
@@ -84,7 +93,7 @@ var _ = Describe("Cmdline", func() {
 			lab = nil
 			args = []string{"-list-workloads"}
 			printCalledCount = 0
-			WorkerFactory = func() (worker benchmarker.Worker) {
+			WorkerFactory = func() (worker benchmarker.Worker, err error) {
 				worker = benchmarker.NewWorker()
 				worker.AddWorkloadStep(workloads.Step("a", func() error { return nil }, "aa"))
 				worker.AddWorkloadStep(workloads.Step("b", func() error { return nil }, "bb"))
@@ -123,6 +132,349 @@ var _ = Describe("Cmdline", func() {
 			Ω(lab).Should(HaveBeenRunWith("stop", 11))
 		})
 	})
+
+	Describe("When -think-time and -pacing are supplied", func() {
+		BeforeEach(func() {
+			WorkerFactory = workerFactory
+			args = []string{"-think-time", "2s", "-pacing", "uniform:1s-1s"}
+		})
+
+		It("configures the experiment with the delays", func() {
+			Ω(lab.lastRunWith.ThinkTime()).Should(Equal(2 * time.Second))
+			Ω(lab.lastRunWith.Pacing()).Should(Equal(1 * time.Second))
+		})
+	})
+
+	Describe("When -warmup-iterations and -warmup-time are supplied", func() {
+		BeforeEach(func() {
+			WorkerFactory = workerFactory
+			args = []string{"-warmup-iterations", "2", "-warmup-time", "30"}
+		})
+
+		It("configures the experiment with the warm-up", func() {
+			Ω(lab.lastRunWith.WarmupIterations).Should(Equal(2))
+			Ω(lab.lastRunWith.WarmupTime).Should(Equal(30))
+		})
+	})
+
+	Describe("When an invalid -think-time is supplied", func() {
+		BeforeEach(func() {
+			WorkerFactory = workerFactory
+			args = []string{"-think-time", "sometimes"}
+		})
+
+		It("does not run the experiment", func() {
+			Ω(lab.lastRunWith).Should(BeNil())
+		})
+	})
+
+	Describe("When -replay is supplied", func() {
+		BeforeEach(func() {
+			WorkerFactory = func() (worker benchmarker.Worker, err error) {
+				worker = benchmarker.NewWorker()
+				worker.AddWorkloadStep(workloads.Step("gcf:push", func() error { return nil }, "a"))
+				worker.AddWorkloadStep(workloads.Step("login", func() error { return nil }, "a"))
+				return
+			}
+			args = []string{"-replay", "some-guid", "-iterations", "7", "-think-time", "2s"}
+		})
+
+		AfterEach(func() {
+			data = nil
+		})
+
+		Context("And the experiment recorded its schedule", func() {
+			BeforeEach(func() {
+				data = []*experiment.Sample{
+					&experiment.Sample{Scheduled: &benchmarker.Scheduled{Offset: 2 * time.Second, Worker: 1, Workload: "login"}},
+					&experiment.Sample{Type: experiment.WorkerSample},
+					&experiment.Sample{Scheduled: &benchmarker.Scheduled{Offset: time.Second, Worker: 0, Workload: "gcf:push"}},
+				}
+			})
+
+			It("replays the schedule, with the current settings", func() {
+				Ω(lab.lastRunWith.Schedule).Should(Equal([]benchmarker.Scheduled{
+					{Offset: time.Second, Worker: 0, Workload: "gcf:push"},
+					{Offset: 2 * time.Second, Worker: 1, Workload: "login"},
+				}))
+				Ω(lab.lastRunWith.Iterations).Should(Equal(2))
+				Ω(lab.lastRunWith.Concurrency).Should(Equal(2))
+				Ω(lab.lastRunWith.ThinkTime()).Should(Equal(2 * time.Second))
+			})
+		})
+
+		Context("And the experiment has no recorded schedule", func() {
+			It("does not run anything", func() {
+				Ω(lab.lastRunWith).Should(BeNil())
+			})
+		})
+
+		Context("And the schedule uses a workload which does not exist", func() {
+			BeforeEach(func() {
+				data = []*experiment.Sample{&experiment.Sample{Scheduled: &benchmarker.Scheduled{Workload: "rest:push"}}}
+			})
+
+			It("does not run anything", func() {
+				Ω(lab.lastRunWith).Should(BeNil())
+			})
+		})
+	})
+
+	Describe("When -traffic-file is supplied", func() {
+		BeforeEach(func() {
+			WorkerFactory = func() (worker benchmarker.Worker, err error) {
+				worker = benchmarker.NewWorker()
+				worker.AddWorkloadStep(workloads.Step("gcf:push", func() error { return nil }, "a"))
+				worker.AddWorkloadStep(workloads.Step("login", func() error { return nil }, "a"))
+				return
+			}
+			ioutil.WriteFile("/tmp/traffic.csv", []byte("1000,GET,/v2/info\n1002,GET,/v2/apps\n1003,PUT,/v2/apps/1/bits\n"), 0644)
+			ioutil.WriteFile("/tmp/traffic.yml", []byte(`
+traffic-mapping:
+- path: ^/v2/info$
+  workload: login
+- method: PUT
+  path: /bits$
+  workload: login,gcf:push
+`), 0644)
+			args = []string{"-config", "/tmp/traffic.yml", "-traffic-file", "/tmp/traffic.csv", "-concurrency", "5"}
+		})
+
+		It("reproduces the traffic's mapped requests at the same offsets", func() {
+			Ω(lab.lastRunWith.Schedule).Should(Equal([]benchmarker.Scheduled{
+				{Offset: 0, Worker: 0, Workload: "login"},
+				{Offset: 3 * time.Second, Worker: 1, Workload: "login,gcf:push"},
+			}))
+			Ω(lab.lastRunWith.Iterations).Should(Equal(2))
+			Ω(lab.lastRunWith.Concurrency).Should(Equal(2))
+		})
+	})
+
+	Describe("When the config file defines chaos actions", func() {
+		BeforeEach(func() {
+			WorkerFactory = workerFactory
+			ioutil.WriteFile("/tmp/chaos.yml", []byte(`
+chaos:
+- at: 30s
+  name: kill the api vm
+  command: bosh -d cf stop api/0 --hard
+`), 0644)
+			args = []string{"-config", "/tmp/chaos.yml"}
+		})
+
+		It("fires them during the experiment", func() {
+			Ω(lab.lastRunWith.Hooks).Should(HaveLen(1))
+			Ω(lab.lastRunWith.Hooks[0].Name).Should(Equal("kill the api vm"))
+			Ω(lab.lastRunWith.Hooks[0].At).Should(Equal(30 * time.Second))
+		})
+
+		Context("And an action is invalid", func() {
+			BeforeEach(func() {
+				ioutil.WriteFile("/tmp/chaos.yml", []byte("chaos:\n- at: soon\n  command: 'true'\n"), 0644)
+			})
+
+			It("does not run the experiment", func() {
+				Ω(lab.lastRunWith).Should(BeNil())
+			})
+		})
+	})
+
+	Describe("When the config file defines a suite", func() {
+		BeforeEach(func() {
+			WorkerFactory = func() (worker benchmarker.Worker, err error) {
+				worker = benchmarker.NewWorker()
+				worker.AddWorkloadStep(workloads.Step("gcf:push", func() error { return nil }, "a"))
+				worker.AddWorkloadStep(workloads.Step("login", func() error { return nil }, "a"))
+				worker.AddWorkloadStep(workloads.Step("push", func() error { return nil }, "a"))
+				return
+			}
+			ioutil.WriteFile("/tmp/suite.yml", []byte(`
+iterations: 4
+suite:
+  name: nightly
+  report: /tmp/suite-report.csv
+  experiments:
+  - name: logins
+    workload: login
+    concurrency: 2
+  - name: pushes
+    workload: login,push
+    iterations: 7
+    think-time: 3s
+`), 0755)
+			args = []string{"-config", "/tmp/suite.yml"}
+		})
+
+		It("runs each experiment in turn", func() {
+			Ω(lab.runs).Should(HaveLen(2))
+			Ω(lab.runs[0].Workload).Should(Equal("login"))
+			Ω(lab.runs[0].Concurrency).Should(Equal(2))
+			Ω(lab.runs[1].Workload).Should(Equal("login,push"))
+			Ω(lab.runs[1].Iterations).Should(Equal(7))
+			Ω(lab.runs[0].ThinkTime).Should(BeNil())
+			Ω(lab.runs[1].ThinkTime()).Should(Equal(3 * time.Second))
+		})
+
+		It("uses the command line values for anything an experiment does not set", func() {
+			Ω(lab.runs[0].Iterations).Should(Equal(4))
+			Ω(lab.runs[1].Concurrency).Should(Equal(1))
+		})
+
+		It("writes a combined report", func() {
+			report, err := ioutil.ReadFile("/tmp/suite-report.csv")
+			Ω(err).ShouldNot(HaveOccurred())
+			lines := strings.Split(strings.TrimSpace(string(report)), "\n")
+			Ω(lines).Should(HaveLen(3))
+			Ω(lines[1]).Should(HavePrefix("logins,"))
+			Ω(lines[2]).Should(HavePrefix("pushes,"))
+			Ω(lines[1]).Should(HaveSuffix(",passed"))
+		})
+
+		Context("And an experiment exceeds its thresholds", func() {
+			BeforeEach(func() {
+				results = []*experiment.Sample{{Average: 2 * time.Second, TotalErrors: 3}}
+				ioutil.WriteFile("/tmp/suite.yml", []byte(`
+suite:
+  name: nightly
+  report: /tmp/suite-report.csv
+  experiments:
+  - name: logins
+    workload: login
+    thresholds:
+      max-errors: 0
+      max-average: 1s
+  - name: pushes
+    workload: push
+    thresholds:
+      max-average: 5s
+`), 0755)
+			})
+
+			AfterEach(func() {
+				results = nil
+			})
+
+			It("fails the suite", func() {
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("1 of 2 experiments exceeded their thresholds"))
+			})
+
+			It("reports which thresholds were exceeded", func() {
+				report, _ := ioutil.ReadFile("/tmp/suite-report.csv")
+				lines := strings.Split(strings.TrimSpace(string(report)), "\n")
+				Ω(lines[1]).Should(HaveSuffix(",\"failed: 3 errors (max 0), average 2s (max 1s)\""))
+				Ω(lines[2]).Should(HaveSuffix(",passed"))
+			})
+		})
+
+		Context("And a threshold is not a duration", func() {
+			BeforeEach(func() {
+				ioutil.WriteFile("/tmp/suite.yml", []byte(`
+suite:
+  experiments:
+  - workload: login
+    thresholds:
+      max-worst: slow
+`), 0755)
+			})
+
+			It("does not run anything", func() {
+				Ω(err).Should(HaveOccurred())
+				Ω(lab.runs).Should(BeEmpty())
+			})
+		})
+
+		Context("And the experiments run in parallel", func() {
+			BeforeEach(func() {
+				ioutil.WriteFile("/tmp/suite.yml", []byte(`
+suite:
+  parallel: true
+  experiments:
+  - workload: login
+  - workload: push
+`), 0755)
+			})
+
+			It("gives each experiment its own worker", func() {
+				Ω(lab.runs).Should(HaveLen(2))
+				Ω(lab.runs[0].Worker).ShouldNot(BeIdenticalTo(lab.runs[1].Worker))
+			})
+		})
+
+		Context("And chaos faults are configured", func() {
+			BeforeEach(func() {
+				ioutil.WriteFile("/tmp/suite.yml", []byte(`
+chaos:
+- at: 1m
+  command: "true"
+suite:
+  experiments:
+  - workload: login
+  - workload: push
+`), 0755)
+			})
+
+			It("injects them into each experiment", func() {
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(lab.runs).Should(HaveLen(2))
+				Ω(lab.runs[0].Hooks).Should(HaveLen(1))
+				Ω(lab.runs[1].Hooks).Should(HaveLen(1))
+			})
+		})
+
+		Context("And chaos faults are configured for a parallel suite", func() {
+			BeforeEach(func() {
+				ioutil.WriteFile("/tmp/suite.yml", []byte(`
+chaos:
+- at: 1m
+  command: "true"
+suite:
+  parallel: true
+  experiments:
+  - workload: login
+`), 0755)
+			})
+
+			It("does not run anything", func() {
+				Ω(err).Should(HaveOccurred())
+				Ω(lab.runs).Should(BeEmpty())
+			})
+		})
+
+		Context("And an experiment's think time is invalid", func() {
+			BeforeEach(func() {
+				ioutil.WriteFile("/tmp/suite.yml", []byte(`
+suite:
+  experiments:
+  - workload: login
+  - workload: push
+    think-time: soon
+`), 0755)
+			})
+
+			It("does not run anything", func() {
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("Invalid think time 'soon'"))
+				Ω(lab.runs).Should(BeEmpty())
+			})
+		})
+
+		Context("And the laboratory rejects an experiment", func() {
+			BeforeEach(func() {
+				runErr = errors.New("rejected")
+			})
+
+			AfterEach(func() {
+				runErr = nil
+			})
+
+			It("returns the error rather than waiting for it forever", func() {
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("rejected"))
+				Ω(lab.runs).Should(HaveLen(1))
+			})
+		})
+	})
 })
 
 type runWithMatcher struct {
@@ -154,10 +506,14 @@ func (m *runWithMatcher) Match(actualLab interface{}) (bool, string, error) {
 
 type dummyLab struct {
 	lastRunWith *experiment.RunnableExperiment
+	runs        []*experiment.RunnableExperiment
+	data        []*experiment.Sample
+	results     []*experiment.Sample
+	err         error
 }
 
 func (d *dummyLab) GetData(guid string) ([]*experiment.Sample, error) {
-	return nil, nil
+	return d.data, nil
 }
 
 func (d *dummyLab) Run(runnable laboratory.Runnable) (experiment.Experiment, error) {
@@ -166,8 +522,24 @@ func (d *dummyLab) Run(runnable laboratory.Runnable) (experiment.Experiment, err
 
 func (d *dummyLab) RunWithHandlers(runnable laboratory.Runnable, handlers []func(<-chan *experiment.Sample)) (experiment.Experiment, error) {
 	d.lastRunWith = runnable.(*experiment.RunnableExperiment)
+	d.runs = append(d.runs, d.lastRunWith)
+	if d.err != nil {
+		return nil, d.err
+	}
+	for _, h := range handlers {
+		ch := make(chan *experiment.Sample, len(d.results))
+		for _, s := range d.results {
+			ch <- s
+		}
+		close(ch)
+		go h(ch)
+	}
 	return nil, nil
 }
 
 func (d *dummyLab) Visit(func(experiment.Experiment)) {
 }
+
+func (d *dummyLab) Cancel(guid string) error {
+	return nil
+}
@@ -8,7 +8,12 @@ import (
 )
 
 func display(concurrency int, iterations int, interval int, stop int, samples <-chan *experiment.Sample) {
+	events := make([]*experiment.Sample, 0)
 	for s := range samples {
+		if s.Event != nil {
+			events = append(events, s)
+		}
+
 		fmt.Print("\033[2J\033[;H")
 		fmt.Println("\x1b[32;1mCloud Foundry Performance Acceptance Tests\x1b[0m")
 		fmt.Printf("Test underway. Concurrency: \x1b[36m%v\x1b[0m  Workload iterations: \x1b[36m%v\x1b[0m  Interval: \x1b[36m%v\x1b[0m  Stop: \x1b[36m%v\x1b[0m\n", concurrency, iterations, interval, stop)
@@ -24,6 +29,9 @@ func display(concurrency int, iterations int, interval int, stop int, samples <-
 		fmt.Printf("\x1b[1mTotal time\x1b[0m:        \x1b[36m%v\x1b[0m\n", s.TotalTime)
 		fmt.Printf("\x1b[1mWall time\x1b[0m:         \x1b[36m%v\x1b[0m\n", s.WallTime)
 		fmt.Printf("\x1b[1mRunning Workers\x1b[0m:   \x1b[36m%v\x1b[0m\n", s.TotalWorkers)
+		if s.Warmup != nil {
+			fmt.Printf("\x1b[1mWarm-up\x1b[0m:           \x1b[36m%v\x1b[0m iterations, average \x1b[36m%v\x1b[0m, worst \x1b[36m%v\x1b[0m (not included above)\n", s.Warmup.Total, s.Warmup.Average, s.Warmup.WorstResult)
+		}
 		fmt.Println()
 		fmt.Println("\x1b[32;1mCommands Issued:\x1b[0m")
 		fmt.Println()
@@ -35,8 +43,22 @@ func display(concurrency int, iterations int, interval int, stop int, samples <-
 			fmt.Printf("\x1b[1m\tWorst time\x1b[0m:            \x1b[36m%v\x1b[0m\n", command.WorstTime)
 			fmt.Printf("\x1b[1m\tTotal time\x1b[0m:            \x1b[36m%v\x1b[0m\n", command.TotalTime)
 			fmt.Printf("\x1b[1m\tPer second throughput\x1b[0m: \x1b[36m%v\x1b[0m\n", command.Throughput)
+			if command.Retries > 0 {
+				fmt.Printf("\x1b[1m\tRetries\x1b[0m:               \x1b[36m%v\x1b[0m (\x1b[36m%v\x1b[0m recovered)\n", command.Retries, command.Recovered)
+			}
 		}
 		fmt.Println("┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄")
+		if len(events) > 0 {
+			fmt.Println()
+			fmt.Println("\x1b[32;1mEvents:\x1b[0m")
+			for _, e := range events {
+				fmt.Printf("\x1b[36m%v\x1b[0m\t%s", e.WallTime, e.Event.Name)
+				if e.Event.Error != "" {
+					fmt.Printf(" \x1b[31mfailed: %s\x1b[0m", e.Event.Error)
+				}
+				fmt.Println()
+			}
+		}
 		if s.TotalErrors > 0 {
 			fmt.Printf("\nTotal errors: %d\n", s.TotalErrors)
 			fmt.Printf("Last error: %v\n", "")
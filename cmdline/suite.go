@@ -0,0 +1,301 @@
+package cmdline
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/benchmarker"
+	"github.com/cloudfoundry-community/pat/chaos"
+	. "github.com/cloudfoundry-community/pat/experiment"
+	. "github.com/cloudfoundry-community/pat/laboratory"
+	"github.com/cloudfoundry-community/pat/metrics"
+)
+
+// A named group of experiments, described in the "suite" section of the
+// configuration file, which are run together and reported on together.
+type Suite struct {
+	Name        string            `yaml:"name"`
+	Parallel    bool              `yaml:"parallel"`
+	Report      string            `yaml:"report"`
+	Experiments []SuiteExperiment `yaml:"experiments"`
+}
+
+type SuiteExperiment struct {
+	Name        string     `yaml:"name"`
+	Workload    string     `yaml:"workload"`
+	Iterations  int        `yaml:"iterations"`
+	Concurrency int        `yaml:"concurrency"`
+	Interval    int        `yaml:"interval"`
+	Stop        int        `yaml:"stop"`
+	ThinkTime   string     `yaml:"think-time"`
+	Pacing      string     `yaml:"pacing"`
+	Warmup      int        `yaml:"warmup-iterations"`
+	WarmupTime  int        `yaml:"warmup-time"`
+	Thresholds  Thresholds `yaml:"thresholds"`
+}
+
+// The limits an experiment's final results must stay within for the suite to
+// pass. Anything left out is not checked.
+type Thresholds struct {
+	MaxErrors     *int   `yaml:"max-errors"`
+	MaxAverage    string `yaml:"max-average"`
+	MaxPercentile string `yaml:"max-95th-percentile"`
+	MaxWorst      string `yaml:"max-worst"`
+}
+
+type suiteResult struct {
+	name       string
+	guid       string
+	sample     *Sample
+	thresholds Thresholds
+	failures   []string
+}
+
+var suite = Suite{}
+
+func (s Suite) defined() bool {
+	return len(s.Experiments) > 0
+}
+
+// Fills in anything an experiment leaves out from the command line values,
+// so a suite only needs to spell out what differs between its experiments.
+func (e SuiteExperiment) withDefaults() SuiteExperiment {
+	if e.Workload == "" {
+		e.Workload = params.workload
+	}
+	if e.Iterations == 0 {
+		e.Iterations = params.iterations
+	}
+	if e.Concurrency == 0 {
+		e.Concurrency = params.concurrency
+	}
+	if e.Interval == 0 {
+		e.Interval = params.interval
+	}
+	if e.Stop == 0 {
+		e.Stop = params.stop
+	}
+	if e.ThinkTime == "" {
+		e.ThinkTime = params.thinkTime
+	}
+	if e.Pacing == "" {
+		e.Pacing = params.pacing
+	}
+	if e.Warmup == 0 {
+		e.Warmup = params.warmup
+	}
+	if e.WarmupTime == 0 {
+		e.WarmupTime = params.warmupTime
+	}
+	return e
+}
+
+func runSuite(lab Laboratory, worker benchmarker.Worker) error {
+	experiments := make([]SuiteExperiment, len(suite.Experiments))
+	configs := make([]ExperimentConfiguration, len(suite.Experiments))
+	for i, e := range suite.Experiments {
+		experiments[i] = e.withDefaults()
+		if experiments[i].Name == "" {
+			experiments[i].Name = "experiment-" + strconv.Itoa(i+1)
+		}
+
+		if ok, err := worker.Validate(experiments[i].Workload); !ok {
+			return fmt.Errorf("Invalid workload in suite experiment '%s': '%s'", experiments[i].Name, err)
+		}
+
+		e := experiments[i]
+		configs[i] = NewExperimentConfiguration(e.Iterations, e.Concurrency, e.Interval, e.Stop, worker, e.Workload)
+		if err := withDelays(&configs[i], e.ThinkTime, e.Pacing); err != nil {
+			return fmt.Errorf("Invalid suite experiment '%s': %s", e.Name, err)
+		}
+
+		if _, err := experiments[i].Thresholds.check(&Sample{}); err != nil {
+			return fmt.Errorf("Invalid suite experiment '%s': %s", experiments[i].Name, err)
+		}
+	}
+
+	hooks, err := chaos.Hooks()
+	if err != nil {
+		return err
+	}
+	if len(hooks) > 0 && suite.Parallel {
+		return errors.New("Chaos faults can not be injected into a parallel suite, whose experiments would each inject them")
+	}
+
+	results := make([]*suiteResult, len(experiments))
+	var wg sync.WaitGroup
+	for i, e := range experiments {
+		handlers, err := metrics.Handlers()
+		if err != nil {
+			return err
+		}
+
+		result := &suiteResult{name: e.Name, thresholds: e.Thresholds}
+		results[i] = result
+		done := make(chan bool)
+		handlers = append(handlers, func(samples <-chan *Sample) {
+			for s := range samples {
+				result.sample = s
+			}
+			close(done)
+		})
+
+		fmt.Printf("Starting experiment '%s' (%s)\n", e.Name, e.Workload)
+		experiment := configs[i]
+		if suite.Parallel {
+			// Experiments running at once each need their own worker, so that
+			// their worker numbers, and the schedules recorded with them, are
+			// their own.
+			if experiment.Worker, err = WorkerFactory(); err != nil {
+				wg.Wait()
+				return err
+			}
+		}
+		for _, fixture := range workloadList.Fixtures() {
+			experiment.Fixtures = append(experiment.Fixtures, fixture)
+		}
+		experiment.Hooks = hooks
+		experiment.WarmupIterations, experiment.WarmupTime = e.Warmup, e.WarmupTime
+		ex, err := lab.RunWithHandlers(NewRunnableExperiment(experiment), handlers)
+		if err != nil {
+			wg.Wait()
+			return fmt.Errorf("Could not start suite experiment '%s': %s", e.Name, err)
+		}
+		if ex != nil {
+			result.guid = ex.GetGuid()
+		}
+
+		wg.Add(1)
+		go func(name string) {
+			<-done
+			fmt.Printf("Finished experiment '%s'\n", name)
+			wg.Done()
+		}(e.Name)
+
+		if !suite.Parallel {
+			wg.Wait()
+		}
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		r.failures, _ = r.thresholds.check(r.final())
+		if len(r.failures) > 0 {
+			failed++
+		}
+	}
+
+	printSuiteReport(os.Stdout, suite.Name, results)
+	if suite.Report != "" {
+		if err := writeSuiteReport(suite.Report, results); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Suite '%s' failed: %d of %d experiments exceeded their thresholds", suite.Name, failed, len(results))
+	}
+
+	return nil
+}
+
+// Lists every threshold the sample exceeds, or returns an error if a
+// threshold is not a valid duration.
+func (t Thresholds) check(s *Sample) (failures []string, err error) {
+	if t.MaxErrors != nil && s.TotalErrors > *t.MaxErrors {
+		failures = append(failures, fmt.Sprintf("%d errors (max %d)", s.TotalErrors, *t.MaxErrors))
+	}
+
+	limits := []struct {
+		name   string
+		max    string
+		actual time.Duration
+	}{
+		{"average", t.MaxAverage, s.Average},
+		{"95th percentile", t.MaxPercentile, s.NinetyfifthPercentile},
+		{"worst", t.MaxWorst, s.WorstResult},
+	}
+	for _, l := range limits {
+		if l.max == "" {
+			continue
+		}
+
+		max, err := time.ParseDuration(l.max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s threshold '%s'", l.name, l.max)
+		}
+
+		if l.actual > max {
+			failures = append(failures, fmt.Sprintf("%s %v (max %v)", l.name, l.actual, max))
+		}
+	}
+
+	return failures, nil
+}
+
+func (r *suiteResult) outcome() string {
+	if len(r.failures) > 0 {
+		return "failed: " + strings.Join(r.failures, ", ")
+	}
+
+	return "passed"
+}
+
+func printSuiteReport(w io.Writer, name string, results []*suiteResult) {
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "\x1b[32;1mSuite results\x1b[0m: \x1b[36m%s\x1b[0m\n", name)
+	fmt.Fprintln(w, "┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄")
+	for _, r := range results {
+		s := r.final()
+		fmt.Fprintf(w, "\x1b[1m%s\x1b[0m (%s):\n", r.name, r.guid)
+		fmt.Fprintf(w, "\x1b[1m\tIterations\x1b[0m:      \x1b[36m%v\x1b[0m\n", s.Total)
+		fmt.Fprintf(w, "\x1b[1m\tErrors\x1b[0m:          \x1b[36m%v\x1b[0m\n", s.TotalErrors)
+		fmt.Fprintf(w, "\x1b[1m\tAverage\x1b[0m:         \x1b[36m%v\x1b[0m\n", s.Average)
+		fmt.Fprintf(w, "\x1b[1m\t95th Percentile\x1b[0m: \x1b[36m%v\x1b[0m\n", s.NinetyfifthPercentile)
+		fmt.Fprintf(w, "\x1b[1m\tWorst\x1b[0m:           \x1b[36m%v\x1b[0m\n", s.WorstResult)
+		fmt.Fprintf(w, "\x1b[1m\tWall time\x1b[0m:       \x1b[36m%v\x1b[0m\n", s.WallTime)
+		fmt.Fprintf(w, "\x1b[1m\tResult\x1b[0m:          \x1b[36m%s\x1b[0m\n", r.outcome())
+	}
+	fmt.Fprintln(w, "┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄┄")
+}
+
+func writeSuiteReport(path string, results []*suiteResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"Experiment", "Guid", "Total", "TotalErrors", "Average", "NinetyfifthPercentile", "WorstResult", "WallTime", "Result"})
+	for _, r := range results {
+		s := r.final()
+		w.Write([]string{r.name, r.guid,
+			strconv.Itoa(int(s.Total)),
+			strconv.Itoa(s.TotalErrors),
+			strconv.Itoa(int(s.Average.Nanoseconds())),
+			strconv.Itoa(int(s.NinetyfifthPercentile.Nanoseconds())),
+			strconv.Itoa(int(s.WorstResult.Nanoseconds())),
+			strconv.Itoa(int(s.WallTime.Nanoseconds())),
+			r.outcome()})
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+func (r *suiteResult) final() *Sample {
+	if r.sample == nil {
+		return &Sample{}
+	}
+
+	return r.sample
+}
@@ -1,9 +1,17 @@
 package config
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"launchpad.net/goyaml"
 )
@@ -13,13 +21,16 @@ type Config interface {
 	IntVar(target *int, name string, defaultValue int, description string)
 	BoolVar(target *bool, name string, defaultValue bool, description string)
 	EnvVar(target *string, name string, defaultValue string, description string)
+	SectionVar(target interface{}, name string, description string)
 	Parse(args []string) error
 }
 
 type f struct {
-	flagSet *flag.FlagSet
-	envVars []env
-	targets map[string]interface{}
+	flagSet  *flag.FlagSet
+	envVars  []env
+	sections map[string]section
+	targets  map[string]interface{}
+	sources  map[string]string
 }
 
 type env struct {
@@ -29,8 +40,24 @@ type env struct {
 	description  string
 }
 
+type section struct {
+	target      interface{}
+	description string
+}
+
+// Returned when a configuration file can not be applied; lists every problem
+// found in the file rather than just the first one.
+type ValidationError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s is not a valid configuration file:\n  %s", e.Path, strings.Join(e.Problems, "\n  "))
+}
+
 func NewConfig() *f {
-	return &f{flag.NewFlagSet(os.Args[0], flag.ExitOnError), make([]env, 0), make(map[string]interface{})}
+	return &f{flag.NewFlagSet(os.Args[0], flag.ExitOnError), make([]env, 0), make(map[string]section), make(map[string]interface{}), make(map[string]string)}
 }
 
 var ConfigAndFlags = NewConfig()
@@ -57,6 +84,17 @@ func (f *f) EnvVar(target *string, name string, defaultValue string, description
 	f.envVars = append(f.envVars, env{target, name, defaultValue, description})
 }
 
+// Binds a structured (nested maps, lists, typed values) top-level key of the
+// configuration file to target, which is decoded with the usual yaml tags.
+// Sections can only be set from a configuration file, not from a flag.
+func (f *f) SectionVar(target interface{}, name string, description string) {
+	if existing, ok := f.sections[name]; ok && existing.target != target {
+		panic("Tried to redefine section: " + name)
+	}
+
+	f.sections[name] = section{target, description}
+}
+
 func (f *f) allowDoubleSetting(target interface{}, name string, fn func()) {
 	if existing := f.flagSet.Lookup(name); existing == nil {
 		f.targets[name] = target
@@ -66,20 +104,46 @@ func (f *f) allowDoubleSetting(target interface{}, name string, fn func()) {
 	}
 }
 
+// Returned by Parse once -print-config has printed the configuration, when
+// the caller should exit rather than run anything.
+var ErrConfigPrinted = errors.New("Printed the configuration")
+
+// Values are taken, in order of precedence, from command line flags, from
+// PAT_* environment variables derived from the flag name (see EnvName), from
+// the -config file and finally from the flag's default.
 func (f *f) Parse(args []string) error {
 	config := f.flagSet.String("config", "", "YML file containing configuration parameters")
+	printConfig := f.flagSet.Bool("print-config", false, "Prints the effective configuration, with secrets redacted, and exits")
 
 	if err := f.ParseEnv(); err != nil {
 		return err
 	}
 
 	f.flagSet.Parse(args)
+	f.flagSet.Visit(func(flag *flag.Flag) {
+		f.sources[flag.Name] = "flag"
+	})
+
+	if value := os.Getenv(EnvName("config")); value != "" && f.sources["config"] != "flag" {
+		*config = value
+		f.sources["config"] = "env"
+	}
+
 	if len(*config) > 0 {
 		if err := f.ParseConfig(*config); err != nil {
-			panic("Failed Parsing Config File")
+			return err
 		}
 	}
 
+	if err := f.ParseFlagEnv(); err != nil {
+		return err
+	}
+
+	if *printConfig {
+		f.PrintConfig(os.Stdout)
+		return ErrConfigPrinted
+	}
+
 	return nil
 }
 
@@ -95,26 +159,390 @@ func (f *f) ParseEnv() error {
 	return nil
 }
 
+// Overrides every flag which was not given on the command line with the value
+// of its derived environment variable, if that is set.
+func (f *f) ParseFlagEnv() error {
+	var err error
+	f.flagSet.VisitAll(func(flag *flag.Flag) {
+		if f.sources[flag.Name] == "flag" || err != nil {
+			return
+		}
+
+		if value := os.Getenv(EnvName(flag.Name)); value != "" {
+			if setErr := f.flagSet.Set(flag.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: expected %s", value, EnvName(flag.Name), typeOf(f.targets[flag.Name]))
+				return
+			}
+			f.sources[flag.Name] = "env"
+		}
+	})
+
+	return err
+}
+
+// The environment variable which overrides a flag, e.g. PAT_REST_PASSWORD
+// for rest:password and PAT_REDIS_HOST for redis-host.
+func EnvName(flagName string) string {
+	return "PAT_" + envReplacer.Replace(strings.ToUpper(flagName))
+}
+
+var envReplacer = strings.NewReplacer(":", "_", "-", "_", ".", "_")
+
+// The last word of the name of a flag which holds a credential, as in
+// rest:password, rest:client-secret or redis-password.
+var secrets = []string{"password", "secret", "token"}
+
+func isSecret(name string) bool {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool { return r == ':' || r == '-' })
+	if len(words) == 0 {
+		return false
+	}
+
+	for _, s := range secrets {
+		if words[len(words)-1] == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Writes the effective value of every flag, and where it came from, redacting
+// credentials.
+func (f *f) PrintConfig(w io.Writer) {
+	f.flagSet.VisitAll(func(flag *flag.Flag) {
+		value := flag.Value.String()
+		if isSecret(flag.Name) && value != "" {
+			value = "[REDACTED]"
+		}
+
+		source := f.sources[flag.Name]
+		if source == "" {
+			source = "default"
+		}
+
+		fmt.Fprintf(w, "%s = %s (%s, %s)\n", flag.Name, value, source, EnvName(flag.Name))
+	})
+}
+
+// Checks that every key in the file refers to a known flag or section and
+// that every value has the right type, without needing a full run. Unlike
+// ParseConfig, nothing in the file is applied.
+func (f *f) ValidateConfig(path string) error {
+	return f.load(path, false)
+}
+
 func (f *f) ParseConfig(path string) error {
+	return f.load(path, true)
+}
+
+func (f *f) load(path string, set bool) error {
 	file, err := ioutil.ReadFile(path)
 	if err != nil {
 		return err
 	}
 
-	yml := make(map[string]string)
-	err = goyaml.Unmarshal(file, &yml)
-	if err != nil {
-		return err
+	yml := make(map[interface{}]interface{})
+	if err = goyaml.Unmarshal(file, &yml); err != nil {
+		return &ValidationError{path, []string{err.Error()}}
 	}
 
+	setOnCommandLine := make(map[string]bool)
 	f.flagSet.Visit(func(flag *flag.Flag) {
-		delete(yml, flag.Name)
+		setOnCommandLine[flag.Name] = true
 	})
 
-	for k, v := range yml {
-		flag := f.flagSet.Lookup(k)
-		flag.Value.Set(v)
+	doc := &document{strings.Split(string(file), "\n"), make([]problem, 0), set}
+	f.apply(doc, nil, yml, setOnCommandLine)
+
+	if len(doc.problems) > 0 {
+		sort.Stable(byLine(doc.problems))
+		messages := make([]string, len(doc.problems))
+		for i, p := range doc.problems {
+			messages[i] = p.message
+		}
+		return &ValidationError{path, messages}
 	}
 
 	return nil
 }
+
+type document struct {
+	lines    []string
+	problems []problem
+	set      bool
+}
+
+type problem struct {
+	line    int
+	message string
+}
+
+type byLine []problem
+
+func (p byLine) Len() int           { return len(p) }
+func (p byLine) Less(i, j int) bool { return p[i].line < p[j].line }
+func (p byLine) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+func (f *f) apply(doc *document, parents []string, yml map[interface{}]interface{}, setOnCommandLine map[string]bool) {
+	for _, k := range sortedKeys(yml) {
+		path := append(append([]string{}, parents...), k)
+		v := yml[k]
+
+		if s, ok := f.sections[k]; ok && len(parents) == 0 {
+			target := s.target
+			if !doc.set {
+				target = reflect.New(reflect.TypeOf(target).Elem()).Interface()
+			}
+			if err := doc.decodeSection(path, v, target); err != nil {
+				doc.problem(path, "invalid value for '%s': %s", k, err)
+			}
+			continue
+		}
+
+		name := f.flagName(parents, k)
+		if name == "" {
+			if nested, ok := v.(map[interface{}]interface{}); ok {
+				f.apply(doc, path, nested, setOnCommandLine)
+			} else {
+				doc.problem(path, "unknown setting '%s'", strings.Join(path, "."))
+			}
+			continue
+		}
+
+		if setOnCommandLine[name] {
+			continue
+		}
+
+		value, err := scalar(v)
+		if err != nil {
+			doc.problem(path, "invalid value for '%s': %s", name, err)
+			continue
+		}
+
+		if !doc.set {
+			if err := check(f.targets[name], value); err != nil {
+				doc.problem(path, "invalid value %q for '%s': expected %s", value, name, typeOf(f.targets[name]))
+			}
+			continue
+		}
+
+		if err := f.flagSet.Set(name, value); err != nil {
+			doc.problem(path, "invalid value %q for '%s': expected %s", value, name, typeOf(f.targets[name]))
+			continue
+		}
+
+		f.sources[name] = "config"
+	}
+}
+
+// Parses value as a flag of the same type as target would, without setting it.
+func check(target interface{}, value string) error {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	switch target.(type) {
+	case *int:
+		flags.Int("value", 0, "")
+	case *bool:
+		flags.Bool("value", false, "")
+	default:
+		flags.String("value", "", "")
+	}
+
+	return flags.Set("value", value)
+}
+
+// The descriptive headings settings may be grouped under without being
+// namespaced by them.
+var Headings = []string{"experiment", "load", "store", "outputs"}
+
+// A key nested inside a map may either be namespaced by its parents
+// ("rest: { target: x }" sets rest:target, "metrics: { address: x }" sets
+// metrics-address) or simply be grouped under one of the Headings, such as
+// "experiment:" or "store:", in which case it names the flag directly. Under
+// any other heading a key which is not namespaced is unknown.
+func (f *f) flagName(parents []string, key string) string {
+	candidates := []string{key}
+	if len(parents) > 0 {
+		prefix := parents[len(parents)-1]
+		candidates = []string{prefix + ":" + key, prefix + "-" + key}
+		if len(parents) == 1 && isHeading(prefix) {
+			candidates = append(candidates, key)
+		}
+	}
+
+	for _, c := range candidates {
+		if f.flagSet.Lookup(c) != nil {
+			return c
+		}
+	}
+
+	return ""
+}
+
+func isHeading(name string) bool {
+	for _, h := range Headings {
+		if h == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Decodes a section from its own lines of the file, when it is written as a
+// block, so that any line numbers in the error refer to the whole file.
+func (doc *document) decodeSection(path []string, v interface{}, target interface{}) error {
+	body, offset := doc.blockOf(path)
+	if body == "" {
+		return decodeSection(v, target)
+	}
+
+	if err := goyaml.Unmarshal([]byte(body), target); err != nil {
+		return errors.New(lineNumbers.ReplaceAllStringFunc(err.Error(), func(match string) string {
+			line, _ := strconv.Atoi(strings.TrimPrefix(match, "line "))
+			return fmt.Sprintf("line %d", line+offset)
+		}))
+	}
+
+	return nil
+}
+
+var lineNumbers = regexp.MustCompile(`line \d+`)
+
+// The lines nested under a key, with their common indentation removed, and
+// the number of lines in the file before them. Empty when the key's value
+// is written on the same line, e.g. as a flow sequence.
+func (doc *document) blockOf(path []string) (string, int) {
+	line := doc.lineOf(path)
+	if line == 0 {
+		return "", 0
+	}
+
+	key := doc.lines[line-1]
+	trimmed := strings.TrimLeft(key, " ")
+	rest := strings.TrimSpace(trimmed[strings.Index(trimmed, ":")+1:])
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", 0
+	}
+
+	depth := len(key) - len(trimmed)
+	indent := -1
+	end := line
+	for ; end < len(doc.lines); end++ {
+		content := strings.TrimLeft(doc.lines[end], " ")
+		if content == "" || strings.HasPrefix(content, "#") {
+			continue
+		}
+		d := len(doc.lines[end]) - len(content)
+		if d <= depth && !strings.HasPrefix(content, "- ") || d < depth {
+			break
+		}
+		if indent < 0 || d < indent {
+			indent = d
+		}
+	}
+
+	if indent < 0 {
+		return "", 0
+	}
+
+	body := make([]string, 0, end-line)
+	for _, l := range doc.lines[line:end] {
+		if len(l) >= indent {
+			l = l[indent:]
+		} else {
+			l = strings.TrimLeft(l, " ")
+		}
+		body = append(body, l)
+	}
+
+	return strings.Join(body, "\n"), line
+}
+
+func decodeSection(v interface{}, target interface{}) error {
+	encoded, err := goyaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return goyaml.Unmarshal(encoded, target)
+}
+
+func scalar(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case nil:
+		return "", nil
+	case map[interface{}]interface{}:
+		return "", errors.New("expected a single value but found a map")
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			s, err := scalar(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+func typeOf(target interface{}) string {
+	switch target.(type) {
+	case *int:
+		return "an integer"
+	case *bool:
+		return "true or false"
+	default:
+		return "a string"
+	}
+}
+
+func sortedKeys(yml map[interface{}]interface{}) []string {
+	keys := make([]string, 0, len(yml))
+	for k := range yml {
+		keys = append(keys, fmt.Sprint(k))
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (doc *document) problem(path []string, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	line := doc.lineOf(path)
+	if line > 0 {
+		message = fmt.Sprintf("line %d: %s", line, message)
+	}
+
+	doc.problems = append(doc.problems, problem{line, message})
+}
+
+// Finds the line a (possibly nested) key was declared on, by looking for each
+// key of the path in turn at a deeper indentation than its parent.
+func (doc *document) lineOf(path []string) int {
+	start, indent := 0, -1
+	for _, key := range path {
+		found := false
+		for i := start; i < len(doc.lines); i++ {
+			trimmed := strings.TrimLeft(doc.lines[i], " ")
+			depth := len(doc.lines[i]) - len(trimmed)
+			if depth <= indent && trimmed != "" && !strings.HasPrefix(trimmed, "#") && i > start {
+				break
+			}
+			if depth > indent && (strings.HasPrefix(trimmed, key+":") || strings.HasPrefix(trimmed, "\""+key+"\":")) {
+				start, indent, found = i, depth, true
+				break
+			}
+		}
+
+		if !found {
+			return 0
+		}
+	}
+
+	return start + 1
+}
@@ -1,6 +1,8 @@
 package config_test
 
 import (
+	"bytes"
+	"io"
 	"io/ioutil"
 	"os"
 
@@ -209,4 +211,302 @@ var _ = Describe("ConfigAndFlags", func() {
 			})
 		})
 	})
+
+	Describe("Structured configuration files", func() {
+		var (
+			name       string
+			iterations int
+			workload   string
+			target     string
+			redisHost  string
+			suite      struct {
+				Parallel    bool `yaml:"parallel"`
+				Experiments []struct {
+					Name       string `yaml:"name"`
+					Iterations int    `yaml:"iterations"`
+				} `yaml:"experiments"`
+			}
+			err error
+			yml string
+		)
+
+		BeforeEach(func() {
+			config.StringVar(&name, "name", "", "description")
+			config.IntVar(&iterations, "iterations", 1, "description")
+			config.StringVar(&workload, "workload", "", "description")
+			config.StringVar(&target, "rest:target", "", "description")
+			config.StringVar(&redisHost, "redis-host", "localhost", "description")
+			config.SectionVar(&suite, "suite", "description")
+		})
+
+		JustBeforeEach(func() {
+			ioutil.WriteFile("/tmp/config.yml", []byte(yml), 0755)
+			err = config.Parse([]string{"-config", "/tmp/config.yml"})
+		})
+
+		Context("When settings are nested under a namespace or a heading", func() {
+			BeforeEach(func() {
+				yml = "experiment:\n  iterations: 3\n  workload: [rest:target, rest:push]\nrest:\n  target: http://api\nstore:\n  redis-host: redis.example.com\n"
+			})
+
+			It("sets the corresponding flags", func() {
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(iterations).Should(Equal(3))
+				Ω(target).Should(Equal("http://api"))
+				Ω(redisHost).Should(Equal("redis.example.com"))
+			})
+
+			It("joins lists with commas", func() {
+				Ω(workload).Should(Equal("rest:target,rest:push"))
+			})
+		})
+
+		Context("When a setting is not known", func() {
+			BeforeEach(func() {
+				yml = "name: fred\nexperiment:\n  iterations: 3\n  iteratoins: 4\n"
+			})
+
+			It("returns an error with the line number, rather than panicking", func() {
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("line 4: unknown setting 'experiment.iteratoins'"))
+			})
+		})
+
+		Context("When a setting is grouped under an unknown heading", func() {
+			BeforeEach(func() {
+				yml = "experiments:\n  iterations: 3\n"
+			})
+
+			It("rejects it rather than falling back to the bare key", func() {
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("line 2: unknown setting 'experiments.iterations'"))
+				Ω(iterations).Should(Equal(1))
+			})
+		})
+
+		Context("When a value has the wrong type", func() {
+			BeforeEach(func() {
+				yml = "name: fred\niterations: lots\n"
+			})
+
+			It("returns an error with the line number and expected type", func() {
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("line 2: invalid value \"lots\" for 'iterations': expected an integer"))
+			})
+		})
+
+		Context("When the file is not valid YAML", func() {
+			BeforeEach(func() {
+				yml = "name: [fred\n"
+			})
+
+			It("returns an error", func() {
+				Ω(err).Should(HaveOccurred())
+			})
+		})
+
+		Context("When the file contains a section", func() {
+			BeforeEach(func() {
+				yml = "suite:\n  parallel: true\n  experiments:\n  - name: one\n    iterations: 2\n  - name: two\n"
+			})
+
+			It("decodes the section into its typed target", func() {
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(suite.Parallel).Should(BeTrue())
+				Ω(suite.Experiments).Should(HaveLen(2))
+				Ω(suite.Experiments[0].Name).Should(Equal("one"))
+				Ω(suite.Experiments[0].Iterations).Should(Equal(2))
+			})
+		})
+
+		Context("When a value in a section has the wrong type", func() {
+			BeforeEach(func() {
+				yml = "name: fred\nsuite:\n  parallel: true\n  experiments:\n  - name: one\n    iterations: lots\n"
+			})
+
+			It("returns an error with the line number in the file", func() {
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("line 2: invalid value for 'suite'"))
+				Ω(err.Error()).Should(ContainSubstring("line 6: "))
+			})
+		})
+	})
+
+	Describe("Validating a configuration file", func() {
+		var (
+			iterations int
+			suite      struct {
+				Parallel bool `yaml:"parallel"`
+			}
+			validate func(string) error
+			err      error
+			yml      string
+		)
+
+		BeforeEach(func() {
+			c := NewConfig()
+			config, validate = c, c.ValidateConfig
+			config.IntVar(&iterations, "iterations", 1, "description")
+			config.SectionVar(&suite, "suite", "description")
+		})
+
+		JustBeforeEach(func() {
+			ioutil.WriteFile("/tmp/config.yml", []byte(yml), 0755)
+			err = validate("/tmp/config.yml")
+		})
+
+		Context("When the file is valid", func() {
+			BeforeEach(func() {
+				yml = "iterations: 3\nsuite:\n  parallel: true\n"
+			})
+
+			It("does not apply any of its values", func() {
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(iterations).Should(Equal(1))
+				Ω(suite.Parallel).Should(BeFalse())
+			})
+		})
+
+		Context("When a value has the wrong type", func() {
+			BeforeEach(func() {
+				yml = "iterations: lots\n"
+			})
+
+			It("returns an error", func() {
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("line 1: invalid value \"lots\" for 'iterations': expected an integer"))
+			})
+		})
+	})
+
+	Describe("Overriding flags with environment variables", func() {
+		var (
+			password string
+			secret   string
+			reuse    bool
+			port     int
+			flags    []string
+			err      error
+			saved    map[string]string
+		)
+
+		variables := []string{"PAT_REST_PASSWORD", "PAT_REDIS_PORT", "PAT_CONFIG"}
+
+		BeforeEach(func() {
+			saved = make(map[string]string)
+			for _, v := range variables {
+				saved[v] = os.Getenv(v)
+				os.Unsetenv(v)
+			}
+			flags = []string{}
+			config.StringVar(&password, "rest:password", "", "description")
+			config.IntVar(&port, "redis-port", 6379, "description")
+			config.StringVar(&secret, "rest:client-secret", "hush", "description")
+			config.BoolVar(&reuse, "rest:reuse-tokens", true, "description")
+		})
+
+		AfterEach(func() {
+			for v, value := range saved {
+				if value == "" {
+					os.Unsetenv(v)
+				} else {
+					os.Setenv(v, value)
+				}
+			}
+		})
+
+		JustBeforeEach(func() {
+			err = config.Parse(flags)
+		})
+
+		It("derives the variable name from the flag name", func() {
+			Ω(EnvName("rest:password")).Should(Equal("PAT_REST_PASSWORD"))
+			Ω(EnvName("redis-port")).Should(Equal("PAT_REDIS_PORT"))
+		})
+
+		Context("When the variable is not set", func() {
+			It("uses the default value", func() {
+				Ω(port).Should(Equal(6379))
+			})
+		})
+
+		Context("When the variable is set", func() {
+			BeforeEach(func() {
+				os.Setenv("PAT_REST_PASSWORD", "s3cret")
+				os.Setenv("PAT_REDIS_PORT", "1234")
+			})
+
+			It("uses the value of the variable", func() {
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(password).Should(Equal("s3cret"))
+				Ω(port).Should(Equal(1234))
+			})
+
+			Context("And the flag is also given", func() {
+				BeforeEach(func() {
+					flags = []string{"-redis-port", "99"}
+				})
+
+				It("prefers the flag", func() {
+					Ω(port).Should(Equal(99))
+				})
+			})
+
+			Context("And the setting is also in a config file", func() {
+				BeforeEach(func() {
+					ioutil.WriteFile("/tmp/config.yml", []byte("redis-port: 5"), 0755)
+					flags = []string{"-config", "/tmp/config.yml"}
+				})
+
+				It("prefers the variable", func() {
+					Ω(port).Should(Equal(1234))
+				})
+			})
+
+			It("prints the effective configuration with secrets redacted", func() {
+				out := &bytes.Buffer{}
+				config.(interface {
+					PrintConfig(w io.Writer)
+				}).PrintConfig(out)
+				Ω(out.String()).Should(ContainSubstring("rest:password = [REDACTED] (env, PAT_REST_PASSWORD)"))
+				Ω(out.String()).Should(ContainSubstring("redis-port = 1234 (env, PAT_REDIS_PORT)"))
+				Ω(out.String()).Should(ContainSubstring("rest:client-secret = [REDACTED]"))
+				Ω(out.String()).Should(ContainSubstring("rest:reuse-tokens = true"))
+				Ω(out.String()).ShouldNot(ContainSubstring("s3cret"))
+			})
+		})
+
+		Context("When the config file is given by PAT_CONFIG", func() {
+			BeforeEach(func() {
+				ioutil.WriteFile("/tmp/config.yml", []byte("redis-port: 5"), 0755)
+				os.Setenv("PAT_CONFIG", "/tmp/config.yml")
+			})
+
+			It("loads that file", func() {
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(port).Should(Equal(5))
+			})
+		})
+
+		Context("When -print-config is given", func() {
+			BeforeEach(func() {
+				flags = []string{"-print-config"}
+			})
+
+			It("returns ErrConfigPrinted rather than exiting", func() {
+				Ω(err).Should(Equal(ErrConfigPrinted))
+			})
+		})
+
+		Context("When the variable has the wrong type", func() {
+			BeforeEach(func() {
+				os.Setenv("PAT_REDIS_PORT", "lots")
+			})
+
+			It("returns an error naming the variable", func() {
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("PAT_REDIS_PORT"))
+			})
+		})
+	})
 })
@@ -1,7 +1,9 @@
 package experiment
 
 import (
+	"log"
 	"math"
+	"sort"
 	"time"
 	. "github.com/cloudfoundry-community/pat/benchmarker"
 )
@@ -13,6 +15,8 @@ const (
 	WorkerSample	
 	ErrorSample
 	OtherSample
+	WarmupSample
+	EventSample
 )
 
 type Command struct {
@@ -22,6 +26,8 @@ type Command struct {
 	TotalTime  time.Duration
 	LastTime   time.Duration
 	WorstTime  time.Duration
+	Retries    int64
+	Recovered  int64
 }
 
 type Sample struct {
@@ -37,6 +43,45 @@ type Sample struct {
 	NinetyfifthPercentile time.Duration
 	WallTime     time.Duration
 	Type         SampleType
+	LastSteps    []StepResult
+	Warmup       *Warmup
+	Scheduled    *Scheduled
+	Event        *Event
+}
+
+// The statistics of the warm-up iterations, which are kept apart from (and
+// not included in) the rest of the sample.
+type Warmup struct {
+	Commands    map[string]Command
+	Total       int64
+	TotalErrors int
+	TotalTime   time.Duration
+	Average     time.Duration
+	WorstResult time.Duration
+}
+
+// Something done to the system under test while an experiment ran, such as a
+// fault injected by a hook. Each event is recorded as an event sample, which
+// carries the statistics of the sample before it.
+type Event struct {
+	Name  string
+	Error string
+}
+
+// An action fired at an offset from the start of an experiment. Hooks whose
+// offset comes after the experiment has finished are not fired.
+type Hook struct {
+	At   time.Duration
+	Name string
+	Fire func() error
+}
+
+// Something an experiment needs which is created before its first iteration
+// and removed after its last, such as the org and users of the rest workload's
+// fixture. Neither step is included in the experiment's timings.
+type Fixture interface {
+	Setup() error
+	Teardown() error
 }
 
 type Experiment interface {
@@ -51,6 +96,13 @@ type ExperimentConfiguration struct {
 	Stop        int
 	Worker      Worker
 	Workload    string
+	Fixtures    []Fixture
+	ThinkTime   Delay
+	Pacing      Delay
+	WarmupIterations int
+	WarmupTime       int
+	Schedule         []Scheduled
+	Hooks            []Hook
 }
 
 type RunnableExperiment struct {
@@ -72,6 +124,8 @@ type SamplableExperiment struct {
 	workers   chan int
 	samples   chan *Sample
 	quit      chan bool
+	warmupIterations int
+	warmupTime       time.Duration
 }
 
 type Executable interface {
@@ -83,52 +137,152 @@ type Samplable interface {
 }
 
 func NewExperimentConfiguration(iterations int, concurrency int, interval int, stop int, worker Worker, workload string) ExperimentConfiguration {
-	return ExperimentConfiguration{iterations, concurrency, interval, stop, worker, workload}
+	return ExperimentConfiguration{iterations, concurrency, interval, stop, worker, workload, nil, nil, nil, 0, 0, nil, nil}
 }
 
 func NewRunnableExperiment(config ExperimentConfiguration) *RunnableExperiment {
-	return &RunnableExperiment{config, config.newExecutableExperiment, newRunningExperiment}
+	return &RunnableExperiment{config, config.newExecutableExperiment, config.newRunningExperiment}
 }
 
 func (c ExperimentConfiguration) newExecutableExperiment(iterationResults chan IterationResult, errors chan error, workers chan int, quit chan bool) Executable {
 	return &ExecutableExperiment{c, iterationResults, workers, quit}
 }
 
-func newRunningExperiment(iterations int, iterationResults chan IterationResult, errors chan error, workers chan int, samples chan *Sample, quit chan bool) Samplable {
-	return &SamplableExperiment{iterations, iterationResults, workers, samples, quit}
+func (c ExperimentConfiguration) newRunningExperiment(iterations int, iterationResults chan IterationResult, errors chan error, workers chan int, samples chan *Sample, quit chan bool) Samplable {
+	return &SamplableExperiment{iterations, iterationResults, workers, samples, quit, c.WarmupIterations, time.Duration(c.WarmupTime) * time.Second}
 }
 
+// Sets up the fixtures, runs the experiment and tears the fixtures down. The
+// sampler starts after setup, so fixtures are not included in the wall time.
+// If a fixture cannot be set up the experiment is not run and the tracker is
+// sent a single error sample.
 func (config *RunnableExperiment) Run(tracker func(<-chan *Sample)) error {
+	for i, fixture := range config.Fixtures {
+		if err := fixture.Setup(); err != nil {
+			config.teardown(config.Fixtures[:i+1])
+			failed(tracker, err)
+			return err
+		}
+	}
+	defer config.teardown(config.Fixtures)
+
 	iteration := make(chan IterationResult)
 	errors := make(chan error)
 	workers := make(chan int)
 	samples := make(chan *Sample)
 	quit := make(chan bool)
 	done := make(chan bool)
+	events := make(chan Event)
+	finished := make(chan bool)
 	maxIterations := config.Iterations
 	if (config.Stop != 0 && config.Interval != 0 && config.Interval < config.Stop) {maxIterations *= config.Stop/config.Interval}
 	sampler := config.samplerFactory(maxIterations, iteration, errors, workers, samples, quit)
 	go sampler.Sample()
 	go func(d chan bool) {
-		tracker(samples)
+		tracker(withEvents(samples, events, time.Now()))
 		d <- true
 	}(done)
 
+	timers := config.fireHooks(events, finished)
 	config.executerFactory(iteration, errors, workers, quit).Execute()
+	for _, t := range timers {
+		t.Stop()
+	}
 	<-done
+	close(finished)
 	return nil
 }
 
+// Fires each hook at its offset, recording an event when it fires and
+// another if it fails.
+func (config *RunnableExperiment) fireHooks(events chan<- Event, finished <-chan bool) []*time.Timer {
+	timers := make([]*time.Timer, len(config.Hooks))
+	for i, hook := range config.Hooks {
+		hook := hook
+		timers[i] = time.AfterFunc(hook.At, func() {
+			record(events, finished, Event{Name: hook.Name})
+			if err := hook.Fire(); err != nil {
+				record(events, finished, Event{hook.Name, err.Error()})
+			}
+		})
+	}
+
+	return timers
+}
+
+func record(events chan<- Event, finished <-chan bool, event Event) {
+	select {
+	case events <- event:
+	case <-finished:
+	}
+}
+
+// Passes the samples on, along with an event sample for each event. Event
+// samples copy the sample before them, so they fit on the same timeline.
+func withEvents(samples <-chan *Sample, events <-chan Event, startTime time.Time) <-chan *Sample {
+	out := make(chan *Sample)
+	go func() {
+		defer close(out)
+		last := &Sample{Commands: make(map[string]Command)}
+		for {
+			select {
+			case s, ok := <-samples:
+				if !ok {
+					return
+				}
+				last = s
+				out <- s
+			case e := <-events:
+				event := *last
+				event.Type, event.LastSteps, event.Scheduled, event.Event = EventSample, nil, nil, &e
+				event.WallTime = time.Now().Sub(startTime)
+				out <- &event
+			}
+		}
+	}()
+
+	return out
+}
+
+func (config *RunnableExperiment) teardown(fixtures []Fixture) {
+	for i := len(fixtures) - 1; i >= 0; i-- {
+		if err := fixtures[i].Teardown(); err != nil {
+			log.Printf("Could not tear down experiment fixture: %s", err)
+		}
+	}
+}
+
+func failed(tracker func(<-chan *Sample), err error) {
+	samples := make(chan *Sample, 1)
+	samples <- &Sample{Commands: make(map[string]Command), TotalErrors: 1, LastError: err, Type: ErrorSample}
+	close(samples)
+	tracker(samples)
+}
+
+// Experiments with a schedule replay it, ignoring their iterations,
+// concurrency, interval and pacing.
 func (ex *ExecutableExperiment) Execute() {
+	if len(ex.Schedule) > 0 {
+		ExecuteSchedule(ex.Schedule, func(s Scheduled) {
+			Counted(ex.workers, TimedWithWorker(ex.iteration, ex.Worker, s.Workload, ex.ThinkTime))()
+		})
+		close(ex.iteration)
+		return
+	}
+
 	Execute(RepeatEveryUntil(ex.Interval, ex.Stop, func() {
-		ExecuteConcurrently(ex.Concurrency, Repeat(ex.Iterations, Counted(ex.workers, TimedWithWorker(ex.iteration, ex.Worker, ex.Workload))))
+		ExecuteConcurrentlyWithPacing(ex.Concurrency, ex.Pacing, Repeat(ex.Iterations, Counted(ex.workers, TimedWithWorker(ex.iteration, ex.Worker, ex.Workload, ex.ThinkTime))))
 	}, ex.quit))
 
 	close(ex.iteration)
 }
 
+// The first warmupIterations iterations to finish, and any which finish in
+// the first warmupTime, are warm-up iterations. They are sent as warm-up
+// samples and only counted in the sample's Warmup statistics.
 func (ex *SamplableExperiment) Sample() {
 	commands := make(map[string]Command)
+	var warmup *Warmup
 	var iterations int64
 	var totalTime time.Duration
 	var avg time.Duration
@@ -137,6 +291,7 @@ func (ex *SamplableExperiment) Sample() {
 	var totalErrors int
 	var workers int
 	var worstResult time.Duration
+	var lastSteps []StepResult
 	var ninetyfifthPercentile time.Duration
 	var percentileLength = int(math.Floor(float64(ex.maxIterations)*.05+0.95))
  	var percentile  = make([]time.Duration, percentileLength, percentileLength)
@@ -145,17 +300,26 @@ func (ex *SamplableExperiment) Sample() {
 
 	for {
 		sampleType := OtherSample
+		var scheduled *Scheduled
 		select {
 		case iteration, ok := <-ex.iteration:
 			if !ok {
 				close(ex.samples)
 				return
 			}
+			scheduled = offset(iteration.Scheduled, startTime)
+			if ex.warmingUp(warmup, startTime) {
+				sampleType = WarmupSample
+				warmup = warmup.add(iteration)
+				break
+			}
+
 			sampleType = ResultSample
 			iterations = iterations + 1
 			totalTime = totalTime + iteration.Duration
 			avg = time.Duration(totalTime.Nanoseconds() / iterations)	
 			lastResult = iteration.Duration	
+			lastSteps = iteration.Steps
 			if iteration.Duration > worstResult {
 				worstResult = iteration.Duration
 			}
@@ -170,18 +334,8 @@ func (ex *SamplableExperiment) Sample() {
 
 			ninetyfifthPercentile = percentile[percentileLength - int(math.Floor(float64(iterations)*.05+0.95))]
 			
-			for _, step := range iteration.Steps {
-				cmd := commands[step.Command]
-				cmd.Count = cmd.Count + 1
-				cmd.TotalTime = cmd.TotalTime + step.Duration
-				cmd.LastTime = step.Duration
-				cmd.Average = time.Duration(cmd.TotalTime.Nanoseconds() / cmd.Count)
-				cmd.Throughput = float64(cmd.Count) / cmd.TotalTime.Seconds()
-				if step.Duration > cmd.WorstTime {
-					cmd.WorstTime = step.Duration
-				}
-			
-				commands[step.Command] = cmd
+			for i, step := range iteration.Steps {
+				addStep(commands, step, failedStep(iteration, i))
 			}
 
 			if iteration.Error != nil {
@@ -193,6 +347,109 @@ func (ex *SamplableExperiment) Sample() {
 		case _ = <-heartbeat.C:
 			//heatbeat for updating CLI Walltime every second	
 		}
-		ex.samples <- &Sample{commands, avg, totalTime, iterations, totalErrors, workers, lastResult, lastError, worstResult, ninetyfifthPercentile, time.Now().Sub(startTime), sampleType}
+		// Only result samples carry the steps of their iteration.
+		steps := []StepResult(nil)
+		if sampleType == ResultSample {
+			steps = lastSteps
+		}
+		ex.samples <- &Sample{commands, avg, totalTime, iterations, totalErrors, workers, lastResult, lastError, worstResult, ninetyfifthPercentile, time.Now().Sub(startTime), sampleType, steps, warmup, scheduled, nil}
+	}
+}
+
+// A copy of the schedule of an iteration, with its offset from the start of
+// the experiment.
+func offset(scheduled *Scheduled, startTime time.Time) *Scheduled {
+	if scheduled == nil {
+		return nil
+	}
+
+	s := *scheduled
+	if s.Offset = s.Start.Sub(startTime); s.Offset < 0 {
+		s.Offset = 0
+	}
+	return &s
+}
+
+type byOffset []Scheduled
+
+func (p byOffset) Len() int           { return len(p) }
+func (p byOffset) Less(i, j int) bool { return p[i].Offset < p[j].Offset }
+func (p byOffset) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// The schedule recorded in an experiment's samples, including its warm-up,
+// in the order the iterations started.
+func ScheduleOf(samples []*Sample) []Scheduled {
+	schedule := make([]Scheduled, 0)
+	for _, s := range samples {
+		if s.Scheduled != nil {
+			schedule = append(schedule, *s.Scheduled)
+		}
+	}
+
+	sort.Stable(byOffset(schedule))
+	return schedule
+}
+
+func (ex *SamplableExperiment) warmingUp(warmup *Warmup, startTime time.Time) bool {
+	var warmedUp int64
+	if warmup != nil {
+		warmedUp = warmup.Total
+	}
+
+	return warmedUp < int64(ex.warmupIterations) || time.Now().Sub(startTime) < ex.warmupTime
+}
+
+// Returns the warm-up statistics including the iteration. The statistics are
+// copied so samples already sent are not changed.
+func (w *Warmup) add(iteration IterationResult) *Warmup {
+	next := &Warmup{Commands: make(map[string]Command)}
+	if w != nil {
+		*next = *w
+		next.Commands = make(map[string]Command)
+		for name, cmd := range w.Commands {
+			next.Commands[name] = cmd
+		}
+	}
+
+	next.Total = next.Total + 1
+	next.TotalTime = next.TotalTime + iteration.Duration
+	next.Average = time.Duration(next.TotalTime.Nanoseconds() / next.Total)
+	if iteration.Duration > next.WorstResult {
+		next.WorstResult = iteration.Duration
+	}
+	if iteration.Error != nil {
+		next.TotalErrors = next.TotalErrors + 1
+	}
+	for i, step := range iteration.Steps {
+		addStep(next.Commands, step, failedStep(iteration, i))
+	}
+
+	return next
+}
+
+// Only the last step of a failed iteration failed.
+func failedStep(iteration IterationResult, i int) bool {
+	return iteration.Error != nil && i == len(iteration.Steps)-1
+}
+
+// Adds the step to its command's statistics. Steps which only succeeded
+// after being retried are counted as recovered.
+func addStep(commands map[string]Command, step StepResult, failed bool) {
+	cmd := commands[step.Command]
+	if step.Attempts > 1 {
+		cmd.Retries = cmd.Retries + int64(step.Attempts-1)
+		if !failed {
+			cmd.Recovered = cmd.Recovered + 1
+		}
 	}
+	cmd.Count = cmd.Count + 1
+	cmd.TotalTime = cmd.TotalTime + step.Duration
+	cmd.LastTime = step.Duration
+	cmd.Average = time.Duration(cmd.TotalTime.Nanoseconds() / cmd.Count)
+	cmd.Throughput = float64(cmd.Count) / cmd.TotalTime.Seconds()
+	if step.Duration > cmd.WorstTime {
+		cmd.WorstTime = step.Duration
+	}
+
+	commands[step.Command] = cmd
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"time"
 	. "github.com/cloudfoundry-community/pat/benchmarker"
+	"github.com/cloudfoundry-community/pat/workloads"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -33,7 +34,7 @@ var _ = Describe("ExperimentConfiguration and Sampler", func() {
 				sampler = &DummySampler{maxIterations, samples, iterationResults, workers, errors, sampleFunc}
 				return sampler
 			}
-			config = &RunnableExperiment{ExperimentConfiguration{5, 2, 1, 3, worker, "push"}, executorFactory, samplerFactory}
+			config = &RunnableExperiment{ExperimentConfiguration{5, 2, 1, 3, worker, "push", nil, nil, nil, 0, 0, nil, nil}, executorFactory, samplerFactory}
 		})
 
 		It("Sends Samples from Sampler to the passed tracker function", func() {
@@ -130,6 +131,92 @@ var _ = Describe("ExperimentConfiguration and Sampler", func() {
 			Ω(got[0].Error()).Should(Equal("Foo"))
 		})
 
+		Describe("With fixtures", func() {
+			var (
+				events   []string
+				fixtures []Fixture
+			)
+
+			BeforeEach(func() {
+				events = make([]string, 0)
+				fixtures = []Fixture{&DummyFixture{"a", nil, &events}, &DummyFixture{"b", nil, &events}}
+				config.Fixtures = fixtures
+				executorFunc = func(e *DummyExecutor) {
+					events = append(events, "execute")
+				}
+				sampleFunc = func(s *DummySampler) {
+					close(s.samples)
+				}
+			})
+
+			It("Sets them up before executing and tears them down afterwards, in reverse order", func() {
+				config.Run(func(samples <-chan *Sample) {
+					for _ = range samples {
+					}
+				})
+				Ω(events).Should(Equal([]string{"setup a", "setup b", "execute", "teardown b", "teardown a"}))
+			})
+
+			Context("When a fixture cannot be set up", func() {
+				BeforeEach(func() {
+					fixtures[1].(*DummyFixture).err = errors.New("No quota")
+				})
+
+				It("Does not execute, tears down what was set up and sends an error sample", func() {
+					got := make([]*Sample, 0)
+					err := config.Run(func(samples <-chan *Sample) {
+						for s := range samples {
+							got = append(got, s)
+						}
+					})
+
+					Ω(err).Should(HaveOccurred())
+					Ω(events).Should(Equal([]string{"setup a", "setup b", "teardown b", "teardown a"}))
+					Ω(got).Should(HaveLen(1))
+					Ω(got[0].Type).Should(Equal(ErrorSample))
+					Ω(got[0].LastError.Error()).Should(Equal("No quota"))
+				})
+			})
+		})
+	})
+
+	Describe("Running an Experiment with hooks", func() {
+		It("Fires each hook at its offset, recording it on the timeline", func() {
+			fired := make(chan string, 3)
+			config := NewExperimentConfiguration(1, 1, 0, 0, NewWorker(), "push")
+			config.Hooks = []Hook{
+				{100 * time.Millisecond, "kill the api vm", func() error { fired <- "kill"; return nil }},
+				{200 * time.Millisecond, "break the router", func() error { fired <- "break"; return errors.New("No such vm") }},
+				{10 * time.Second, "too late", func() error { fired <- "too late"; return nil }},
+			}
+			runnable := NewRunnableExperiment(config)
+			runnable.executerFactory = func(iterationResults chan IterationResult, errors chan error, workers chan int, quit chan bool) Executable {
+				return &DummyExecutor{iterationResults, workers, errors, func(e *DummyExecutor) { time.Sleep(400 * time.Millisecond) }}
+			}
+			runnable.samplerFactory = func(maxIterations int, iterationResults chan IterationResult, errors chan error, workers chan int, samples chan *Sample, quit chan bool) Samplable {
+				return &DummySampler{maxIterations, samples, iterationResults, workers, errors, func(s *DummySampler) {
+					s.samples <- &Sample{Total: 3}
+					time.Sleep(500 * time.Millisecond)
+					close(s.samples)
+				}}
+			}
+
+			got := make([]*Sample, 0)
+			runnable.Run(func(samples <-chan *Sample) {
+				for s := range samples {
+					got = append(got, s)
+				}
+			})
+
+			Ω(got).Should(HaveLen(4))
+			Ω(got[1].Type).Should(Equal(EventSample))
+			Ω(got[1].Event).Should(Equal(&Event{"kill the api vm", ""}))
+			Ω(got[1].Total).Should(Equal(int64(3)))
+			Ω(got[1].WallTime.Seconds()).Should(BeNumerically("~", 0.1, 0.05))
+			Ω(got[2].Event).Should(Equal(&Event{"break the router", ""}))
+			Ω(got[3].Event).Should(Equal(&Event{"break the router", "No such vm"}))
+			Ω(fired).Should(HaveLen(2))
+		})
 	})
 
 	Describe("Executing", func() {
@@ -153,13 +240,13 @@ var _ = Describe("ExperimentConfiguration and Sampler", func() {
 			workers = make(chan int)
 			quit = make(chan bool)
 			samples = make(chan *Sample)
-			go (&SamplableExperiment{maxIterations, iteration, workers, samples, quit}).Sample()
+			go (&SamplableExperiment{maxIterations, iteration, workers, samples, quit, 0, 0}).Sample()
 		})
 
 		It("Calculates the running average", func() {
-			go func() { iteration <- IterationResult{2 * time.Second, nil, nil} }()
-			go func() { iteration <- IterationResult{4 * time.Second, nil, nil} }()
-			go func() { iteration <- IterationResult{6 * time.Second, nil, nil} }()
+			go func() { iteration <- IterationResult{2 * time.Second, nil, nil, nil} }()
+			go func() { iteration <- IterationResult{4 * time.Second, nil, nil, nil} }()
+			go func() { iteration <- IterationResult{6 * time.Second, nil, nil, nil} }()
 
 			Ω((<-samples).Average).Should(Equal(2 * time.Second))
 			Ω((<-samples).Average).Should(Equal(3 * time.Second))
@@ -168,7 +255,7 @@ var _ = Describe("ExperimentConfiguration and Sampler", func() {
 
 		It("Closes the samples channel when there are no more iterationResults", func() {
 			go func() {
-				iteration <- IterationResult{2 * time.Second, nil, nil}
+				iteration <- IterationResult{2 * time.Second, nil, nil, nil}
 				close(iteration)
 			}()
 
@@ -179,8 +266,8 @@ var _ = Describe("ExperimentConfiguration and Sampler", func() {
 
 		It("Counts errors", func() {
 			go func() {
-				iteration <- IterationResult{0, nil, errors.New("fishfingers burnt")}
-				iteration <- IterationResult{0, nil, errors.New("toast not buttered")}
+				iteration <- IterationResult{0, nil, errors.New("fishfingers burnt"), nil}
+				iteration <- IterationResult{0, nil, errors.New("toast not buttered"), nil}
 			}()
 
 			Ω((<-samples).TotalErrors).Should(Equal(1))
@@ -189,8 +276,8 @@ var _ = Describe("ExperimentConfiguration and Sampler", func() {
 
 		It("Calculates the throughput for a command", func() {
 			go func() {	
-				iteration <- IterationResult{0, []StepResult{StepResult{Command: "push", Duration: 1 * time.Second}}, nil} 
-				iteration <- IterationResult{0, []StepResult{StepResult{Command: "list", Duration: 2 * time.Second}}, nil} 
+				iteration <- IterationResult{0, []StepResult{StepResult{Command: "push", Duration: 1 * time.Second}}, nil, nil} 
+				iteration <- IterationResult{0, []StepResult{StepResult{Command: "list", Duration: 2 * time.Second}}, nil, nil} 
 			}()
 				
 			Ω((<-samples).Commands["push"].Throughput).Should(BeNumerically("==", 1))
@@ -200,7 +287,7 @@ var _ = Describe("ExperimentConfiguration and Sampler", func() {
 				iteration <- IterationResult{0, []StepResult{
 					StepResult{Command: "push", Duration: 3 * time.Second},
 					StepResult{Command: "push", Duration: 2 * time.Second}},
-				 nil} 			
+				 nil, nil} 			
 			}()
 			
 			sample := <-samples				
@@ -210,6 +297,186 @@ var _ = Describe("ExperimentConfiguration and Sampler", func() {
 		})
 	})
 
+	Describe("Sampling iteration steps", func() {
+		var (
+			iteration chan IterationResult
+			workers   chan int
+			samples   chan *Sample
+		)
+
+		BeforeEach(func() {
+			iteration = make(chan IterationResult)
+			workers = make(chan int)
+			samples = make(chan *Sample)
+			go (&SamplableExperiment{1, iteration, workers, samples, make(chan bool), 0, 0}).Sample()
+		})
+
+		It("Includes the steps and requests of the iteration in its result sample", func() {
+			steps := []StepResult{StepResult{Command: "push", Duration: time.Second, Requests: []workloads.RequestTrace{{Method: "GET", Status: 200}}}}
+			go func() { iteration <- IterationResult{time.Second, steps, nil, nil} }()
+			Ω((<-samples).LastSteps).Should(Equal(steps))
+
+			go func() { workers <- 1 }()
+			Ω((<-samples).LastSteps).Should(BeNil())
+		})
+	})
+
+	Describe("Sampling retried steps", func() {
+		var (
+			iteration chan IterationResult
+			samples   chan *Sample
+		)
+
+		BeforeEach(func() {
+			iteration = make(chan IterationResult)
+			samples = make(chan *Sample)
+			go (&SamplableExperiment{2, iteration, make(chan int), samples, make(chan bool), 0, 0}).Sample()
+		})
+
+		It("Counts the retries, and the steps which recovered", func() {
+			go func() {
+				iteration <- IterationResult{0, []StepResult{StepResult{Command: "push", Attempts: 3}}, nil, nil}
+				iteration <- IterationResult{0, []StepResult{StepResult{Command: "push", Attempts: 2}}, errors.New("still broken"), nil}
+			}()
+
+			Ω((<-samples).Commands["push"].Recovered).Should(Equal(int64(1)))
+			sample := <-samples
+			Ω(sample.Commands["push"].Retries).Should(Equal(int64(3)))
+			Ω(sample.Commands["push"].Recovered).Should(Equal(int64(1)))
+		})
+	})
+
+	Describe("Sampling a warm-up", func() {
+		var (
+			iteration chan IterationResult
+			workers   chan int
+			samples   chan *Sample
+		)
+
+		BeforeEach(func() {
+			iteration = make(chan IterationResult)
+			workers = make(chan int)
+			samples = make(chan *Sample)
+		})
+
+		Context("By count", func() {
+			BeforeEach(func() {
+				go (&SamplableExperiment{3, iteration, workers, samples, make(chan bool), 2, 0}).Sample()
+				go func() {
+					iteration <- IterationResult{10 * time.Second, []StepResult{StepResult{Command: "push", Duration: 10 * time.Second}}, nil, nil}
+					iteration <- IterationResult{8 * time.Second, nil, errors.New("cold"), nil}
+					iteration <- IterationResult{2 * time.Second, []StepResult{StepResult{Command: "push", Duration: 2 * time.Second}}, nil, nil}
+				}()
+			})
+
+			It("Reports the first iterations separately, as warm-up samples", func() {
+				first := <-samples
+				Ω(first.Type).Should(Equal(WarmupSample))
+				Ω(first.Total).Should(BeZero())
+				Ω(first.Warmup.Total).Should(Equal(int64(1)))
+
+				second := <-samples
+				Ω(second.Type).Should(Equal(WarmupSample))
+				Ω(second.Warmup.Total).Should(Equal(int64(2)))
+				Ω(second.Warmup.Average).Should(Equal(9 * time.Second))
+				Ω(second.Warmup.WorstResult).Should(Equal(10 * time.Second))
+				Ω(second.Warmup.TotalErrors).Should(Equal(1))
+				Ω(second.Warmup.Commands["push"].Count).Should(Equal(int64(1)))
+				Ω(first.Warmup.Total).Should(Equal(int64(1)))
+			})
+
+			It("Excludes the warm-up from the statistics", func() {
+				<-samples
+				<-samples
+				sample := <-samples
+				Ω(sample.Type).Should(Equal(ResultSample))
+				Ω(sample.Total).Should(Equal(int64(1)))
+				Ω(sample.Average).Should(Equal(2 * time.Second))
+				Ω(sample.WorstResult).Should(Equal(2 * time.Second))
+				Ω(sample.TotalErrors).Should(BeZero())
+				Ω(sample.Commands["push"].Count).Should(Equal(int64(1)))
+				Ω(sample.Warmup.Total).Should(Equal(int64(2)))
+			})
+		})
+
+		Context("By duration", func() {
+			BeforeEach(func() {
+				go (&SamplableExperiment{3, iteration, workers, samples, make(chan bool), 0, 200 * time.Millisecond}).Sample()
+			})
+
+			It("Treats the iterations finishing within the warm-up time as warm-up", func() {
+				go func() { iteration <- IterationResult{time.Second, nil, nil, nil} }()
+				Ω((<-samples).Type).Should(Equal(WarmupSample))
+
+				time.Sleep(300 * time.Millisecond)
+				go func() { iteration <- IterationResult{time.Second, nil, nil, nil} }()
+				Ω((<-samples).Type).Should(Equal(ResultSample))
+			})
+		})
+	})
+
+	Describe("Sampling the schedule", func() {
+		var (
+			iteration chan IterationResult
+			workers   chan int
+			samples   chan *Sample
+		)
+
+		BeforeEach(func() {
+			iteration = make(chan IterationResult)
+			workers = make(chan int)
+			samples = make(chan *Sample)
+			go (&SamplableExperiment{3, iteration, workers, samples, make(chan bool), 1, 0}).Sample()
+		})
+
+		It("Records the offset, worker and workload of each iteration, including the warm-up", func() {
+			go func() {
+				iteration <- IterationResult{0, nil, nil, &Scheduled{Start: time.Now(), Worker: 1, Workload: "push"}}
+				workers <- 1
+				iteration <- IterationResult{0, nil, nil, &Scheduled{Start: time.Now().Add(2 * time.Second), Workload: "login"}}
+			}()
+
+			warmup := <-samples
+			Ω(warmup.Type).Should(Equal(WarmupSample))
+			Ω(warmup.Scheduled.Worker).Should(Equal(1))
+			Ω(warmup.Scheduled.Workload).Should(Equal("push"))
+			Ω((<-samples).Scheduled).Should(BeNil())
+			result := <-samples
+			Ω(result.Scheduled.Offset.Seconds()).Should(BeNumerically("~", 2, 0.1))
+			Ω(result.Scheduled.Workload).Should(Equal("login"))
+		})
+
+		It("Is read back from the samples in the order the iterations started", func() {
+			schedule := ScheduleOf([]*Sample{
+				&Sample{Scheduled: &Scheduled{Offset: 2 * time.Second, Workload: "b"}},
+				&Sample{Type: WorkerSample},
+				&Sample{Scheduled: &Scheduled{Offset: time.Second, Workload: "a"}},
+			})
+			Ω(schedule).Should(Equal([]Scheduled{{Offset: time.Second, Workload: "a"}, {Offset: 2 * time.Second, Workload: "b"}}))
+		})
+	})
+
+	Describe("Replaying a schedule", func() {
+		It("Runs the scheduled workloads at their offsets, instead of the configured iterations", func() {
+			worker := NewWorker()
+			worker.AddWorkloadStep(workloads.Step("a", func() error { return nil }, ""))
+			worker.AddWorkloadStep(workloads.Step("b", func() error { return nil }, ""))
+			config := NewExperimentConfiguration(10, 1, 0, 0, worker, "a")
+			config.Schedule = []Scheduled{{Offset: 0, Workload: "a"}, {Offset: 500 * time.Millisecond, Worker: 1, Workload: "b"}}
+
+			iteration := make(chan IterationResult, 2)
+			workers := make(chan int, 4)
+			start := time.Now()
+			config.newExecutableExperiment(iteration, make(chan error), workers, make(chan bool)).Execute()
+
+			Ω(time.Now().Sub(start).Seconds()).Should(BeNumerically("~", 0.5, 0.1))
+			Ω((<-iteration).Steps[0].Command).Should(Equal("a"))
+			Ω((<-iteration).Steps[0].Command).Should(Equal("b"))
+			_, open := <-iteration
+			Ω(open).Should(BeFalse())
+		})
+	})
+
 	Describe("Sampling Percentile", func() {
 		var (
 			maxIterations int 	
@@ -227,14 +494,14 @@ var _ = Describe("ExperimentConfiguration and Sampler", func() {
 			quit = make(chan bool)
 			samples = make(chan *Sample)
 			ticks = make(chan int)
-			go (&SamplableExperiment{maxIterations, iteration, workers, samples, quit}).Sample()
+			go (&SamplableExperiment{maxIterations, iteration, workers, samples, quit, 0, 0}).Sample()
 		})
 
 		It("Calculates the 95th percentile", func() {	
 			samplesToSend := []int { 2, 5, 1, 9, 12, 8, 19, 57, 33, 44, 1, 12, 43, 99, 98, 19, 34, 19, 7, 55, 23}
 			expectedPercentiles := []int { 2, 5, 5, 9, 12, 12, 19, 57, 57, 57, 57, 57, 57, 99, 99, 99, 99, 99, 99, 99, 98}
 			
-			go func() { for i := 0; i < maxIterations; i++ { iteration <- IterationResult{time.Duration(samplesToSend[i]) * time.Second, nil, nil} } }()
+			go func() { for i := 0; i < maxIterations; i++ { iteration <- IterationResult{time.Duration(samplesToSend[i]) * time.Second, nil, nil, nil} } }()
 			for q := 0; q < maxIterations; q++ {
 				Ω((<-samples).NinetyfifthPercentile).Should(Equal(time.Duration(expectedPercentiles[q]) * time.Second))
 			}
@@ -265,3 +532,19 @@ func (s *DummySampler) Sample() {
 func (e *DummyExecutor) Execute() {
 	e.executorFunc(e)
 }
+
+type DummyFixture struct {
+	name   string
+	err    error
+	events *[]string
+}
+
+func (f *DummyFixture) Setup() error {
+	*f.events = append(*f.events, "setup "+f.name)
+	return f.err
+}
+
+func (f *DummyFixture) Teardown() error {
+	*f.events = append(*f.events, "teardown "+f.name)
+	return nil
+}
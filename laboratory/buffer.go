@@ -1,6 +1,10 @@
 package laboratory
 
-import "github.com/cloudfoundry-community/pat/experiment"
+import (
+	"sync"
+
+	"github.com/cloudfoundry-community/pat/experiment"
+)
 
 // An in-memory buffer so that the currently running experiment
 // can be served in-memory rather than round-tripping to the data
@@ -8,12 +12,15 @@ import "github.com/cloudfoundry-community/pat/experiment"
 type buffered struct {
 	name    string
 	samples []*experiment.Sample
+	state   string
+	lock    sync.Mutex
 }
 
 func (self *lab) buffer(buffered *buffered, samples <-chan *experiment.Sample) {
-	self.running = append(self.running, buffered)
 	for s := range samples {
+		buffered.lock.Lock()
 		buffered.samples = append(buffered.samples, s)
+		buffered.lock.Unlock()
 	}
 }
 
@@ -22,5 +29,46 @@ func (b *buffered) GetGuid() string {
 }
 
 func (b *buffered) GetData() ([]*experiment.Sample, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
 	return b.samples, nil
 }
+
+func (b *buffered) GetState() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.state
+}
+
+func (b *buffered) setState(state string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.state = state
+}
+
+func (b *buffered) start() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state != Queued {
+		return false
+	}
+
+	b.state = Running
+	return true
+}
+
+func (b *buffered) cancelIfQueued() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state != Queued {
+		return false
+	}
+
+	b.state = Cancelled
+	return true
+}
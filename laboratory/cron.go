@@ -0,0 +1,136 @@
+package laboratory
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A parsed five-field cron expression: minute, hour, day of month, month and
+// day of week. Each field accepts *, n, a-b, a,b and a step such as */15.
+type Cron struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+	anyDay   bool
+	anyWeek  bool
+}
+
+var cronAliases = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@nightly": "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+func ParseCron(expression string) (*Cron, error) {
+	if alias, ok := cronAliases[strings.TrimSpace(expression)]; ok {
+		expression = alias
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.New("A cron expression needs five fields (minute hour day-of-month month day-of-week): " + expression)
+	}
+
+	c := &Cron{anyDay: fields[2] == "*", anyWeek: fields[4] == "*"}
+	var err error
+	if c.minutes, err = cronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if c.hours, err = cronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if c.days, err = cronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if c.months, err = cronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if c.weekdays, err = cronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if c.weekdays[7] {
+		c.weekdays[0] = true
+	}
+
+	return c, nil
+}
+
+// True if the expression fires during the minute containing t. As with cron,
+// when both day of month and day of week are restricted either may match.
+func (c *Cron) Matches(t time.Time) bool {
+	if !c.minutes[t.Minute()] || !c.hours[t.Hour()] || !c.months[int(t.Month())] {
+		return false
+	}
+
+	day, weekday := c.days[t.Day()], c.weekdays[int(t.Weekday())]
+	switch {
+	case c.anyDay && c.anyWeek:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeek:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// The first minute after t at which the expression fires (or the zero time
+// if it never fires within the next year, e.g. for the 31st of February).
+func (c *Cron) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	for end := next.AddDate(1, 0, 0); next.Before(end); next = next.Add(time.Minute) {
+		if c.Matches(next) {
+			return next
+		}
+	}
+
+	return time.Time{}
+}
+
+func cronField(field string, min int, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return nil, errors.New("Invalid step in cron field: " + field)
+			}
+			part = part[:i]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, errors.New("Invalid cron field: " + field)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, errors.New("Invalid cron field: " + field)
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+
+		if from < min || to > max || from > to {
+			return nil, errors.New("Cron field out of range: " + field)
+		}
+
+		for v := from; v <= to; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,52 @@
+package laboratory
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cron", func() {
+	at := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", s)
+		Ω(err).ShouldNot(HaveOccurred())
+		return t
+	}
+
+	It("matches a fixed time of day", func() {
+		c, err := ParseCron("30 2 * * *")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(c.Matches(at("2014-03-04 02:30"))).Should(BeTrue())
+		Ω(c.Matches(at("2014-03-04 02:31"))).Should(BeFalse())
+	})
+
+	It("supports steps, ranges and lists", func() {
+		c, err := ParseCron("*/15 9-17 * * 1,3")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(c.Matches(at("2014-03-03 09:45"))).Should(BeTrue())  // Monday
+		Ω(c.Matches(at("2014-03-05 17:00"))).Should(BeTrue())  // Wednesday
+		Ω(c.Matches(at("2014-03-04 09:45"))).Should(BeFalse()) // Tuesday
+		Ω(c.Matches(at("2014-03-03 09:40"))).Should(BeFalse())
+		Ω(c.Matches(at("2014-03-03 18:00"))).Should(BeFalse())
+	})
+
+	It("supports aliases", func() {
+		c, err := ParseCron("@nightly")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(c.Matches(at("2014-03-04 00:00"))).Should(BeTrue())
+	})
+
+	It("finds the next time the expression fires", func() {
+		c, _ := ParseCron("0 2 * * *")
+		Ω(c.Next(at("2014-03-04 02:00"))).Should(Equal(at("2014-03-05 02:00")))
+		Ω(c.Next(at("2014-03-04 01:00"))).Should(Equal(at("2014-03-04 02:00")))
+	})
+
+	It("rejects invalid expressions", func() {
+		for _, expression := range []string{"every night", "* * * *", "60 * * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+			_, err := ParseCron(expression)
+			Ω(err).Should(HaveOccurred(), expression)
+		}
+	})
+})
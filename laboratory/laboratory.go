@@ -1,13 +1,32 @@
 package laboratory
 
 import (
+	"errors"
+	"sync"
+
 	"github.com/cloudfoundry-community/pat/experiment"
 	"github.com/nu7hatch/gouuid"
 )
 
+const (
+	Queued    = "Queued"
+	Running   = "Running"
+	Finished  = "Finished"
+	Cancelled = "Cancelled"
+)
+
 type lab struct {
-	store   Store
-	running []experiment.Experiment
+	store         Store
+	running       []experiment.Experiment
+	queue         []queued
+	active        int
+	maxConcurrent int
+	lock          sync.Mutex
+}
+
+type queued struct {
+	buffered *buffered
+	run      func()
 }
 
 type Laboratory interface {
@@ -15,6 +34,7 @@ type Laboratory interface {
 	RunWithHandlers(ex Runnable, fns []func(samples <-chan *experiment.Sample)) (experiment.Experiment, error)
 	Visit(fn func(ex experiment.Experiment))
 	GetData(name string) ([]*experiment.Sample, error)
+	Cancel(name string) error
 }
 
 type Runnable interface {
@@ -26,8 +46,20 @@ type Store interface {
 	LoadAll() ([]experiment.Experiment, error)
 }
 
+// Experiments which report their progress through the queue (i.e. those
+// started in this process, rather than loaded from the store).
+type Stateful interface {
+	GetState() string
+}
+
 func NewLaboratory(history Store) Laboratory {
-	lab := &lab{history, make([]experiment.Experiment, 0)}
+	return NewQueuedLaboratory(history, 0)
+}
+
+// A laboratory which runs at most maxConcurrent experiments at once, queueing
+// any others until a running experiment finishes. Zero means no limit.
+func NewQueuedLaboratory(history Store, maxConcurrent int) Laboratory {
+	lab := &lab{store: history, running: make([]experiment.Experiment, 0), maxConcurrent: maxConcurrent}
 	lab.reload()
 	return lab
 }
@@ -42,27 +74,77 @@ func (self *lab) Run(ex Runnable) (experiment.Experiment, error) {
 
 func (self *lab) RunWithHandlers(ex Runnable, additionalHandlers []func(<-chan *experiment.Sample)) (experiment.Experiment, error) {
 	guid, _ := uuid.NewV4()
-	buffered := &buffered{guid.String(), make([]*experiment.Sample, 0)}
-	handlers := make([]func(<-chan *experiment.Sample), 2)
-	handlers[0] = self.store.Writer(guid.String())
-	handlers[1] = func(samples <-chan *experiment.Sample) {
-		self.buffer(buffered, samples)
+	buffered := &buffered{name: guid.String(), samples: make([]*experiment.Sample, 0), state: Queued}
+
+	self.lock.Lock()
+	self.running = append(self.running, buffered)
+	self.queue = append(self.queue, queued{buffered, func() {
+		// The experiment is only saved once it leaves the queue, so one
+		// cancelled while queued leaves nothing behind in the store.
+		handlers := make([]func(<-chan *experiment.Sample), 2)
+		handlers[0] = self.store.Writer(buffered.name)
+		handlers[1] = func(samples <-chan *experiment.Sample) {
+			self.buffer(buffered, samples)
+		}
+		for _, h := range additionalHandlers {
+			handlers = append(handlers, h)
+		}
+
+		ex.Run(Multiplexer(handlers).Multiplex)
+	}})
+	self.dispatch()
+	self.lock.Unlock()
+
+	return buffered, nil
+}
+
+// Starts queued experiments, in the order they were queued, until the
+// maximum number are running. Must be called with the lock held.
+func (self *lab) dispatch() {
+	for len(self.queue) > 0 && (self.maxConcurrent <= 0 || self.active < self.maxConcurrent) {
+		next := self.queue[0]
+		self.queue = self.queue[1:]
+		if !next.buffered.start() {
+			continue
+		}
+
+		self.active++
+		go func(next queued) {
+			next.run()
+			next.buffered.setState(Finished)
+
+			self.lock.Lock()
+			self.active--
+			self.dispatch()
+			self.lock.Unlock()
+		}(next)
 	}
-	for _, h := range additionalHandlers {
-		handlers = append(handlers, h)
+}
+
+// Removes a queued experiment from the queue. Experiments which have already
+// started can not be cancelled.
+func (self *lab) Cancel(name string) error {
+	for _, e := range self.experiments() {
+		if e.GetGuid() == name {
+			if b, ok := e.(*buffered); ok && b.cancelIfQueued() {
+				return nil
+			}
+
+			return errors.New("Only queued experiments can be cancelled")
+		}
 	}
-	go ex.Run(Multiplexer(handlers).Multiplex)
-	return buffered, nil
+
+	return errors.New("No such experiment: " + name)
 }
 
 func (self *lab) Visit(fn func(ex experiment.Experiment)) {
-	for _, e := range self.running {
+	for _, e := range self.experiments() {
 		fn(e)
 	}
 }
 
 func (self *lab) GetData(name string) ([]*experiment.Sample, error) {
-	for _, e := range self.running {
+	for _, e := range self.experiments() {
 		if e.GetGuid() == name {
 			return e.GetData()
 		}
@@ -70,3 +152,10 @@ func (self *lab) GetData(name string) ([]*experiment.Sample, error) {
 
 	return nil, nil
 }
+
+func (self *lab) experiments() []experiment.Experiment {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	return append([]experiment.Experiment{}, self.running...)
+}
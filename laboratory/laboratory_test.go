@@ -1,6 +1,10 @@
 package laboratory
 
 import (
+	"errors"
+	"sync"
+	"time"
+
 	. "github.com/cloudfoundry-community/pat/experiment"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -19,7 +23,7 @@ var _ = Describe("Laboratory", func() {
 		)
 
 		BeforeEach(func() {
-			store = &dummyStore{make(map[string][]*Sample), make([]Experiment, 0)}
+			store = &dummyStore{store: make(map[string][]*Sample), previous: make([]Experiment, 0)}
 		})
 
 		JustBeforeEach(func() {
@@ -34,13 +38,11 @@ var _ = Describe("Laboratory", func() {
 				}
 			}})
 
-			Eventually(func() int {
-				got := make([]Experiment, 0)
-				lab.Visit(func(e Experiment) {
-					got = append(got, e)
-				})
-				return len(got)
-			}).Should(Equal(2))
+			for _, run := range []Experiment{run1, run2} {
+				Eventually(func() string {
+					return run.(Stateful).GetState()
+				}).Should(Equal(Finished))
+			}
 		})
 
 		It("lists running experiments", func() {
@@ -113,6 +115,153 @@ var _ = Describe("Laboratory", func() {
 	})
 })
 
+var _ = Describe("Queueing experiments", func() {
+	var (
+		lab     Laboratory
+		store   *dummyStore
+		release chan bool
+		started chan string
+		running []Experiment
+	)
+
+	BeforeEach(func() {
+		release = make(chan bool)
+		started = make(chan string, 10)
+		store = &dummyStore{store: make(map[string][]*Sample), previous: make([]Experiment, 0)}
+		lab = NewQueuedLaboratory(store, 1)
+		running = make([]Experiment, 0)
+		for _, name := range []string{"1", "2", "3"} {
+			ex, _ := lab.Run(&blockingExperiment{name, started, release})
+			running = append(running, ex)
+		}
+	})
+
+	AfterEach(func() {
+		close(release)
+	})
+
+	state := func(e Experiment) func() string {
+		return func() string { return e.(Stateful).GetState() }
+	}
+
+	It("only runs up to the maximum number of experiments at once", func() {
+		Eventually(started).Should(Receive(Equal("1")))
+		Consistently(started, 100*time.Millisecond).ShouldNot(Receive())
+		Ω(state(running[0])()).Should(Equal(Running))
+		Ω(state(running[1])()).Should(Equal(Queued))
+		Ω(state(running[2])()).Should(Equal(Queued))
+	})
+
+	It("starts the next queued experiment when one finishes", func() {
+		Eventually(started).Should(Receive(Equal("1")))
+		release <- true
+		Eventually(state(running[0])).Should(Equal(Finished))
+		Eventually(started).Should(Receive(Equal("2")))
+	})
+
+	It("lists queued experiments straight away", func() {
+		got := make([]Experiment, 0)
+		lab.Visit(func(e Experiment) {
+			got = append(got, e)
+		})
+		Ω(got).Should(HaveLen(3))
+	})
+
+	It("cancels queued experiments, so they never run", func() {
+		Ω(lab.Cancel(running[1].GetGuid())).Should(Succeed())
+		Ω(state(running[1])()).Should(Equal(Cancelled))
+
+		Eventually(started).Should(Receive(Equal("1")))
+		release <- true
+		Eventually(started).Should(Receive(Equal("3")))
+		Ω(store.saved(running[0].GetGuid())).Should(BeTrue())
+		Ω(store.saved(running[1].GetGuid())).Should(BeFalse())
+	})
+
+	It("does not cancel running experiments", func() {
+		Eventually(started).Should(Receive(Equal("1")))
+		Ω(lab.Cancel(running[0].GetGuid())).ShouldNot(Succeed())
+	})
+
+	It("returns an error when cancelling an unknown experiment", func() {
+		Ω(lab.Cancel("no-such-experiment")).ShouldNot(Succeed())
+	})
+})
+
+var _ = Describe("Scheduler", func() {
+	var (
+		lab         Laboratory
+		scheduler   *Scheduler
+		runs        int
+		handled     chan bool
+		handlersErr error
+	)
+
+	BeforeEach(func() {
+		runs = 0
+		handled = make(chan bool, 10)
+		handlersErr = nil
+		lab = NewLaboratory(&dummyStore{store: make(map[string][]*Sample), previous: make([]Experiment, 0)})
+		scheduler = NewScheduler(lab, func() ([]func(<-chan *Sample), error) {
+			return []func(<-chan *Sample){func(samples <-chan *Sample) {
+				for _ = range samples {
+				}
+				handled <- true
+			}}, handlersErr
+		})
+		_, err := scheduler.Add("nightly", "0 2 * * *", func() Runnable {
+			runs++
+			return &dummyExperiment{"nightly", nil}
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	It("enqueues an experiment when the schedule is due, once per minute", func() {
+		scheduler.RunDue(time.Date(2014, 3, 4, 1, 59, 0, 0, time.Local))
+		Ω(runs).Should(Equal(0))
+		scheduler.RunDue(time.Date(2014, 3, 4, 2, 0, 10, 0, time.Local))
+		scheduler.RunDue(time.Date(2014, 3, 4, 2, 0, 40, 0, time.Local))
+		Ω(runs).Should(Equal(1))
+		scheduler.RunDue(time.Date(2014, 3, 5, 2, 0, 0, 0, time.Local))
+		Ω(runs).Should(Equal(2))
+	})
+
+	It("records the last experiment it enqueued", func() {
+		scheduler.RunDue(time.Date(2014, 3, 4, 2, 0, 0, 0, time.Local))
+		scheduler.Visit(func(s Schedule) {
+			Ω(s.LastRun).ShouldNot(BeEmpty())
+			Ω(s.Next).Should(Equal(time.Date(2014, 3, 5, 2, 0, 0, 0, time.Local)))
+		})
+	})
+
+	It("runs the experiment with the configured handlers", func() {
+		scheduler.RunDue(time.Date(2014, 3, 4, 2, 0, 0, 0, time.Local))
+		Eventually(handled).Should(Receive())
+	})
+
+	It("records an error getting the handlers rather than running without them", func() {
+		handlersErr = errors.New("no sink")
+		scheduler.RunDue(time.Date(2014, 3, 4, 2, 0, 0, 0, time.Local))
+		Ω(runs).Should(Equal(0))
+		scheduler.Visit(func(s Schedule) {
+			Ω(s.LastError).Should(Equal("no sink"))
+		})
+	})
+
+	It("removes schedules", func() {
+		scheduler.Visit(func(s Schedule) {
+			Ω(scheduler.Remove(s.Id)).Should(Succeed())
+		})
+		scheduler.RunDue(time.Date(2014, 3, 4, 2, 0, 0, 0, time.Local))
+		Ω(runs).Should(Equal(0))
+	})
+
+	It("rejects invalid cron expressions", func() {
+		_, err := scheduler.Add("bad", "whenever", nil)
+		Ω(err).Should(HaveOccurred())
+	})
+})
+
 func data(s []*Sample, e error) []*Sample {
 	Ω(e).ShouldNot(HaveOccurred())
 	return s
@@ -121,6 +270,8 @@ func data(s []*Sample, e error) []*Sample {
 type dummyStore struct {
 	store    map[string][]*Sample
 	previous []Experiment
+	writers  []string
+	lock     sync.Mutex
 }
 
 type dummyExperiment struct {
@@ -129,6 +280,9 @@ type dummyExperiment struct {
 }
 
 func (store *dummyStore) Writer(guid string) func(samples <-chan *Sample) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.writers = append(store.writers, guid)
 	return func(samples <-chan *Sample) {
 		for s := range samples {
 			store.store[guid] = append(store.store[guid], s)
@@ -136,6 +290,18 @@ func (store *dummyStore) Writer(guid string) func(samples <-chan *Sample) {
 	}
 }
 
+func (store *dummyStore) saved(guid string) bool {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for _, w := range store.writers {
+		if w == guid {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (store *dummyStore) LoadAll() ([]Experiment, error) {
 	return store.previous, nil
 }
@@ -162,3 +328,18 @@ func (e *dummyExperiment) GetData() ([]*Sample, error) {
 func (e *dummyExperiment) GetGuid() string {
 	return e.name
 }
+
+type blockingExperiment struct {
+	name    string
+	started chan string
+	release chan bool
+}
+
+func (e *blockingExperiment) Run(fn func(samples <-chan *Sample)) error {
+	e.started <- e.name
+	<-e.release
+	ch := make(chan *Sample)
+	close(ch)
+	fn(ch)
+	return nil
+}
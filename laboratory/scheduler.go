@@ -0,0 +1,135 @@
+package laboratory
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/experiment"
+	"github.com/nu7hatch/gouuid"
+)
+
+// A recurring experiment: every time Cron fires, the Scheduler enqueues a
+// fresh Runnable from the factory on its Laboratory.
+type Schedule struct {
+	Id          string
+	Name        string
+	Cron        string
+	Next        time.Time
+	LastRun     string
+	LastError   string
+	cron        *Cron
+	factory     func() Runnable
+	lastFiredAt time.Time
+}
+
+type Scheduler struct {
+	lab       Laboratory
+	handlers  func() ([]func(<-chan *experiment.Sample), error)
+	schedules map[string]*Schedule
+	lock      sync.Mutex
+}
+
+// Scheduled experiments are run with the sample handlers returned by handlers,
+// so they are reported on in the same way as any other experiment.
+func NewScheduler(lab Laboratory, handlers func() ([]func(<-chan *experiment.Sample), error)) *Scheduler {
+	return &Scheduler{lab: lab, handlers: handlers, schedules: make(map[string]*Schedule)}
+}
+
+func (s *Scheduler) Add(name string, cron string, factory func() Runnable) (*Schedule, error) {
+	parsed, err := ParseCron(cron)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := uuid.NewV4()
+	schedule := &Schedule{Id: id.String(), Name: name, Cron: cron, Next: parsed.Next(time.Now()), cron: parsed, factory: factory}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.schedules[schedule.Id] = schedule
+	return schedule, nil
+}
+
+func (s *Scheduler) Remove(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.schedules[id]; !ok {
+		return errors.New("No such schedule: " + id)
+	}
+
+	delete(s.schedules, id)
+	return nil
+}
+
+// Visits every schedule, in order of when it next fires.
+func (s *Scheduler) Visit(fn func(schedule Schedule)) {
+	s.lock.Lock()
+	all := make([]Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		all = append(all, *schedule)
+	}
+	s.lock.Unlock()
+
+	sort.Sort(byNext(all))
+	for _, schedule := range all {
+		fn(schedule)
+	}
+}
+
+// Enqueues an experiment for every schedule which fires during the minute
+// containing now, at most once per schedule per minute.
+func (s *Scheduler) RunDue(now time.Time) {
+	minute := now.Truncate(time.Minute)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, schedule := range s.schedules {
+		if schedule.cron.Matches(minute) && !schedule.lastFiredAt.Equal(minute) {
+			schedule.lastFiredAt = minute
+			s.run(schedule)
+		}
+		schedule.Next = schedule.cron.Next(now)
+	}
+}
+
+func (s *Scheduler) run(schedule *Schedule) {
+	handlers, err := s.handlers()
+	if err != nil {
+		schedule.LastError = err.Error()
+		return
+	}
+
+	ex, err := s.lab.RunWithHandlers(schedule.factory(), handlers)
+	if err != nil {
+		schedule.LastError = err.Error()
+		return
+	}
+
+	schedule.LastError = ""
+	if ex != nil {
+		schedule.LastRun = ex.GetGuid()
+	}
+}
+
+// Checks for due schedules until quit is closed.
+func (s *Scheduler) Start(quit <-chan bool) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.RunDue(now)
+		case <-quit:
+			return
+		}
+	}
+}
+
+type byNext []Schedule
+
+func (b byNext) Len() int           { return len(b) }
+func (b byNext) Less(i, j int) bool { return b[i].Next.Before(b[j].Next) }
+func (b byNext) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -16,13 +16,55 @@ func main() {
 
 	cmdline.InitCommandLineFlags(flags)
 	server.InitCommandLineFlags(flags)
-	flags.Parse(os.Args[1:])
+
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "validate" {
+		os.Exit(validateConfig(os.Args[3:]))
+	}
+
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "replay" {
+		if len(args) < 2 {
+			fmt.Println("Usage: pat replay <guid> [flags]")
+			os.Exit(2)
+		}
+		args = append([]string{"-replay", args[1]}, args[2:]...)
+	}
+
+	if len(args) > 0 && args[0] == "traffic" {
+		if len(args) < 2 {
+			fmt.Println("Usage: pat traffic <access log or CSV> [flags]")
+			os.Exit(2)
+		}
+		args = append([]string{"-traffic-file", args[1]}, args[2:]...)
+	}
+
+	if err := flags.Parse(args); err == config.ErrConfigPrinted {
+		os.Exit(0)
+	} else if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	if useServer == true {
 		fmt.Println("Starting in server mode")
 		server.Serve()
 		server.Bind()
-	} else {
-		cmdline.RunCommandLine()
+	} else if err := cmdline.RunCommandLine(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func validateConfig(args []string) int {
+	if len(args) != 1 {
+		fmt.Println("Usage: pat config validate <config.yml>")
+		return 2
 	}
+
+	if err := config.ConfigAndFlags.ValidateConfig(args[0]); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	fmt.Printf("%s is valid\n", args[0])
+	return 0
 }
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"github.com/cloudfoundry-community/pat/benchmarker"
+	"github.com/cloudfoundry-community/pat/config"
+	"github.com/cloudfoundry-community/pat/experiment"
+)
+
+var params = struct {
+	address  string
+	protocol string
+	network  string
+	prefix   string
+
+	traceEndpoint string
+	traceFile     string
+	traceService  string
+}{}
+
+func DescribeParameters(config config.Config) {
+	config.StringVar(&params.address, "metrics-address", "", "host:port of a StatsD, Graphite or InfluxDB listener to push sample metrics to (disabled if empty)")
+	config.StringVar(&params.protocol, "metrics-protocol", "statsd", "Metrics protocol, one of 'statsd', 'graphite' or 'influxdb'")
+	config.StringVar(&params.network, "metrics-network", "udp", "Network used to push metrics, 'udp' or 'tcp'")
+	config.StringVar(&params.prefix, "metrics-prefix", "pat", "Prefix (or measurement name, for influxdb) of every pushed metric")
+	config.StringVar(&params.traceEndpoint, "trace-endpoint", "", "OTLP/HTTP traces URL of an OpenTelemetry collector to export each iteration's trace to, e.g. http://localhost:4318/v1/traces (disabled if empty)")
+	config.StringVar(&params.traceFile, "trace-file", "", "file to append each iteration's trace to, as a line of OTLP/JSON (disabled if empty)")
+	config.StringVar(&params.traceService, "trace-service-name", "pat", "service.name of the exported traces")
+}
+
+// Returns the handlers which should be passed to Laboratory.RunWithHandlers
+// to push the samples of an experiment to the configured metrics sink, and
+// to export its iterations' traces. Iterations are only given trace ids
+// (see benchmarker.TraceIterations) once traces are exported.
+func Handlers() ([]func(<-chan *experiment.Sample), error) {
+	handlers := make([]func(<-chan *experiment.Sample), 0)
+	if params.address != "" {
+		sink, err := SinkFactory(params.protocol, params.network, params.address, params.prefix)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, sink.Write)
+	}
+
+	if params.traceEndpoint != "" || params.traceFile != "" {
+		exporter, err := TraceExporterFactory(params.traceEndpoint, params.traceFile, params.traceService)
+		if err != nil {
+			return nil, err
+		}
+		benchmarker.TraceIterations = true
+		handlers = append(handlers, exporter.Write)
+	}
+
+	return handlers, nil
+}
+
+var SinkFactory = func(protocol string, network string, address string, prefix string) (*Sink, error) {
+	return NewSink(protocol, network, address, prefix)
+}
+
+var TraceExporterFactory = func(endpoint string, file string, service string) (*TraceExporter, error) {
+	return NewTraceExporter(endpoint, file, service)
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/experiment"
+)
+
+type formatter func(prefix string, metric string, command string, value time.Duration, at time.Time) string
+
+type errorFormatter func(prefix string, count int, at time.Time) string
+
+var formatters = map[string]formatter{
+	"statsd":   statsd,
+	"graphite": graphite,
+	"influxdb": influxdb,
+}
+
+var errorFormatters = map[string]errorFormatter{
+	"statsd":   statsdErrors,
+	"graphite": graphiteErrors,
+	"influxdb": influxdbErrors,
+}
+
+type Sink struct {
+	conn         net.Conn
+	format       formatter
+	formatErrors errorFormatter
+	prefix       string
+}
+
+func NewSink(protocol string, network string, address string, prefix string) (*Sink, error) {
+	format, ok := formatters[protocol]
+	if !ok {
+		return nil, errors.New("Unknown metrics protocol: " + protocol)
+	}
+
+	if network != "udp" && network != "tcp" {
+		return nil, errors.New("Unknown metrics network: " + network)
+	}
+
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sink{conn, format, errorFormatters[protocol], prefix}, nil
+}
+
+// Pushes the timing of every new iteration, and of every step run as part
+// of it, as the samples arrive, along with how many iterations have failed
+// since the last sample. Heartbeat and worker samples are skipped.
+func (s *Sink) Write(samples <-chan *experiment.Sample) {
+	defer s.conn.Close()
+
+	var total int64
+	var totalErrors int
+	counts := make(map[string]int64)
+	for sample := range samples {
+		if sample.Type != experiment.ResultSample || sample.Total == total {
+			continue
+		}
+
+		now := time.Now()
+		total = sample.Total
+		s.send(s.format(s.prefix, "iteration", "", sample.LastResult, now))
+		for name, command := range sample.Commands {
+			if command.Count > counts[name] {
+				counts[name] = command.Count
+				s.send(s.format(s.prefix, "step", name, command.LastTime, now))
+			}
+		}
+
+		if sample.TotalErrors > totalErrors {
+			s.send(s.formatErrors(s.prefix, sample.TotalErrors-totalErrors, now))
+			totalErrors = sample.TotalErrors
+		}
+	}
+}
+
+func (s *Sink) send(line string) {
+	if _, err := s.conn.Write([]byte(line)); err != nil {
+		fmt.Println("Can't push metrics: ", err)
+	}
+}
+
+func statsd(prefix string, metric string, command string, value time.Duration, at time.Time) string {
+	return fmt.Sprintf("%s:%s|ms\n", metricPath(prefix, metric, command), millis(value))
+}
+
+func statsdErrors(prefix string, count int, at time.Time) string {
+	return fmt.Sprintf("%s.errors:%d|c\n", prefix, count)
+}
+
+func graphite(prefix string, metric string, command string, value time.Duration, at time.Time) string {
+	return fmt.Sprintf("%s %s %d\n", metricPath(prefix, metric, command), millis(value), at.Unix())
+}
+
+func graphiteErrors(prefix string, count int, at time.Time) string {
+	return fmt.Sprintf("%s.errors %d %d\n", prefix, count, at.Unix())
+}
+
+func influxdb(prefix string, metric string, command string, value time.Duration, at time.Time) string {
+	tags := "type=" + metric
+	if command != "" {
+		tags = tags + ",command=" + influxEscaper.Replace(command)
+	}
+
+	return fmt.Sprintf("%s,%s duration_ms=%s %d\n", influxEscaper.Replace(prefix), tags, millis(value), at.UnixNano())
+}
+
+func influxdbErrors(prefix string, count int, at time.Time) string {
+	return fmt.Sprintf("%s,type=error count=%di %d\n", influxEscaper.Replace(prefix), count, at.UnixNano())
+}
+
+var pathEscaper = strings.NewReplacer(":", "_", ".", "_", " ", "_", "|", "_")
+var influxEscaper = strings.NewReplacer(",", "\\,", " ", "\\ ", "=", "\\=")
+
+func metricPath(prefix string, metric string, command string) string {
+	if command == "" {
+		return prefix + "." + metric
+	}
+
+	return prefix + "." + metric + "." + pathEscaper.Replace(command)
+}
+
+func millis(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d.Nanoseconds())/float64(time.Millisecond))
+}
@@ -0,0 +1,221 @@
+package metrics_test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/config"
+	"github.com/cloudfoundry-community/pat/experiment"
+	. "github.com/cloudfoundry-community/pat/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics Sink", func() {
+	var (
+		samples []*experiment.Sample
+	)
+
+	BeforeEach(func() {
+		samples = []*experiment.Sample{
+			&experiment.Sample{Type: experiment.WorkerSample},
+			&experiment.Sample{Type: experiment.ResultSample, Total: 1, LastResult: 3 * time.Second, Commands: map[string]experiment.Command{
+				"rest:push": experiment.Command{Count: 1, LastTime: 2 * time.Second},
+			}},
+			&experiment.Sample{Type: experiment.OtherSample, Total: 1, LastResult: 3 * time.Second},
+			&experiment.Sample{Type: experiment.ResultSample, Total: 2, TotalErrors: 1, LastResult: 1500 * time.Millisecond, Commands: map[string]experiment.Command{
+				"rest:push":  experiment.Command{Count: 1, LastTime: 2 * time.Second},
+				"rest:login": experiment.Command{Count: 1, LastTime: 250 * time.Millisecond},
+			}},
+		}
+	})
+
+	Describe("Pushing to a UDP listener", func() {
+		var (
+			listener *net.UDPConn
+			received chan string
+		)
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			received = make(chan string, 100)
+			go func() {
+				buf := make([]byte, 1024)
+				for {
+					n, _, err := listener.ReadFromUDP(buf)
+					if err != nil {
+						return
+					}
+					received <- string(buf[:n])
+				}
+			}()
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("sends iteration and step timings in the StatsD format", func() {
+			sink, err := NewSink("statsd", "udp", listener.LocalAddr().String(), "pat")
+			Ω(err).ShouldNot(HaveOccurred())
+			write(sink, samples)
+
+			Ω(lines(received, 5)).Should(ConsistOf(
+				"pat.iteration:3000.000|ms",
+				"pat.step.rest_push:2000.000|ms",
+				"pat.iteration:1500.000|ms",
+				"pat.step.rest_login:250.000|ms",
+				"pat.errors:1|c",
+			))
+		})
+
+		It("counts every iteration which failed since the last sample", func() {
+			sink, err := NewSink("statsd", "udp", listener.LocalAddr().String(), "pat")
+			Ω(err).ShouldNot(HaveOccurred())
+			write(sink, []*experiment.Sample{
+				&experiment.Sample{Type: experiment.ResultSample, Total: 3, TotalErrors: 3, LastResult: time.Second},
+				&experiment.Sample{Type: experiment.ResultSample, Total: 4, TotalErrors: 4, LastResult: time.Second},
+			})
+
+			Ω(lines(received, 4)).Should(ConsistOf(
+				"pat.iteration:1000.000|ms",
+				"pat.errors:3|c",
+				"pat.iteration:1000.000|ms",
+				"pat.errors:1|c",
+			))
+		})
+
+		It("sends iteration and step timings in the InfluxDB line protocol", func() {
+			sink, err := NewSink("influxdb", "udp", listener.LocalAddr().String(), "pat")
+			Ω(err).ShouldNot(HaveOccurred())
+			write(sink, samples[:2])
+
+			got := lines(received, 2)
+			Ω(got[0]).Should(HavePrefix("pat,type=iteration duration_ms=3000.000 "))
+			Ω(got[1]).Should(HavePrefix("pat,type=step,command=rest:push duration_ms=2000.000 "))
+		})
+	})
+
+	Describe("Pushing to a TCP listener", func() {
+		var (
+			listener net.Listener
+			received chan string
+		)
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.Listen("tcp", "127.0.0.1:0")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			received = make(chan string, 100)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					received <- scanner.Text()
+				}
+			}()
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("sends iteration and step timings in the Graphite plaintext format", func() {
+			sink, err := NewSink("graphite", "tcp", listener.Addr().String(), "pat.ci")
+			Ω(err).ShouldNot(HaveOccurred())
+			write(sink, samples[:2])
+
+			got := lines(received, 2)
+			Ω(got[0]).Should(MatchRegexp(`^pat\.ci\.iteration 3000\.000 \d+$`))
+			Ω(got[1]).Should(MatchRegexp(`^pat\.ci\.step\.rest_push 2000\.000 \d+$`))
+		})
+	})
+
+	Describe("Creating a sink", func() {
+		It("rejects unknown protocols", func() {
+			_, err := NewSink("carbon-copy", "udp", "127.0.0.1:1", "pat")
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("rejects unknown networks", func() {
+			_, err := NewSink("statsd", "unix", "127.0.0.1:1", "pat")
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	Describe("Handlers", func() {
+		var (
+			flags       config.Config
+			sinkFactory func(string, string, string, string) (*Sink, error)
+		)
+
+		BeforeEach(func() {
+			sinkFactory = SinkFactory
+			flags = config.NewConfig()
+			DescribeParameters(flags)
+		})
+
+		AfterEach(func() {
+			SinkFactory = sinkFactory
+		})
+
+		It("returns no handlers when no metrics address is configured", func() {
+			flags.Parse([]string{})
+			handlers, err := Handlers()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(handlers).Should(BeEmpty())
+		})
+
+		It("returns a handler for the configured sink", func() {
+			var protocol, network, address, prefix string
+			SinkFactory = func(p string, n string, a string, pre string) (*Sink, error) {
+				protocol, network, address, prefix = p, n, a, pre
+				return nil, nil
+			}
+
+			flags.Parse([]string{"-metrics-address", "relay:2003", "-metrics-protocol", "graphite", "-metrics-network", "tcp"})
+			handlers, err := Handlers()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(handlers).Should(HaveLen(1))
+			Ω(protocol).Should(Equal("graphite"))
+			Ω(network).Should(Equal("tcp"))
+			Ω(address).Should(Equal("relay:2003"))
+			Ω(prefix).Should(Equal("pat"))
+		})
+	})
+})
+
+func write(sink *Sink, samples []*experiment.Sample) {
+	ch := make(chan *experiment.Sample)
+	go func() {
+		for _, s := range samples {
+			ch <- s
+		}
+		close(ch)
+	}()
+	sink.Write(ch)
+}
+
+func lines(received chan string, n int) []string {
+	got := make([]string, 0)
+	Eventually(func() int {
+		for {
+			select {
+			case l := <-received:
+				got = append(got, strings.Split(strings.TrimSpace(l), "\n")...)
+			default:
+				return len(got)
+			}
+		}
+	}).Should(BeNumerically(">=", n))
+	return got
+}
@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/benchmarker"
+	"github.com/cloudfoundry-community/pat/experiment"
+	"github.com/cloudfoundry-community/pat/workloads"
+)
+
+// Exports each iteration as an OpenTelemetry trace, in the OTLP/JSON
+// encoding, either by POSTing it to an OTLP/HTTP collector or by appending it
+// as a line to a file. The iteration is the root span, each step a child of
+// it, and each HTTP request a child of its step whose id was sent to the
+// server in the traceparent header.
+type TraceExporter struct {
+	endpoint string
+	out      io.WriteCloser
+	service  string
+	client   *http.Client
+}
+
+func NewTraceExporter(endpoint string, file string, service string) (*TraceExporter, error) {
+	if endpoint != "" && file != "" {
+		return nil, errors.New("Only one of trace-endpoint and trace-file can be given")
+	}
+
+	exporter := &TraceExporter{endpoint: endpoint, service: service, client: &http.Client{Timeout: 10 * time.Second}}
+	if file != "" {
+		out, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		exporter.out = out
+	}
+
+	return exporter, nil
+}
+
+// Exports the trace of every new iteration as the samples arrive. Iterations
+// which ran without TraceIterations set have no trace id and are skipped.
+func (t *TraceExporter) Write(samples <-chan *experiment.Sample) {
+	if t.out != nil {
+		defer t.out.Close()
+	}
+
+	var total int64
+	var totalErrors int
+	for sample := range samples {
+		if sample.Type != experiment.ResultSample || sample.Total == total {
+			continue
+		}
+
+		total = sample.Total
+		failed := sample.TotalErrors > totalErrors
+		totalErrors = sample.TotalErrors
+		if len(sample.LastSteps) == 0 || sample.LastSteps[0].TraceId == "" {
+			continue
+		}
+
+		if err := t.export(Spans(sample.LastSteps, sample.LastResult, failed)); err != nil {
+			fmt.Println("Can't export traces: ", err)
+		}
+	}
+}
+
+func (t *TraceExporter) export(spans []Span) error {
+	body, err := json.Marshal(t.document(spans))
+	if err != nil {
+		return err
+	}
+
+	if t.out != nil {
+		_, err = t.out.Write(append(body, '\n'))
+		return err
+	}
+
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return errors.New("Collector replied " + resp.Status)
+	}
+
+	return nil
+}
+
+// A span in the OTLP/JSON encoding. Times are nanoseconds since the epoch,
+// encoded as strings.
+type Span struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []SpanAttribute `json:"attributes,omitempty"`
+	Status            SpanStatus      `json:"status"`
+}
+
+type SpanAttribute struct {
+	Key   string         `json:"key"`
+	Value AttributeValue `json:"value"`
+}
+
+type AttributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type SpanStatus struct {
+	Code int `json:"code,omitempty"`
+}
+
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+	statusError      = 2
+)
+
+// The spans of one iteration. The iteration starts with its first step; if it
+// failed, the last step run is the one which failed.
+func Spans(steps []benchmarker.StepResult, duration time.Duration, failed bool) []Span {
+	first := steps[0]
+	spans := []Span{span(first.TraceId, first.ParentId, "", "iteration", spanKindInternal, first.Start, duration, failed,
+		stringAttribute("pat.steps", stepNames(steps)))}
+
+	for i, step := range steps {
+		spans = append(spans, span(step.TraceId, step.SpanId, step.ParentId, step.Command, spanKindInternal, step.Start, step.Duration, failed && i == len(steps)-1))
+		for _, request := range step.Requests {
+			spans = append(spans, requestSpan(step, request))
+		}
+	}
+
+	return spans
+}
+
+func requestSpan(step benchmarker.StepResult, request workloads.RequestTrace) Span {
+	attributes := []SpanAttribute{
+		stringAttribute("http.request.method", request.Method),
+		stringAttribute("url.full", request.Url),
+	}
+	if request.Status != 0 {
+		attributes = append(attributes, intAttribute("http.response.status_code", request.Status))
+	}
+	if request.RequestId != "" {
+		attributes = append(attributes, stringAttribute("cloudfoundry.request_id", request.RequestId))
+	}
+
+	failed := request.Status == 0 || request.Status >= 400
+	return span(step.TraceId, request.SpanId, step.SpanId, request.Method, spanKindClient, request.Start, request.Total, failed, attributes...)
+}
+
+func span(traceId string, spanId string, parentId string, name string, kind int, start time.Time, duration time.Duration, failed bool, attributes ...SpanAttribute) Span {
+	s := Span{
+		TraceId:           traceId,
+		SpanId:            spanId,
+		ParentSpanId:      parentId,
+		Name:              name,
+		Kind:              kind,
+		StartTimeUnixNano: strconv.FormatInt(start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(start.Add(duration).UnixNano(), 10),
+		Attributes:        attributes,
+	}
+	if failed {
+		s.Status.Code = statusError
+	}
+
+	return s
+}
+
+func stepNames(steps []benchmarker.StepResult) string {
+	names := ""
+	for i, step := range steps {
+		if i > 0 {
+			names += ","
+		}
+		names += step.Command
+	}
+
+	return names
+}
+
+func stringAttribute(key string, value string) SpanAttribute {
+	return SpanAttribute{key, AttributeValue{StringValue: &value}}
+}
+
+func intAttribute(key string, value int) SpanAttribute {
+	s := strconv.Itoa(value)
+	return SpanAttribute{key, AttributeValue{IntValue: &s}}
+}
+
+func (t *TraceExporter) document(spans []Span) interface{} {
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []SpanAttribute{stringAttribute("service.name", t.service)},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "pat"},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+}
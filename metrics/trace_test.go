@@ -0,0 +1,207 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/benchmarker"
+	"github.com/cloudfoundry-community/pat/config"
+	"github.com/cloudfoundry-community/pat/experiment"
+	. "github.com/cloudfoundry-community/pat/metrics"
+	"github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Trace Exporter", func() {
+	var (
+		start   time.Time
+		steps   []benchmarker.StepResult
+		samples []*experiment.Sample
+	)
+
+	BeforeEach(func() {
+		start = time.Unix(1000, 0)
+		steps = []benchmarker.StepResult{
+			{Command: "rest:target", Duration: time.Second, Start: start, TraceId: "THE-TRACE", SpanId: "TARGET-SPAN", ParentId: "ITERATION-SPAN",
+				Requests: []workloads.RequestTrace{{Start: start, SpanId: "REQUEST-SPAN", Method: "GET", Url: "http://api/v2/info", Status: 200, RequestId: "abc", Total: time.Second}}},
+			{Command: "rest:push", Duration: 2 * time.Second, Start: start.Add(time.Second), TraceId: "THE-TRACE", SpanId: "PUSH-SPAN", ParentId: "ITERATION-SPAN"},
+		}
+		samples = []*experiment.Sample{
+			&experiment.Sample{Type: experiment.WorkerSample},
+			&experiment.Sample{Type: experiment.ResultSample, Total: 1, TotalErrors: 1, LastResult: 3 * time.Second, LastSteps: steps},
+			&experiment.Sample{Type: experiment.OtherSample, Total: 1, TotalErrors: 1, LastResult: 3 * time.Second, LastSteps: steps},
+		}
+	})
+
+	Describe("The spans of an iteration", func() {
+		It("Has the iteration as the root span, with a span for each step and request", func() {
+			spans := Spans(steps, 3*time.Second, false)
+			Ω(spans).Should(HaveLen(4))
+
+			Ω(spans[0].Name).Should(Equal("iteration"))
+			Ω(spans[0].SpanId).Should(Equal("ITERATION-SPAN"))
+			Ω(spans[0].ParentSpanId).Should(BeEmpty())
+			Ω(spans[0].StartTimeUnixNano).Should(Equal("1000000000000"))
+			Ω(spans[0].EndTimeUnixNano).Should(Equal("1003000000000"))
+
+			Ω(spans[1].Name).Should(Equal("rest:target"))
+			Ω(spans[1].ParentSpanId).Should(Equal("ITERATION-SPAN"))
+
+			Ω(spans[2].Name).Should(Equal("GET"))
+			Ω(spans[2].SpanId).Should(Equal("REQUEST-SPAN"))
+			Ω(spans[2].ParentSpanId).Should(Equal("TARGET-SPAN"))
+			Ω(*spans[2].Attributes[2].Value.IntValue).Should(Equal("200"))
+
+			Ω(spans[3].Name).Should(Equal("rest:push"))
+			Ω(spans[3].StartTimeUnixNano).Should(Equal("1001000000000"))
+			for _, span := range spans {
+				Ω(span.TraceId).Should(Equal("THE-TRACE"))
+				Ω(span.Status.Code).Should(BeZero())
+			}
+		})
+
+		It("Marks the iteration and its last step as errors when it failed", func() {
+			spans := Spans(steps, 3*time.Second, true)
+			Ω(spans[0].Status.Code).Should(Equal(2))
+			Ω(spans[1].Status.Code).Should(BeZero())
+			Ω(spans[3].Status.Code).Should(Equal(2))
+		})
+	})
+
+	Describe("Exporting to a collector", func() {
+		var (
+			collector *httptest.Server
+			received  chan map[string]interface{}
+		)
+
+		BeforeEach(func() {
+			received = make(chan map[string]interface{}, 10)
+			collector = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Ω(r.URL.Path).Should(Equal("/v1/traces"))
+				Ω(r.Header.Get("Content-Type")).Should(Equal("application/json"))
+				var body map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&body)
+				received <- body
+			}))
+		})
+
+		AfterEach(func() {
+			collector.Close()
+		})
+
+		It("POSTs each new iteration's spans as OTLP/JSON", func() {
+			exporter, err := NewTraceExporter(collector.URL+"/v1/traces", "", "my-pat")
+			Ω(err).ShouldNot(HaveOccurred())
+			exportAll(exporter, samples)
+
+			Ω(received).Should(HaveLen(1))
+			body := <-received
+			resource := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+			Ω(resource["resource"]).Should(Equal(map[string]interface{}{
+				"attributes": []interface{}{map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "my-pat"}}},
+			}))
+			spans := resource["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+			Ω(spans).Should(HaveLen(4))
+			Ω(spans[0].(map[string]interface{})["status"]).Should(Equal(map[string]interface{}{"code": float64(2)}))
+		})
+
+		It("Skips iterations which were not traced", func() {
+			samples[1].LastSteps = []benchmarker.StepResult{{Command: "rest:target"}}
+			exporter, _ := NewTraceExporter(collector.URL+"/v1/traces", "", "pat")
+			exportAll(exporter, samples)
+			Ω(received).Should(BeEmpty())
+		})
+	})
+
+	Describe("Exporting to a file", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "traces")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("Appends a line of OTLP/JSON for each iteration", func() {
+			file := path.Join(dir, "traces.json")
+			for i := 0; i < 2; i++ {
+				exporter, err := NewTraceExporter("", file, "pat")
+				Ω(err).ShouldNot(HaveOccurred())
+				exportAll(exporter, samples)
+			}
+
+			contents, err := ioutil.ReadFile(file)
+			Ω(err).ShouldNot(HaveOccurred())
+			lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+			Ω(lines).Should(HaveLen(2))
+			Ω(lines[0]).Should(ContainSubstring(`"traceId":"THE-TRACE"`))
+		})
+	})
+
+	Describe("Creating an exporter", func() {
+		It("Rejects both an endpoint and a file", func() {
+			_, err := NewTraceExporter("http://collector:4318/v1/traces", "traces.json", "pat")
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	Describe("Handlers", func() {
+		var (
+			flags                config.Config
+			traceExporterFactory func(string, string, string) (*TraceExporter, error)
+		)
+
+		BeforeEach(func() {
+			traceExporterFactory = TraceExporterFactory
+			flags = config.NewConfig()
+			DescribeParameters(flags)
+		})
+
+		AfterEach(func() {
+			TraceExporterFactory = traceExporterFactory
+			benchmarker.TraceIterations = false
+		})
+
+		It("Does not trace iterations when no trace endpoint or file is configured", func() {
+			flags.Parse([]string{})
+			Handlers()
+			Ω(benchmarker.TraceIterations).Should(BeFalse())
+		})
+
+		It("Returns a handler exporting the traces, and traces iterations", func() {
+			var endpoint, service string
+			TraceExporterFactory = func(e string, f string, s string) (*TraceExporter, error) {
+				endpoint, service = e, s
+				return &TraceExporter{}, nil
+			}
+
+			flags.Parse([]string{"-trace-endpoint", "http://collector:4318/v1/traces"})
+			handlers, err := Handlers()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(handlers).Should(HaveLen(1))
+			Ω(endpoint).Should(Equal("http://collector:4318/v1/traces"))
+			Ω(service).Should(Equal("pat"))
+			Ω(benchmarker.TraceIterations).Should(BeTrue())
+		})
+	})
+})
+
+func exportAll(exporter *TraceExporter, samples []*experiment.Sample) {
+	ch := make(chan *experiment.Sample)
+	go func() {
+		for _, s := range samples {
+			ch <- s
+		}
+		close(ch)
+	}()
+	exporter.Write(ch)
+}
@@ -6,12 +6,15 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/cloudfoundry-community/pat/benchmarker"
+	"github.com/cloudfoundry-community/pat/chaos"
 	"github.com/cloudfoundry-community/pat/config"
 	. "github.com/cloudfoundry-community/pat/experiment"
 	. "github.com/cloudfoundry-community/pat/laboratory"
+	"github.com/cloudfoundry-community/pat/metrics"
 	"github.com/cloudfoundry-community/pat/store"
 	"github.com/cloudfoundry-community/pat/workloads"
 )
@@ -26,22 +29,26 @@ type Response struct {
 }
 
 type context struct {
-	router *mux.Router
-	lab    Laboratory
+	router    *mux.Router
+	lab       Laboratory
+	scheduler *Scheduler
 }
 
 var params = struct {
-	port string
+	port          string
+	maxConcurrent int
 }{}
 
 func InitCommandLineFlags(config config.Config) {
 	config.EnvVar(&params.port, "VCAP_APP_PORT", "8080", "The port to bind to")
+	config.IntVar(&params.maxConcurrent, "max-concurrent-experiments", 1, "maximum number of experiments to run at once in server mode, others are queued (0 for no limit)")
 	store.DescribeParameters(config)
+	metrics.DescribeParameters(config)
 }
 
 func Serve() {
 	err := store.WithStore(func(store Store) error {
-		ServeWithLab(NewLaboratory(store))
+		ServeWithLab(NewQueuedLaboratory(store, params.maxConcurrent))
 		return nil
 	})
 
@@ -52,12 +59,17 @@ func Serve() {
 
 func ServeWithLab(lab Laboratory) {
 	r := mux.NewRouter()
-	ctx := &context{r, lab}
+	ctx := &context{r, lab, NewScheduler(lab, metrics.Handlers)}
+	go ctx.scheduler.Start(make(chan bool))
 
 	r.Methods("GET").Path("/experiments/").HandlerFunc(handler(ctx.handleListExperiments))
 	r.Methods("GET").Path("/experiments/{name}.csv").HandlerFunc(csvHandler(ctx.handleGetExperiment)).Name("csv")
 	r.Methods("GET").Path("/experiments/{name}").HandlerFunc(handler(ctx.handleGetExperiment)).Name("experiment")
 	r.Methods("POST").Path("/experiments/").HandlerFunc(handler(ctx.handlePush))
+	r.Methods("DELETE").Path("/experiments/{name}").HandlerFunc(handler(ctx.handleCancelExperiment))
+	r.Methods("GET").Path("/schedules/").HandlerFunc(handler(ctx.handleListSchedules))
+	r.Methods("POST").Path("/schedules/").HandlerFunc(handler(ctx.handleAddSchedule))
+	r.Methods("DELETE").Path("/schedules/{id}").HandlerFunc(handler(ctx.handleRemoveSchedule)).Name("schedule")
 	r.Methods("GET").Path("/").HandlerFunc(redirectBase)
 	http.Handle("/ui/", http.StripPrefix("/ui/", http.FileServer(http.Dir("ui"))))
 	http.Handle("/", r)
@@ -90,6 +102,9 @@ func (ctx *context) handleListExperiments(w http.ResponseWriter, r *http.Request
 		json["CsvLocation"] = csvUrl.String()
 		json["Name"] = "Simple Push (" + e.GetGuid() + ")"
 		json["State"] = "Unknown"
+		if s, ok := e.(Stateful); ok {
+			json["State"] = s.GetState()
+		}
 		experiments = append(experiments, json)
 	})
 
@@ -97,26 +112,50 @@ func (ctx *context) handleListExperiments(w http.ResponseWriter, r *http.Request
 }
 
 func (ctx *context) handlePush(w http.ResponseWriter, r *http.Request) (interface{}, error) {
-	pushes, err := strconv.Atoi(r.FormValue("iterations"))
+	ex, err := experimentFromForm(r.FormValue)
+	if err != nil {
+		return nil, err
+	}
+
+	handlers, err := metrics.Handlers()
+	if err != nil {
+		return nil, err
+	}
+
+	experiment, err := ctx.lab.RunWithHandlers(ex, handlers)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.router.Get("experiment").URL("name", experiment.GetGuid())
+}
+
+// An error in the request itself, replied to with 400 Bad Request.
+type badRequest struct {
+	error
+}
+
+func experimentFromForm(formValue func(key string) string) (*RunnableExperiment, error) {
+	pushes, err := strconv.Atoi(formValue("iterations"))
 	if err != nil {
 		pushes = 1
 	}
 
-	concurrency, err := strconv.Atoi(r.FormValue("concurrency"))
+	concurrency, err := strconv.Atoi(formValue("concurrency"))
 	if err != nil {
 		concurrency = 1
 	}
 
-	interval, err := strconv.Atoi(r.FormValue("interval"))
+	interval, err := strconv.Atoi(formValue("interval"))
 	if err != nil {
 		interval = 0
 	}
-	stop, err := strconv.Atoi(r.FormValue("stop"))
+	stop, err := strconv.Atoi(formValue("stop"))
 	if err != nil {
 		stop = 0
 	}
 
-	workload := r.FormValue("workload")
+	workload := formValue("workload")
 	if workload == "" {
 		workload = "push"
 	}
@@ -124,14 +163,103 @@ func (ctx *context) handlePush(w http.ResponseWriter, r *http.Request) (interfac
 	//ToDo (simon): interval and stop is 0, repeating at interval is not yet exposed in Web UI
 	workloadList := workloads.DefaultWorkloadList()
 	worker := benchmarker.NewWorker()
-	workloadList.DescribeWorkloads(worker)
+	if err := workloadList.DescribeWorkloads(worker); err != nil {
+		return nil, err
+	}
 
-	experiment, _ := ctx.lab.Run(
-		NewRunnableExperiment(
-			NewExperimentConfiguration(
-				pushes, concurrency, interval, stop, worker, workload)))
+	experiment := NewExperimentConfiguration(
+		pushes, concurrency, interval, stop, worker, workload)
+	for _, fixture := range workloadList.Fixtures() {
+		experiment.Fixtures = append(experiment.Fixtures, fixture)
+	}
+	if experiment.ThinkTime, err = benchmarker.ParseDelay(formValue("thinkTime")); err != nil {
+		return nil, badRequest{fmt.Errorf("Invalid thinkTime: %s", err)}
+	}
+	if experiment.Pacing, err = benchmarker.ParseDelay(formValue("pacing")); err != nil {
+		return nil, badRequest{fmt.Errorf("Invalid pacing: %s", err)}
+	}
+	if experiment.WarmupIterations, err = optionalInt(formValue("warmupIterations")); err != nil {
+		return nil, badRequest{fmt.Errorf("Invalid warmupIterations: %s", err)}
+	}
+	if experiment.WarmupTime, err = optionalInt(formValue("warmupTime")); err != nil {
+		return nil, badRequest{fmt.Errorf("Invalid warmupTime: %s", err)}
+	}
+	if experiment.Hooks, err = chaos.Hooks(); err != nil {
+		return nil, err
+	}
+	return NewRunnableExperiment(experiment), nil
+}
 
-	return ctx.router.Get("experiment").URL("name", experiment.GetGuid())
+// An unset value is 0; anything else must be a whole number.
+func optionalInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+func (ctx *context) handleCancelExperiment(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	name := mux.Vars(r)["name"]
+	if err := ctx.lab.Cancel(name); err != nil {
+		return nil, err
+	}
+
+	return ctx.router.Get("experiment").URL("name", name)
+}
+
+func (ctx *context) handleListSchedules(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	schedules := make([]map[string]string, 0)
+	ctx.scheduler.Visit(func(s Schedule) {
+		json := make(map[string]string)
+		url, _ := ctx.router.Get("schedule").URL("id", s.Id)
+		json["Location"] = url.String()
+		json["Name"] = s.Name
+		json["Cron"] = s.Cron
+		json["Next"] = s.Next.Format(time.RFC3339)
+		if s.LastRun != "" {
+			last, _ := ctx.router.Get("experiment").URL("name", s.LastRun)
+			json["LastRun"] = last.String()
+		}
+		if s.LastError != "" {
+			json["LastError"] = s.LastError
+		}
+		schedules = append(schedules, json)
+	})
+
+	return &listResponse{schedules}, nil
+}
+
+func (ctx *context) handleAddSchedule(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	name := r.FormValue("name")
+	if name == "" {
+		name = r.FormValue("workload")
+	}
+
+	if _, err := experimentFromForm(r.FormValue); err != nil {
+		return nil, err
+	}
+
+	// The form was checked above, so building the experiment cannot fail.
+	form := r.Form
+	schedule, err := ctx.scheduler.Add(name, r.FormValue("cron"), func() Runnable {
+		ex, _ := experimentFromForm(form.Get)
+		return ex
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.router.Get("schedule").URL("id", schedule.Id)
+}
+
+func (ctx *context) handleRemoveSchedule(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	id := mux.Vars(r)["id"]
+	if err := ctx.scheduler.Remove(id); err != nil {
+		return nil, err
+	}
+
+	return &listResponse{[]string{}}, nil
 }
 
 func (ctx *context) handleGetExperiment(w http.ResponseWriter, r *http.Request) (interface{}, error) {
@@ -175,7 +303,9 @@ func handler(fn func(http.ResponseWriter, *http.Request) (interface{}, error)) h
 			}
 		}
 
-		if err != nil {
+		if _, ok := err.(badRequest); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	}
@@ -2,16 +2,20 @@ package server_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/cloudfoundry-community/pat/chaos"
 	"github.com/cloudfoundry-community/pat/config"
 	. "github.com/cloudfoundry-community/pat/experiment"
 	. "github.com/cloudfoundry-community/pat/laboratory"
+	"github.com/cloudfoundry-community/pat/metrics"
 	. "github.com/cloudfoundry-community/pat/server"
 	"github.com/cloudfoundry-community/pat/store"
 	. "github.com/onsi/ginkgo"
@@ -144,6 +148,88 @@ var _ = Describe("Server", func() {
 		Ω(lab.config.Stop).Should(Equal(3))
 	})
 
+	It("Supports 'thinkTime' and 'pacing' parameters", func() {
+		post("/experiments/?thinkTime=2s&pacing=exponential:1s")
+		Ω(lab.config.ThinkTime()).Should(Equal(2 * time.Second))
+		Ω(lab.config.Pacing).ShouldNot(BeNil())
+	})
+
+	It("Rejects an invalid 'thinkTime' or 'pacing'", func() {
+		Ω(status("POST", "/experiments/?thinkTime=soon")).Should(Equal(http.StatusBadRequest))
+		Ω(status("POST", "/experiments/?pacing=gaussian:1s")).Should(Equal(http.StatusBadRequest))
+		Ω(status("POST", "/schedules/?cron=@daily&thinkTime=soon")).Should(Equal(http.StatusBadRequest))
+		Ω(lab.config).Should(BeNil())
+	})
+
+	It("Supports 'warmupIterations' and 'warmupTime' parameters", func() {
+		post("/experiments/?warmupIterations=2&warmupTime=30")
+		Ω(lab.config.WarmupIterations).Should(Equal(2))
+		Ω(lab.config.WarmupTime).Should(Equal(30))
+	})
+
+	Context("When metrics are pushed to a sink", func() {
+		var (
+			sinks       int
+			sinkFactory func(string, string, string, string) (*metrics.Sink, error)
+		)
+
+		BeforeEach(func() {
+			sinks = 0
+			sinkFactory = metrics.SinkFactory
+			metrics.SinkFactory = func(p string, n string, a string, pre string) (*metrics.Sink, error) {
+				sinks++
+				return nil, errors.New("unreachable")
+			}
+			flags := config.NewConfig()
+			metrics.DescribeParameters(flags)
+			Ω(flags.Parse([]string{"-metrics-address", "127.0.0.1:8125"})).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			metrics.SinkFactory = sinkFactory
+			metrics.DescribeParameters(config.NewConfig())
+		})
+
+		It("Does not connect to it for an invalid request", func() {
+			Ω(status("POST", "/experiments/?thinkTime=soon")).Should(Equal(http.StatusBadRequest))
+			Ω(sinks).Should(Equal(0))
+		})
+
+		It("Fails when it can not connect", func() {
+			Ω(status("POST", "/experiments/")).Should(Equal(http.StatusInternalServerError))
+			Ω(sinks).Should(Equal(1))
+		})
+	})
+
+	It("Replies with an error when the laboratory can not run the experiment", func() {
+		lab.err = errors.New("rejected")
+		Ω(status("POST", "/experiments/")).Should(Equal(http.StatusInternalServerError))
+	})
+
+	It("Rejects an invalid 'warmupIterations' or 'warmupTime'", func() {
+		Ω(status("POST", "/experiments/?warmupIterations=a+few")).Should(Equal(http.StatusBadRequest))
+		Ω(status("POST", "/experiments/?warmupTime=30s")).Should(Equal(http.StatusBadRequest))
+		Ω(lab.config).Should(BeNil())
+	})
+
+	Context("When the chaos configuration is invalid", func() {
+		BeforeEach(func() {
+			ioutil.WriteFile("/tmp/pat-chaos.yml", []byte("chaos:\n  - at: soon\n    command: \"true\"\n"), 0644)
+			flags := config.NewConfig()
+			chaos.DescribeParameters(flags)
+			Ω(flags.Parse([]string{"-config", "/tmp/pat-chaos.yml"})).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			chaos.DescribeParameters(config.NewConfig())
+		})
+
+		It("Fails to start an experiment", func() {
+			Ω(status("POST", "/experiments/")).Should(Equal(http.StatusInternalServerError))
+			Ω(lab.config).Should(BeNil())
+		})
+	})
+
 	It("Supports a 'workload' parameter", func() {
 		post("/experiments/?workload=flibble")
 		Ω(lab.config.Workload).Should(Equal("flibble"))
@@ -153,11 +239,48 @@ var _ = Describe("Server", func() {
 		json := post("/experiments/")
 		Ω(json["Location"]).Should(Equal("/experiments/some-guid"))
 	})
+
+	It("Cancels a queued experiment", func() {
+		json := decode(req("DELETE", "/experiments/b"))
+		Ω(lab.cancelled).Should(Equal("b"))
+		Ω(json["Location"]).Should(Equal("/experiments/b"))
+	})
+
+	Describe("Schedules", func() {
+		It("Adds a schedule and lists it", func() {
+			json := post("/schedules/?cron=0+2+*+*+*&name=nightly&iterations=3&workload=flibble")
+			Ω(json["Location"]).Should(HavePrefix("/schedules/"))
+
+			json = get("/schedules/")
+			Ω(json["Items"]).Should(HaveLen(1))
+			item := json["Items"].([]interface{})[0].(map[string]interface{})
+			Ω(item["Name"]).Should(Equal("nightly"))
+			Ω(item["Cron"]).Should(Equal("0 2 * * *"))
+			Ω(item["Next"]).ShouldNot(BeEmpty())
+		})
+
+		It("Rejects an invalid cron expression", func() {
+			resp := httptest.NewRecorder()
+			r, _ := http.NewRequest("POST", "/schedules/?cron=every+night", nil)
+			http.DefaultServeMux.ServeHTTP(resp, r)
+			Ω(resp.Code).Should(Equal(http.StatusInternalServerError))
+		})
+
+		It("Removes a schedule", func() {
+			json := post("/schedules/?cron=@daily")
+			req("DELETE", json["Location"].(string))
+
+			json = get("/schedules/")
+			Ω(json["Items"]).Should(BeEmpty())
+		})
+	})
 })
 
 type DummyLab struct {
 	experiments []*DummyExperiment
 	config      *RunnableExperiment
+	cancelled   string
+	err         error
 }
 
 type DummyExperiment struct {
@@ -165,21 +288,29 @@ type DummyExperiment struct {
 }
 
 func (l *DummyLab) RunWithHandlers(ex Runnable, fns []func(<-chan *Sample)) (Experiment, error) {
-	Fail("called unexpected dummy function")
-	return nil, nil
-}
+	if l.err != nil {
+		return nil, l.err
+	}
 
-func (l *DummyLab) Run(ex Runnable) (Experiment, error) {
 	l.config = ex.(*RunnableExperiment)
 	return &DummyExperiment{"some-guid"}, nil
 }
 
+func (l *DummyLab) Run(ex Runnable) (Experiment, error) {
+	return l.RunWithHandlers(ex, nil)
+}
+
 func (l *DummyLab) Visit(fn func(ex Experiment)) {
 	for _, e := range l.experiments {
 		fn(e)
 	}
 }
 
+func (l *DummyLab) Cancel(name string) error {
+	l.cancelled = name
+	return nil
+}
+
 func (l *DummyLab) GetData(name string) ([]*Sample, error) {
 	if name == "a" {
 		return []*Sample{&Sample{}, &Sample{}, &Sample{}}, nil
@@ -208,6 +339,13 @@ func decode(encoded []byte) (decoded map[string]interface{}) {
 	return decoded
 }
 
+func status(method string, url string) int {
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, url, nil)
+	http.DefaultServeMux.ServeHTTP(resp, req)
+	return resp.Code
+}
+
 func req(method string, url string) []byte {
 	resp := httptest.NewRecorder()
 	req, err := http.NewRequest(method, url, nil)
@@ -2,6 +2,7 @@ package store
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -55,10 +56,14 @@ func (self *csvFile) Write(samples <-chan *experiment.Sample) {
 	}
 
 	w := csv.NewWriter(f)
-	w.Write([]string{"Average", "TotalTime", "Total", "TotalErrors", "TotalWorkers", "LastResult", "WorstResult", "NinetyfifthPercentile", "WallTime", "Type"})
+	w.Write([]string{"Average", "TotalTime", "Total", "TotalErrors", "TotalWorkers", "LastResult", "WorstResult", "NinetyfifthPercentile", "WallTime", "Type", "LastSteps", "Warmup", "Scheduled", "Event"})
 
 	for s := range samples {
-		if s.Type == experiment.ResultSample {
+		if s.Type == experiment.ResultSample || s.Type == experiment.WarmupSample || s.Type == experiment.EventSample {
+			steps, _ := json.Marshal(s.LastSteps)
+			warmup, _ := json.Marshal(s.Warmup)
+			scheduled, _ := json.Marshal(s.Scheduled)
+			event, _ := json.Marshal(s.Event)
 			w.Write([]string{strconv.Itoa(int(s.Average.Nanoseconds())),
 				strconv.Itoa(int(s.TotalTime.Nanoseconds())),
 				strconv.Itoa(int(s.Total)),
@@ -68,7 +73,11 @@ func (self *csvFile) Write(samples <-chan *experiment.Sample) {
 				strconv.Itoa(int(s.WorstResult.Nanoseconds())),
 				strconv.Itoa(int(s.NinetyfifthPercentile.Nanoseconds())),
 				strconv.Itoa(int(s.WallTime)),
-				strconv.Itoa(int(s.Type))})
+				strconv.Itoa(int(s.Type)),
+				string(steps),
+				string(warmup),
+				string(scheduled),
+				string(event)})
 			w.Flush()
 		}
 	}
@@ -99,7 +108,22 @@ func (self *csvFile) GetData() (samples []*experiment.Sample, err error) {
 			sample.WorstResult, err = duration(d[6])
 			sample.NinetyfifthPercentile, err = duration(d[7])
 			sample.WallTime, err = duration(d[8])
-			sample.Type = experiment.ResultSample // this is the only type we currently persist
+			sample.Type = experiment.ResultSample
+			if len(d) > 13 {
+				err = json.Unmarshal([]byte(d[13]), &sample.Event)
+			}
+			if len(d) > 12 {
+				err = json.Unmarshal([]byte(d[12]), &sample.Scheduled)
+			}
+			if len(d) > 11 {
+				var sampleType int
+				sampleType, err = strconv.Atoi(d[9])
+				sample.Type = experiment.SampleType(sampleType)
+				err = json.Unmarshal([]byte(d[11]), &sample.Warmup)
+			}
+			if len(d) > 10 {
+				err = json.Unmarshal([]byte(d[10]), &sample.LastSteps)
+			}
 
 			if err != nil {
 				return nil, err
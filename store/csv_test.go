@@ -8,8 +8,10 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/cloudfoundry-community/pat/benchmarker"
 	"github.com/cloudfoundry-community/pat/experiment"
 	. "github.com/cloudfoundry-community/pat/store"
+	"github.com/cloudfoundry-community/pat/workloads"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -34,8 +36,8 @@ var _ = Describe("Csv Store", func() {
 			store = NewCsvStore(dir)
 			writer := store.Writer("foo")
 			write(writer, []*experiment.Sample{
-				&experiment.Sample{nil, 1, 2, 3, 4, 5, 6, nil, 7, 3, 8, experiment.ResultSample},
-				&experiment.Sample{nil, 9, 8, 7, 6, 5, 4, errors.New("foo"), 3, 7, 2, experiment.ResultSample},
+				&experiment.Sample{Average: 1, TotalTime: 2, Total: 3, TotalErrors: 4, TotalWorkers: 5, LastResult: 6, WorstResult: 7, NinetyfifthPercentile: 3, WallTime: 8, Type: experiment.ResultSample},
+				&experiment.Sample{Average: 9, TotalTime: 8, Total: 7, TotalErrors: 6, TotalWorkers: 5, LastResult: 4, LastError: errors.New("foo"), WorstResult: 3, NinetyfifthPercentile: 7, WallTime: 2, Type: experiment.ResultSample},
 			})
 			files, err := ioutil.ReadDir(dir)
 			Ω(err).ShouldNot(HaveOccurred())
@@ -72,7 +74,7 @@ var _ = Describe("Csv Store", func() {
 			samples, err := ex[0].GetData()
 			Ω(err).ShouldNot(HaveOccurred())
 
-			Ω(samples[0]).Should(Equal(&experiment.Sample{nil, 1, 2, 3, 4, 5, 6, nil, 7, 3, 8, experiment.ResultSample}))
+			Ω(samples[0]).Should(Equal(&experiment.Sample{Average: 1, TotalTime: 2, Total: 3, TotalErrors: 4, TotalWorkers: 5, LastResult: 6, WorstResult: 7, NinetyfifthPercentile: 3, WallTime: 8, Type: experiment.ResultSample}))
 		})
 
 		It("Does not save error text, to avoid huge files", func() {
@@ -84,18 +86,94 @@ var _ = Describe("Csv Store", func() {
 			Ω(samples[0].LastError).Should(BeNil())
 		})
 
+		Context("When samples include the steps of their iteration", func() {
+			JustBeforeEach(func() {
+				steps := []benchmarker.StepResult{benchmarker.StepResult{Command: "push", Duration: 3, Requests: []workloads.RequestTrace{{Method: "GET", Status: 200, RequestId: "abc"}}}}
+				write(store.Writer("steps"), []*experiment.Sample{
+					&experiment.Sample{Type: experiment.ResultSample, LastSteps: steps},
+				})
+			})
+
+			It("Round trips the steps and their requests", func() {
+				ex, err := store.LoadAll()
+				Ω(err).ShouldNot(HaveOccurred())
+				samples, err := ex[1].GetData()
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(samples[0].LastSteps).Should(HaveLen(1))
+				Ω(samples[0].LastSteps[0].Requests[0].RequestId).Should(Equal("abc"))
+			})
+		})
+
+		Context("When samples include a warm-up", func() {
+			JustBeforeEach(func() {
+				write(store.Writer("warmup"), []*experiment.Sample{
+					&experiment.Sample{Type: experiment.WarmupSample, Warmup: &experiment.Warmup{Total: 1, Average: 5}},
+					&experiment.Sample{Type: experiment.ResultSample, Total: 1, Warmup: &experiment.Warmup{Total: 1, Average: 5}},
+				})
+			})
+
+			It("Round trips the warm-up samples and statistics", func() {
+				ex, err := store.LoadAll()
+				Ω(err).ShouldNot(HaveOccurred())
+				samples, err := ex[1].GetData()
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(samples).Should(HaveLen(2))
+				Ω(samples[0].Type).Should(Equal(experiment.WarmupSample))
+				Ω(samples[1].Type).Should(Equal(experiment.ResultSample))
+				Ω(samples[1].Warmup).Should(Equal(&experiment.Warmup{Total: 1, Average: 5}))
+			})
+		})
+
+		Context("When samples include the schedule of their iteration", func() {
+			JustBeforeEach(func() {
+				write(store.Writer("schedule"), []*experiment.Sample{
+					&experiment.Sample{Type: experiment.WarmupSample, Scheduled: &benchmarker.Scheduled{Offset: 5, Worker: 1, Workload: "push"}},
+				})
+			})
+
+			It("Round trips the schedule", func() {
+				ex, err := store.LoadAll()
+				Ω(err).ShouldNot(HaveOccurred())
+				samples, err := ex[1].GetData()
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(samples[0].Scheduled).Should(Equal(&benchmarker.Scheduled{Offset: 5, Worker: 1, Workload: "push"}))
+			})
+		})
+
+		Context("When samples include events", func() {
+			JustBeforeEach(func() {
+				write(store.Writer("events"), []*experiment.Sample{
+					&experiment.Sample{Type: experiment.EventSample, Total: 2, WallTime: 5, Event: &experiment.Event{Name: "kill the api vm", Error: "exit status 1"}},
+				})
+			})
+
+			It("Round trips the events", func() {
+				ex, err := store.LoadAll()
+				Ω(err).ShouldNot(HaveOccurred())
+				samples, err := ex[1].GetData()
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(samples).Should(HaveLen(1))
+				Ω(samples[0].Type).Should(Equal(experiment.EventSample))
+				Ω(samples[0].Event).Should(Equal(&experiment.Event{Name: "kill the api vm", Error: "exit status 1"}))
+			})
+		})
+
 		It("Loads multiple CSVs from a directory, in order", func() {
 			foo := store.Writer("bar")
 			write(foo, []*experiment.Sample{
-				&experiment.Sample{nil, 1, 2, 3, 4, 5, 6, nil, 7, 3, 8, experiment.ResultSample},
-				&experiment.Sample{nil, 9, 8, 7, 6, 5, 4, errors.New("foo"), 3, 7, 2, experiment.ResultSample},
+				&experiment.Sample{Average: 1, TotalTime: 2, Total: 3, TotalErrors: 4, TotalWorkers: 5, LastResult: 6, WorstResult: 7, NinetyfifthPercentile: 3, WallTime: 8, Type: experiment.ResultSample},
+				&experiment.Sample{Average: 9, TotalTime: 8, Total: 7, TotalErrors: 6, TotalWorkers: 5, LastResult: 4, LastError: errors.New("foo"), WorstResult: 3, NinetyfifthPercentile: 7, WallTime: 2, Type: experiment.ResultSample},
 			})
 
 			bar := store.Writer("baz")
 			write(bar, []*experiment.Sample{
-				&experiment.Sample{nil, 1, 2, 3, 4, 5, 6, nil, 7, 3, 8, experiment.ResultSample},
-				&experiment.Sample{nil, 1, 2, 3, 4, 5, 6, nil, 7, 3, 8, experiment.ResultSample},
-				&experiment.Sample{nil, 9, 8, 7, 6, 5, 4, errors.New("foo"), 3, 7, 2, experiment.ResultSample},
+				&experiment.Sample{Average: 1, TotalTime: 2, Total: 3, TotalErrors: 4, TotalWorkers: 5, LastResult: 6, WorstResult: 7, NinetyfifthPercentile: 3, WallTime: 8, Type: experiment.ResultSample},
+				&experiment.Sample{Average: 1, TotalTime: 2, Total: 3, TotalErrors: 4, TotalWorkers: 5, LastResult: 6, WorstResult: 7, NinetyfifthPercentile: 3, WallTime: 8, Type: experiment.ResultSample},
+				&experiment.Sample{Average: 9, TotalTime: 8, Total: 7, TotalErrors: 6, TotalWorkers: 5, LastResult: 4, LastError: errors.New("foo"), WorstResult: 3, NinetyfifthPercentile: 7, WallTime: 2, Type: experiment.ResultSample},
 			})
 
 			samples, err := store.LoadAll()
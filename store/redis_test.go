@@ -80,20 +80,20 @@ var _ = Describe("Redis Store", func() {
 
 			writer := store.Writer("experiment-1")
 			write(writer, []*experiment.Sample{
-				&experiment.Sample{nil, 1, 2, 3, 4, 5, 6, nil, 7, 9, 8, experiment.ResultSample},
-				&experiment.Sample{nil, 9, 8, 7, 6, 5, 4, errors.New("foo"), 3, 1, 2, experiment.ResultSample},
+				&experiment.Sample{Average: 1, TotalTime: 2, Total: 3, TotalErrors: 4, TotalWorkers: 5, LastResult: 6, WorstResult: 7, NinetyfifthPercentile: 9, WallTime: 8, Type: experiment.ResultSample},
+				&experiment.Sample{Average: 9, TotalTime: 8, Total: 7, TotalErrors: 6, TotalWorkers: 5, LastResult: 4, LastError: errors.New("foo"), WorstResult: 3, NinetyfifthPercentile: 1, WallTime: 2, Type: experiment.ResultSample},
 			})
 
 			writer = store.Writer("experiment-2")
 			write(writer, []*experiment.Sample{
-				&experiment.Sample{nil, 2, 2, 3, 4, 5, 6, nil, 7, 9, 8, experiment.ResultSample},
+				&experiment.Sample{Average: 2, TotalTime: 2, Total: 3, TotalErrors: 4, TotalWorkers: 5, LastResult: 6, WorstResult: 7, NinetyfifthPercentile: 9, WallTime: 8, Type: experiment.ResultSample},
 			})
 
 			writer = store.Writer("experiment-3")
 			write(writer, []*experiment.Sample{
-				&experiment.Sample{nil, 1, 3, 3, 4, 5, 6, nil, 7, 9, 8, experiment.ResultSample},
-				&experiment.Sample{nil, 2, 3, 3, 4, 5, 6, nil, 7, 9, 8, experiment.ResultSample},
-				&experiment.Sample{nil, 9, 8, 7, 6, 5, 4, errors.New("foo"), 3, 1, 2, experiment.ResultSample},
+				&experiment.Sample{Average: 1, TotalTime: 3, Total: 3, TotalErrors: 4, TotalWorkers: 5, LastResult: 6, WorstResult: 7, NinetyfifthPercentile: 9, WallTime: 8, Type: experiment.ResultSample},
+				&experiment.Sample{Average: 2, TotalTime: 3, Total: 3, TotalErrors: 4, TotalWorkers: 5, LastResult: 6, WorstResult: 7, NinetyfifthPercentile: 9, WallTime: 8, Type: experiment.ResultSample},
+				&experiment.Sample{Average: 9, TotalTime: 8, Total: 7, TotalErrors: 6, TotalWorkers: 5, LastResult: 4, LastError: errors.New("foo"), WorstResult: 3, NinetyfifthPercentile: 1, WallTime: 2, Type: experiment.ResultSample},
 			})
 		})
 
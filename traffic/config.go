@@ -0,0 +1,47 @@
+package traffic
+
+import (
+	"os"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/benchmarker"
+	"github.com/cloudfoundry-community/pat/config"
+)
+
+var params = struct {
+	file     string
+	duration int
+	rules    []Rule
+}{}
+
+func DescribeParameters(config config.Config) {
+	params.rules = nil
+	config.StringVar(&params.file, "traffic-file", "", "Cloud Controller nginx access log, or CSV of timestamp,method,path, whose requests to reproduce as workloads at the same rate (see 'pat traffic')")
+	config.IntVar(&params.duration, "traffic-duration", 0, "only reproduce the first n seconds of the traffic file (0 for all of it)")
+	config.SectionVar(&params.rules, "traffic-mapping", "rules (method, path, workload) mapping the requests in the traffic file onto workloads, the first matching rule winning")
+}
+
+// Whether an experiment should reproduce the traffic in a file.
+func Configured() bool {
+	return params.file != ""
+}
+
+// Reads the configured traffic file and maps its requests onto a schedule of
+// workloads, spread over the given number of workers. Also returns how many
+// lines of the file were ignored, as they were not requests or matched no
+// rule.
+func ConfiguredSchedule(workers int) (schedule []benchmarker.Scheduled, ignored int, err error) {
+	file, err := os.Open(params.file)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	arrivals, skipped, err := ReadArrivals(file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	schedule, unmatched, err := Schedule(arrivals, params.rules, workers, time.Duration(params.duration)*time.Second)
+	return schedule, skipped + unmatched, err
+}
@@ -0,0 +1,171 @@
+package traffic
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/benchmarker"
+)
+
+// Maps the requests which match it onto a workload. Path is a regular
+// expression matched against the path of each request, without its query
+// string, and an empty Method matches requests with any method.
+type Rule struct {
+	Method   string `yaml:"method"`
+	Path     string `yaml:"path"`
+	Workload string `yaml:"workload"`
+}
+
+// A request seen in production.
+type Arrival struct {
+	Time   time.Time
+	Method string
+	Path   string
+}
+
+// The time and request line of an nginx access log entry, in either the
+// Cloud Controller's format or the usual combined one.
+var accessLogEntry = regexp.MustCompile(`\[([^\]]+)\] "([A-Z]+) ([^ "]+)`)
+
+// Reads requests from a Cloud Controller nginx access log, or from a CSV of
+// timestamp,method,path where the timestamp is RFC 3339 or Unix seconds.
+// Lines which are neither (such as a CSV header) are skipped, and counted.
+func ReadArrivals(r io.Reader) (arrivals []Arrival, skipped int, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if arrival, ok := parseLine(line); ok {
+			arrivals = append(arrivals, arrival)
+		} else {
+			skipped++
+		}
+	}
+
+	return arrivals, skipped, scanner.Err()
+}
+
+func parseLine(line string) (Arrival, bool) {
+	if m := accessLogEntry.FindStringSubmatch(line); m != nil {
+		for _, layout := range []string{"02/Jan/2006:15:04:05 -0700", time.RFC3339Nano} {
+			if t, err := time.Parse(layout, m[1]); err == nil {
+				return Arrival{t, m[2], withoutQuery(m[3])}, true
+			}
+		}
+		return Arrival{}, false
+	}
+
+	fields := strings.SplitN(line, ",", 3)
+	if len(fields) != 3 {
+		return Arrival{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(fields[0]))
+	if err != nil {
+		seconds, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		if err != nil {
+			return Arrival{}, false
+		}
+		t = time.Unix(0, int64(seconds*float64(time.Second)))
+	}
+
+	return Arrival{t, strings.ToUpper(strings.TrimSpace(fields[1])), withoutQuery(strings.TrimSpace(fields[2]))}, true
+}
+
+func withoutQuery(path string) string {
+	if i := strings.Index(path, "?"); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+type byTime []Arrival
+
+func (p byTime) Len() int           { return len(p) }
+func (p byTime) Less(i, j int) bool { return p[i].Time.Before(p[j].Time) }
+func (p byTime) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+type compiledRule struct {
+	method   string
+	path     *regexp.Regexp
+	workload string
+}
+
+// Turns the arrivals into a schedule of the workloads their requests map
+// onto, using the first rule each request matches, at the same offsets from
+// the first arrival. Iterations are spread over the workers in turn. Only
+// the first duration of traffic is scheduled, unless duration is zero.
+// Returns how many requests matched no rule.
+func Schedule(arrivals []Arrival, rules []Rule, workers int, duration time.Duration) (schedule []benchmarker.Scheduled, unmatched int, err error) {
+	compiled, err := compile(rules)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	sorted := append([]Arrival{}, arrivals...)
+	sort.Stable(byTime(sorted))
+
+	schedule = make([]benchmarker.Scheduled, 0)
+	for _, a := range sorted {
+		offset := a.Time.Sub(sorted[0].Time)
+		if duration > 0 && offset >= duration {
+			break
+		}
+
+		workload, ok := match(compiled, a)
+		if !ok {
+			unmatched++
+			continue
+		}
+
+		schedule = append(schedule, benchmarker.Scheduled{Offset: offset, Worker: len(schedule) % workers, Workload: workload})
+	}
+
+	return schedule, unmatched, nil
+}
+
+func compile(rules []Rule) ([]compiledRule, error) {
+	if len(rules) == 0 {
+		return nil, errors.New("No traffic-mapping rules to map requests onto workloads")
+	}
+
+	compiled := make([]compiledRule, len(rules))
+	for i, rule := range rules {
+		if rule.Workload == "" {
+			return nil, errors.New("The traffic-mapping rule for '" + rule.Path + "' has no workload")
+		}
+
+		path, err := regexp.Compile(rule.Path)
+		if err != nil {
+			return nil, errors.New("Invalid path in traffic-mapping rule: " + err.Error())
+		}
+
+		compiled[i] = compiledRule{strings.ToUpper(rule.Method), path, rule.Workload}
+	}
+
+	return compiled, nil
+}
+
+func match(rules []compiledRule, a Arrival) (string, bool) {
+	for _, rule := range rules {
+		if (rule.method == "" || rule.method == a.Method) && rule.path.MatchString(a.Path) {
+			return rule.workload, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,119 @@
+package traffic_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/benchmarker"
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/traffic"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Traffic", func() {
+	start := time.Date(2014, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	Describe("Reading arrivals", func() {
+		It("Reads a Cloud Controller nginx access log", func() {
+			arrivals, skipped, err := ReadArrivals(strings.NewReader(
+				`api.example.com - [01/Mar/2014:10:00:00 +0000] "GET /v2/apps?q=name:foo HTTP/1.1" 200 612 "-" "go-cli 6.1" 10.0.0.1:5000 vcap_request_id:abc response_time:0.012` + "\n" +
+					`10.0.0.2 - - [01/Mar/2014:10:00:01 +0000] "PUT /v2/apps/123/bits HTTP/1.1" 201 0 "-" "curl"` + "\n"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(skipped).Should(BeZero())
+			Ω(arrivals).Should(HaveLen(2))
+			Ω(arrivals[0].Time.Equal(start)).Should(BeTrue())
+			Ω(arrivals[0].Method).Should(Equal("GET"))
+			Ω(arrivals[0].Path).Should(Equal("/v2/apps"))
+			Ω(arrivals[1].Time.Equal(start.Add(time.Second))).Should(BeTrue())
+			Ω(arrivals[1].Path).Should(Equal("/v2/apps/123/bits"))
+		})
+
+		It("Reads a CSV of timestamp, method and path, skipping anything else", func() {
+			arrivals, skipped, err := ReadArrivals(strings.NewReader(
+				"timestamp,method,path\n2014-03-01T10:00:00Z,get,/v2/info\n\n1393668001.5,POST,/v2/apps\nnot a request\n"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(skipped).Should(Equal(2))
+			Ω(arrivals).Should(HaveLen(2))
+			Ω(arrivals[0]).Should(Equal(Arrival{start, "GET", "/v2/info"}))
+			Ω(arrivals[1].Time.Equal(start.Add(1500 * time.Millisecond))).Should(BeTrue())
+			Ω(arrivals[1].Method).Should(Equal("POST"))
+		})
+	})
+
+	Describe("Scheduling", func() {
+		var (
+			arrivals []Arrival
+			rules    []Rule
+		)
+
+		BeforeEach(func() {
+			arrivals = []Arrival{
+				{start.Add(3 * time.Second), "PUT", "/v2/apps/123/bits"},
+				{start, "GET", "/v2/info"},
+				{start.Add(time.Second), "GET", "/v2/apps"},
+				{start.Add(2 * time.Second), "DELETE", "/v2/apps/123"},
+			}
+			rules = []Rule{
+				{Method: "get", Path: "^/v2/apps$", Workload: "rest:target,rest:login,rest:list"},
+				{Method: "PUT", Path: "/bits$", Workload: "rest:target,rest:login,rest:push"},
+				{Path: "^/v2/info$", Workload: "rest:target"},
+			}
+		})
+
+		It("Maps each request onto the workload of the first rule it matches, at its offset", func() {
+			schedule, unmatched, err := Schedule(arrivals, rules, 2, 0)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(unmatched).Should(Equal(1))
+			Ω(schedule).Should(Equal([]benchmarker.Scheduled{
+				{Offset: 0, Worker: 0, Workload: "rest:target"},
+				{Offset: time.Second, Worker: 1, Workload: "rest:target,rest:login,rest:list"},
+				{Offset: 3 * time.Second, Worker: 0, Workload: "rest:target,rest:login,rest:push"},
+			}))
+		})
+
+		It("Only schedules the given duration of traffic", func() {
+			schedule, _, err := Schedule(arrivals, rules, 1, 2*time.Second)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(schedule).Should(HaveLen(2))
+		})
+
+		It("Rejects invalid rules", func() {
+			_, _, err := Schedule(arrivals, nil, 1, 0)
+			Ω(err).Should(HaveOccurred())
+			_, _, err = Schedule(arrivals, []Rule{{Path: "(", Workload: "rest:target"}}, 1, 0)
+			Ω(err).Should(HaveOccurred())
+			_, _, err = Schedule(arrivals, []Rule{{Path: "/v2/info"}}, 1, 0)
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	Describe("The configured schedule", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "traffic")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("Maps the traffic file's requests using the traffic-mapping rules", func() {
+			ioutil.WriteFile(dir+"/traffic.csv", []byte("2014-03-01T10:00:00Z,GET,/v2/info\n2014-03-01T10:00:05Z,GET,/v2/apps\n"), 0644)
+			ioutil.WriteFile(dir+"/config.yml", []byte("traffic-mapping:\n  - path: ^/v2/info$\n    workload: rest:target\n"), 0644)
+
+			flags := config.NewConfig()
+			DescribeParameters(flags)
+			Ω(flags.Parse([]string{"-config", dir + "/config.yml", "-traffic-file", dir + "/traffic.csv"})).ShouldNot(HaveOccurred())
+			Ω(Configured()).Should(BeTrue())
+
+			schedule, ignored, err := ConfiguredSchedule(1)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(ignored).Should(Equal(1))
+			Ω(schedule).Should(Equal([]benchmarker.Scheduled{{Workload: "rest:target"}}))
+		})
+	})
+})
@@ -8,13 +8,16 @@ pat.experiment = function(refreshRate) {
   exports.url = ko.observable("")
   exports.csvUrl = ko.observable("")
   exports.data = ko.observableArray()
+  exports.events = ko.observableArray()
   exports.config = { iterations: ko.observable(1), concurrency: ko.observable(1), interval: ko.observable(0), stop: ko.observable(0) }
 
   var timer = null
 
   exports.refresh = function() {
     $.get(exports.url(), function(data) {
-      exports.data(data.Items.filter(function(d) { return d.Type === 0 }))
+      var events = data.Items.filter(function(d) { return d.Type === 5 })
+      exports.events(events)
+      exports.data(pat.annotate(data.Items.filter(function(d) { return d.Type === 0 }), events))
       exports.waitAndRefreshOnce()
     })
   }
@@ -31,6 +34,7 @@ pat.experiment = function(refreshRate) {
   exports.run = function() {
     exports.state("running")
     exports.data([])
+    exports.events([])
 		$.post( "/experiments/", { "iterations": exports.config.iterations(), "concurrency": exports.config.concurrency(), "interval": exports.config.interval(), "stop": exports.config.stop(),  "workload": $("#cmdSelect").val() }, function(data) {
 			exports.url(data.Location)
 			exports.csvUrl(data.CsvLocation)
@@ -85,6 +89,55 @@ pat.experimentList = function() {
   return exports
 }
 
+pat.scheduleList = function() {
+  var exports = {}
+
+  var timer = null
+
+  exports.schedules = ko.observableArray()
+  exports.refresh = function() {
+    $.get("/schedules/", function(data) {
+      exports.schedules(data.Items)
+      timer = setTimeout(exports.refresh, 1000 * 30)
+    })
+  }
+
+  exports.refreshNow = function() {
+    if(timer) { clearTimeout(timer) }
+    exports.refresh()
+  }
+
+  exports.add = function(name, cron, config) {
+    $.post("/schedules/", $.extend({ "name": name, "cron": cron }, config), exports.refreshNow)
+  }
+
+  exports.remove = function(schedule) {
+    $.ajax({ url: schedule.Location, type: "DELETE", success: exports.refreshNow })
+  }
+
+  exports.refresh()
+
+  return exports
+}
+
+// Marks each result with the events (such as injected faults) which happened
+// since the result before it, so the chart can show them.
+pat.annotate = function(results, events) {
+  var previous = -1
+  results.forEach(function(r) {
+    var happened = events.filter(function(e) { return e.WallTime > previous && e.WallTime <= r.WallTime })
+    if(happened.length > 0) {
+      r.Events = happened.map(function(e) { return e.Event.Name + (e.Event.Error ? " (failed: " + e.Event.Error + ")" : "") })
+    }
+    previous = r.WallTime
+  })
+  return results
+}
+
+pat.ms = function(nanoseconds) {
+  return nanoseconds ? (nanoseconds / 1000000).toFixed(1) + " ms" : "-"
+}
+
 ko.bindingHandlers.chart = {
   c: {},
   init: function(element, valueAccessor) {    
@@ -101,7 +154,7 @@ ko.bindingHandlers.chart = {
   }
 }
 
-pat.view = function(experimentList, experiment) {
+pat.view = function(experimentList, experiment, scheduleList) {
   var self = this
 
   this.redirectTo = function(location) { window.location = location }
@@ -109,6 +162,9 @@ pat.view = function(experimentList, experiment) {
   this.start = function() { experiment.run() }
   this.stop = function() { alert("Not implemented") }
   this.downloadCsv = function() { self.redirectTo(experiment.csvUrl()) }
+  this.cancel = function(ex) {
+    $.ajax({ url: ex.Location, type: "DELETE", success: experimentList.refreshNow })
+  }
 
   this.canStart = ko.computed(function() { return experiment.state() !== "running" })
   this.canStop = ko.computed(function() { return experiment.state() === "running" })
@@ -124,10 +180,35 @@ pat.view = function(experimentList, experiment) {
   this.numStopHasError = ko.computed(function() { return experiment.config.stop() < 0 })
   this.formHasNoErrors = ko.computed(function() { return ! ( this.numIterationsHasError() | this.numConcurrentHasError() | this.numIntervalHasError() | this.numStopHasError() ) }, this)
   this.previousExperiments = experimentList.experiments
+  this.schedules = scheduleList ? scheduleList.schedules : ko.observableArray()
+  this.scheduleName = ko.observable("")
+  this.scheduleCron = ko.observable("")
+  this.addSchedule = function() {
+    scheduleList.add(self.scheduleName(), self.scheduleCron(), { "iterations": experiment.config.iterations(), "concurrency": experiment.config.concurrency(), "interval": experiment.config.interval(), "stop": experiment.config.stop(), "workload": $("#cmdSelect").val() })
+  }
+  this.removeSchedule = function(schedule) { scheduleList.remove(schedule) }
   this.data = experiment.data
+  this.events = ko.computed(function() {
+    return experiment.events().map(function(e) {
+      return { WallTime: (e.WallTime / 1000000000).toFixed(2) + " sec", Name: e.Event.Name, Error: e.Event.Error }
+    })
+  })
+  this.iteration = ko.observable(null)
+  this.showIteration = function(sample) { self.iteration(sample) }
+  this.isShownIteration = function(sample) { return self.iteration() !== null && self.iteration().WallTime === sample.WallTime }
+  this.iterationSteps = ko.computed(function() {
+    var sample = self.iteration()
+    if(!sample || !sample.LastSteps) { return [] }
+    return sample.LastSteps.map(function(step) {
+      return { Command: step.Command, Duration: pat.ms(step.Duration), Requests: (step.Requests || []).map(function(r) {
+        return { Method: r.Method, Url: r.Url, Status: r.Status || "failed", RequestId: r.RequestId, Dns: pat.ms(r.Dns), Connect: pat.ms(r.Connect), Tls: pat.ms(r.Tls), FirstByte: pat.ms(r.FirstByte), Total: pat.ms(r.Total) }
+      }) }
+    })
+  })
 
   experiment.url.subscribe(function(url) {
     window.location.hash = "#" + url
+    self.iteration(null)
   })
 
   experiment.state.subscribe(function() {
@@ -135,6 +135,7 @@ var barchart = function(el) {
     self.outerBody.select(".y.axis").call(self.yAxis);
 
     hightlightErrors();
+    markEvents(data, x);
    
     bars.exit().remove();
     labels.exit().remove();
@@ -180,6 +181,33 @@ var barchart = function(el) {
     })
   }
 
+  // Draws a line before each bar with events since the bar before it.
+  function markEvents(data, x) {
+    var annotations = [];
+    data.forEach(function(d, i) {
+      if (d.Events) annotations.push({ "i": i, "title": d.Events.join(", ") });
+    });
+
+    var markers = self.barCon.selectAll("line.event").data(annotations);
+    markers.enter()
+      .append("line")
+        .attr("class", "event");
+    markers
+        .attr("x1", function(a) { return x(a.i) - 1 })
+        .attr("x2", function(a) { return x(a.i) - 1 })
+        .attr("y1", 0)
+        .attr("y2", self.svgHeight)
+        .attr("data-toggle", "tooltip")
+        .attr("title", function(a) { return a.title })
+        .each(function() {
+          $(this).tooltip({
+            "placement": "top",
+            "container": "body"
+          });
+        });
+    markers.exit().remove();
+  }
+
   function getTranslateX(node) {
     var splitted = node.attr("transform").split(",");
     return parseInt(splitted [0].split("(")[1]);
@@ -3,7 +3,7 @@ describe("The view", function() {
   var experimentList
 
   beforeEach(function() {
-    experiment = { run: function() {}, url: ko.observable(""), state: ko.observable(""), view: function() {}, csvUrl: ko.observable(""), config: { iterations: ko.observable(1), concurrency: ko.observable(1), interval: ko.observable(0), stop: ko.observable(0) } }
+    experiment = { run: function() {}, url: ko.observable(""), state: ko.observable(""), view: function() {}, csvUrl: ko.observable(""), events: ko.observableArray(), config: { iterations: ko.observable(1), concurrency: ko.observable(1), interval: ko.observable(0), stop: ko.observable(0) } }
     experimentList = { experiments: [], refreshNow: function(){} }
     spyOn(experimentList, "refreshNow")
     spyOn(experiment, "view")
@@ -96,6 +96,39 @@ describe("The view", function() {
     })    
   })
 
+  describe("showing an iteration", function() {
+    beforeEach(function() {
+      v.showIteration({ WallTime: 5, LastSteps: [{ Command: "rest:push", Duration: 2000000, Requests: [{ Method: "PUT", Url: "http://api/v2/apps/1/bits", Status: 201, RequestId: "abc", Total: 1500000 }] }] })
+    })
+
+    it("lists its steps and their requests", function() {
+      var steps = v.iterationSteps()
+      expect(steps.length).toBe(1)
+      expect(steps[0].Command).toBe("rest:push")
+      expect(steps[0].Duration).toBe("2.0 ms")
+      expect(steps[0].Requests[0].RequestId).toBe("abc")
+      expect(steps[0].Requests[0].Total).toBe("1.5 ms")
+      expect(steps[0].Requests[0].Dns).toBe("-")
+    })
+
+    it("highlights the iteration", function() {
+      expect(v.isShownIteration({ WallTime: 5 })).toBe(true)
+      expect(v.isShownIteration({ WallTime: 6 })).toBe(false)
+    })
+
+    it("is hidden when another experiment is viewed", function() {
+      experiment.url("/experiments/other")
+      expect(v.iterationSteps().length).toBe(0)
+    })
+  })
+
+  describe("listing events", function() {
+    it("shows when each happened, and whether it failed", function() {
+      experiment.events([{ Type: 5, WallTime: 2500000000, Event: { Name: "kill the api vm", Error: "" } }])
+      expect(v.events()).toEqual([{ WallTime: "2.50 sec", Name: "kill the api vm", Error: "" }])
+    })
+  })
+
   describe("Previous Histories Popup", function() {
     it("should be hidden from the view by default", function() {    
       var property = $('#historyPopup').css('display');
@@ -191,6 +224,17 @@ describe("Bar chart", function() {
     })
   })
     
+  it("should mark events with a line before the first bar after them", function() {
+    var data = [{"LastResult" : 2 * sec},
+                {"LastResult" : 5 * sec, "Events": ["kill the api vm"]},
+                {"LastResult" : 1 * sec}];
+    chart(data);
+
+    var markers = d3.select( chart.drawArea() ).selectAll("line.event");
+    expect(markers.size()).toBe(1);
+    expect(markers.attr("title")).toBe("kill the api vm");
+  })
+
   it("should auto-pan to the left when new data is drawn outside of the viewable area", function() {
     var data = [];
     
@@ -379,5 +423,13 @@ describe("Running an experiment", function my() {
     it("updates the state to 'running'", function() {
       expect(experiment.state()).toBe("running")
     })
+
+    it("keeps events (type 5) and marks them on the next result", function() {
+      var event = {"Type": 5, "WallTime": 2, "Event": {"Name": "kill the api vm", "Error": "exit status 1"}}
+      $.get.mostRecentCall.args[1]({"Items": [{"Type": 0, "WallTime": 1}, event, {"Type": 0, "WallTime": 3}]})
+      expect(experiment.events()).toEqual([event])
+      expect(experiment.data()[0].Events).toBeUndefined()
+      expect(experiment.data()[1].Events).toEqual(["kill the api vm (failed: exit status 1)"])
+    })
   })
 })
@@ -0,0 +1,242 @@
+package workloads
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry-community/pat/config"
+)
+
+// The application pushed by rest:push, rest3:push and gcf:push, and the
+// settings it is pushed with.
+type appConfig struct {
+	path      string
+	buildpack string
+	stack     string
+	memory    int
+	disk      int
+	instances int
+	env       string
+	size      int
+}
+
+var pushedApp = &appConfig{}
+
+func (a *appConfig) DescribeParameters(config config.Config) {
+	config.StringVar(&a.path, "app:path", "", "directory, .zip, .jar or .war of the app to push (default: a small generated Ruby app)")
+	config.StringVar(&a.buildpack, "app:buildpack", "", "buildpack name or URL to push the app with (default: detected by the platform)")
+	config.StringVar(&a.stack, "app:stack", "", "stack to push the app to (default: the platform default)")
+	config.IntVar(&a.memory, "app:memory", 0, "memory (in MB) of each app instance (default: the platform default)")
+	config.IntVar(&a.disk, "app:disk", 0, "disk quota (in MB) of each app instance (default: the platform default)")
+	config.IntVar(&a.instances, "app:instances", 0, "number of app instances to start (default: the platform default)")
+	config.StringVar(&a.env, "app:env", "", "comma-separated NAME=VALUE environment variables to set on the app")
+	config.IntVar(&a.size, "app:size", 0, "pad the app with this many KB of random data, to simulate larger apps")
+}
+
+// The app:env setting as a map.
+func (a *appConfig) environment() map[string]string {
+	env := make(map[string]string)
+	for _, pair := range strings.Split(a.env, ",") {
+		if parts := strings.SplitN(strings.TrimSpace(pair), "=", 2); len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	return env
+}
+
+// Writes the app, as a zip, to w.
+func (a *appConfig) writeZip(w io.Writer) error {
+	zipper := zip.NewWriter(w)
+
+	var err error
+	switch {
+	case a.path == "":
+		err = writeGeneratedApp(zipper)
+	case isArchive(a.path):
+		err = copyZip(zipper, a.path)
+	default:
+		err = zipDirectory(zipper, a.path)
+	}
+	if err != nil {
+		return err
+	}
+
+	if a.size > 0 {
+		padding, _ := zipper.Create("pat-padding.bin")
+		random := rand.New(rand.NewSource(rand.Int63()))
+		io.CopyN(padding, random, int64(a.size)*1024)
+	}
+
+	return zipper.Close()
+}
+
+// Writes the app to a temporary zip file, for pushing with the CLI.
+func (a *appConfig) writeZipFile() (string, error) {
+	file, err := ioutil.TempFile("", "pat-app")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := a.writeZip(file); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	zipped := file.Name() + ".zip"
+	return zipped, os.Rename(file.Name(), zipped)
+}
+
+const defaultCliApp = "assets/hello-world"
+
+// gcf:push has always pushed with 64MB unless told otherwise.
+const defaultCliMemory = 64
+
+// The path to push with the CLI: app:path as given, or a generated zip if
+// the app needs to be padded.
+func (a *appConfig) cliPath() (string, error) {
+	if a.size > 0 {
+		return a.writeZipFile()
+	}
+
+	if a.path == "" {
+		return defaultCliApp, nil
+	}
+
+	return a.path, nil
+}
+
+func (a *appConfig) cliArgs(name string, path string) []string {
+	memory := a.memory
+	if memory == 0 {
+		memory = defaultCliMemory
+	}
+
+	args := []string{"push", name, "patsapp", "-m", fmt.Sprintf("%dM", memory), "-p", path}
+	if a.instances > 0 {
+		args = append(args, "-i", strconv.Itoa(a.instances))
+	}
+	if a.disk > 0 {
+		args = append(args, "-k", fmt.Sprintf("%dM", a.disk))
+	}
+	if a.buildpack != "" {
+		args = append(args, "-b", a.buildpack)
+	}
+	if a.stack != "" {
+		args = append(args, "-s", a.stack)
+	}
+	return args
+}
+
+func (a *appConfig) withBits(field string, fn func(b *bytes.Buffer, m *multipart.Writer) error) error {
+	var b bytes.Buffer
+	multi := multipart.NewWriter(&b)
+	appbits, _ := multi.CreateFormFile(field, "app.zip")
+	if err := a.writeZip(appbits); err != nil {
+		return err
+	}
+	resources, _ := multi.CreateFormField("resources")
+	resources.Write([]byte("[]"))
+	multi.Close()
+
+	return fn(&b, multi)
+}
+
+func writeGeneratedApp(zipper *zip.Writer) error {
+	configru, _ := zipper.Create("config.ru")
+	configru.Write([]byte("$stdout.sync = true\n\napp = lambda do |env|\n puts env['QUERY_STRING'] unless env['QUERY_STRING'].to_s.empty?\n body = 'Hello, World!'\n [200, { 'Content-Type' => 'text/plain', 'Content-Length' => body.length.to_s }, [body] ]\n end\n\n run app"))
+	gemfile, _ := zipper.Create("Gemfile")
+	gemfile.Write([]byte("source \"https://rubygems.org\" \n\ngem \"rack\""))
+	gemfilelock, _ := zipper.Create("Gemfile.lock")
+	gemfilelock.Write([]byte("GEM\n  remote: https://rubygems.org/\n  specs:\n    track (1.5.2)\n\nPLATFORMS\n  ruby\n\nDEPENDENCIES\n  rack"))
+	return nil
+}
+
+func isArchive(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zip", ".jar", ".war":
+		return true
+	}
+	return false
+}
+
+func copyZip(zipper *zip.Writer, path string) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		if err := copyZipEntry(zipper, file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyZipEntry(zipper *zip.Writer, file *zip.File) error {
+	in, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	header := file.FileHeader
+	out, err := zipper.CreateHeader(&header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func zipDirectory(zipper *zip.Writer, dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("app:path must be a directory or a .zip, .jar or .war file")
+	}
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		name, _ := filepath.Rel(dir, path)
+		header, _ := zip.FileInfoHeader(info)
+		header.Name = filepath.ToSlash(name)
+		header.Method = zip.Deflate
+		out, err := zipper.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		_, err = io.Copy(out, in)
+		return err
+	})
+}
@@ -0,0 +1,120 @@
+package workloads_test
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"strings"
+
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pushed app settings", func() {
+	var (
+		client  *dummyClient
+		rest    workloads
+		args    []string
+		context map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		args = []string{}
+	})
+
+	JustBeforeEach(func() {
+		replies := make(map[string]interface{})
+		replyWithLocation := make(map[string]string)
+		client = &dummyClient{replies, replyWithLocation, make(map[call]interface{})}
+		rest = NewRestWorkloadWithClient(client)
+		config := config.NewConfig()
+		rest.DescribeParameters(config)
+		Ω(config.Parse(append([]string{"-rest:target", "APISERVER"}, args...))).ShouldNot(HaveOccurred())
+		context = make(map[string]interface{})
+
+		replies["APISERVER/v2/info"] = TargetResponse{LoginEndpoint: "THELOGINSERVER/PATH"}
+		replies["THELOGINSERVER/PATH/oauth/token"] = LoginResponse{"blah blah"}
+		replies["APISERVER/v2/spaces?q=name:dev"] = SpaceResponse{[]Resource{Resource{Metadata{"THE-SPACE"}}}}
+		replies["APISERVER/v2/stacks?q=name:cflinuxfs2"] = NamedResourcesResponse{[]NamedResource{namedResource("THE-STACK", "cflinuxfs2")}}
+		replyWithLocation["APISERVER/v2/apps"] = "/THE-APP-URI"
+		replies["APISERVER/THE-APP-URI"] = ""
+		replies["APISERVER/THE-APP-URI/bits"] = ""
+		replies["APISERVER/THE-APP-URI/instances"] = ""
+
+		rest.Target(context)
+		rest.Login(context)
+		Ω(rest.Push(context)).ShouldNot(HaveOccurred())
+	})
+
+	Context("By default", func() {
+		It("Pushes a generated Ruby app with the platform's default memory, disk and instances", func() {
+			m := mapOf(client.ShouldHaveBeenCalledWith("POST", "APISERVER/v2/apps"))
+			Ω(m).ShouldNot(HaveKey("memory"))
+			Ω(m).ShouldNot(HaveKey("disk_quota"))
+			Ω(m).ShouldNot(HaveKey("instances"))
+			Ω(m).ShouldNot(HaveKey("buildpack"))
+
+			files := uploaded(client.ShouldHaveBeenCalledWith("PUT(multipart)", "APISERVER/THE-APP-URI/bits"))
+			Ω(files).Should(HaveKey("config.ru"))
+			Ω(files["Gemfile"]).Should(ContainSubstring("https://rubygems.org"))
+		})
+	})
+
+	Context("When the app settings are configured", func() {
+		BeforeEach(func() {
+			args = []string{"-app:memory", "512", "-app:disk", "1024", "-app:instances", "3", "-app:buildpack", "java_buildpack",
+				"-app:stack", "cflinuxfs2", "-app:env", "JAVA_OPTS=-Xss1m,DEBUG=true"}
+		})
+
+		It("Creates the app with them", func() {
+			m := mapOf(client.ShouldHaveBeenCalledWith("POST", "APISERVER/v2/apps"))
+			Ω(m["memory"]).Should(BeNumerically("==", 512))
+			Ω(m["disk_quota"]).Should(BeNumerically("==", 1024))
+			Ω(m["instances"]).Should(BeNumerically("==", 3))
+			Ω(m["buildpack"]).Should(Equal("java_buildpack"))
+			Ω(m["stack_guid"]).Should(Equal("THE-STACK"))
+			Ω(m["environment_json"]).Should(Equal(map[string]interface{}{"JAVA_OPTS": "-Xss1m", "DEBUG": "true"}))
+		})
+	})
+
+	Context("When an app directory is configured", func() {
+		BeforeEach(func() {
+			os.MkdirAll("/tmp/pat-app/lib", 0755)
+			ioutil.WriteFile("/tmp/pat-app/server.js", []byte("console.log('hi')"), 0644)
+			ioutil.WriteFile("/tmp/pat-app/lib/util.js", []byte("module.exports = {}"), 0644)
+			args = []string{"-app:path", "/tmp/pat-app", "-app:size", "64"}
+		})
+
+		It("Uploads its files, padded to the requested size", func() {
+			files := uploaded(client.ShouldHaveBeenCalledWith("PUT(multipart)", "APISERVER/THE-APP-URI/bits"))
+			Ω(files["server.js"]).Should(Equal("console.log('hi')"))
+			Ω(files["lib/util.js"]).Should(Equal("module.exports = {}"))
+			Ω(files).ShouldNot(HaveKey("config.ru"))
+			Ω(files["pat-padding.bin"]).Should(HaveLen(64 * 1024))
+		})
+	})
+})
+
+// The files in the zip uploaded in a multipart body.
+func uploaded(data interface{}) map[string]string {
+	body := data.(*bytes.Buffer).Bytes()
+	boundary, _ := bufio.NewReader(bytes.NewReader(body)).ReadString('\n')
+	part, err := multipart.NewReader(bytes.NewReader(body), strings.TrimSpace(strings.TrimPrefix(boundary, "--"))).NextPart()
+	Ω(err).ShouldNot(HaveOccurred())
+	zipped, _ := ioutil.ReadAll(part)
+
+	reader, err := zip.NewReader(bytes.NewReader(zipped), int64(len(zipped)))
+	Ω(err).ShouldNot(HaveOccurred())
+	files := make(map[string]string)
+	for _, f := range reader.File {
+		r, _ := f.Open()
+		content, _ := ioutil.ReadAll(r)
+		files[f.Name] = string(content)
+	}
+	return files
+}
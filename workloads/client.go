@@ -16,6 +16,9 @@ type httpclient interface {
 	Put(token string, url string, data interface{}, responseBody interface{}) (reply Reply)
 	MultipartPut(token string, m *multipart.Writer, url string, data *bytes.Buffer, responseBody interface{}) (reply Reply)
 	Post(token string, url string, data interface{}, responseBody interface{}) (reply Reply)
+	MultipartPost(token string, m *multipart.Writer, url string, data *bytes.Buffer, responseBody interface{}) (reply Reply)
+	Patch(token string, url string, data interface{}, responseBody interface{}) (reply Reply)
+	Delete(token string, url string, data interface{}, responseBody interface{}) (reply Reply)
 	PostToUaa(url string, data url.Values, responseBody interface{}) (reply Reply)
 }
 
@@ -26,23 +29,35 @@ type Reply struct {
 }
 
 func (client rest) Post(token string, url string, data interface{}, body interface{}) Reply {
-	return client.req(token, "POST", url, "", "", "", jsonToString(data), body)
+	return client.req(token, "POST", url, "application/json", "", "", jsonToString(data), body)
 }
 
 func (client rest) Put(token string, url string, data interface{}, body interface{}) Reply {
-	return client.req(token, "PUT", url, "", "", "", jsonToString(data), body)
+	return client.req(token, "PUT", url, "application/json", "", "", jsonToString(data), body)
 }
 
 func (client rest) MultipartPut(token string, m *multipart.Writer, url string, data *bytes.Buffer, body interface{}) Reply {
 	return client.req(token, "PUT", url, m.FormDataContentType(), "", "", data, body)
 }
 
+func (client rest) MultipartPost(token string, m *multipart.Writer, url string, data *bytes.Buffer, body interface{}) Reply {
+	return client.req(token, "POST", url, m.FormDataContentType(), "", "", data, body)
+}
+
+func (client rest) Patch(token string, url string, data interface{}, body interface{}) Reply {
+	return client.req(token, "PATCH", url, "application/json", "", "", jsonToString(data), body)
+}
+
+func (client rest) Delete(token string, url string, data interface{}, body interface{}) Reply {
+	return client.req(token, "DELETE", url, "", "", "", jsonToString(data), body)
+}
+
 func (client rest) Get(token string, url string, data interface{}, body interface{}) Reply {
 	return client.req(token, "GET", url, "", "", "", jsonToString(data), body)
 }
 
 func (client rest) PostToUaa(url string, data url.Values, reply interface{}) Reply {
-	return client.req("", "POST", url, "application/x-www-form-urlencoded", "cf", "", strings.NewReader(data.Encode()), reply)
+	return client.req("", "POST", url, "application/x-www-form-urlencoded", client.uaa.clientId, client.uaa.clientSecret, strings.NewReader(data.Encode()), reply)
 }
 
 func (context *rest) GetSuccessfully(token string, url string, data url.Values, responseBody interface{}, fn func(reply Reply) error) error {
@@ -73,6 +88,27 @@ func (context *rest) PostSuccessfully(token string, url string, data interface{}
 	})
 }
 
+func (context *rest) MultipartPostSuccessfully(token string, m *multipart.Writer, url string, data *bytes.Buffer, responseBody interface{}, fn func(reply Reply) error) error {
+	reply := context.client.MultipartPost(token, m, url, data, responseBody)
+	return checkSuccessfulReply(reply, func() error {
+		return fn(reply)
+	})
+}
+
+func (context *rest) PatchSuccessfully(token string, url string, data interface{}, responseBody interface{}, fn func(reply Reply) error) error {
+	reply := context.client.Patch(token, url, data, responseBody)
+	return checkSuccessfulReply(reply, func() error {
+		return fn(reply)
+	})
+}
+
+func (context *rest) DeleteSuccessfully(token string, url string, data interface{}, responseBody interface{}, fn func(reply Reply) error) error {
+	reply := context.client.Delete(token, url, data, responseBody)
+	return checkSuccessfulReply(reply, func() error {
+		return fn(reply)
+	})
+}
+
 func (context *rest) PostToUaaSuccessfully(url string, data url.Values, responseBody interface{}, fn func(reply Reply) error) error {
 	reply := context.client.PostToUaa(url, data, responseBody)
 	return checkSuccessfulReply(reply, func() error {
@@ -101,13 +137,20 @@ func (client rest) req(token string, method string, url string, contentType stri
 		req.Header.Set("Content-Type", contentType)
 	}
 
-	c := &http.Client{}
-	resp, err := c.Do(req)
+	c, err := client.transport.clientFor(client.iteration)
+	if err != nil {
+		return Reply{0, err.Error(), ""}
+	}
+
+	var result Reply
+	err = traceRequest(client.requests, c, req, func(resp *http.Response) {
+		json.NewDecoder(resp.Body).Decode(&reply)
+		log.Println(method, " ", url, "-", resp.Status)
+		result = Reply{resp.StatusCode, resp.Status, resp.Header.Get("Location")}
+	})
 	if err != nil {
 		return Reply{0, err.Error(), ""}
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(&reply)
-	log.Println(method, " ", url, "-", resp.Status)
-	return Reply{resp.StatusCode, resp.Status, resp.Header.Get("Location")}
+	return result
 }
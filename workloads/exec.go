@@ -0,0 +1,153 @@
+package workloads
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/config"
+)
+
+// A command described in the "exec" section of the configuration file. Each
+// one is registered as an exec:<name> workload step. Args and Env values
+// may refer to values in the step context as {{key}}. The step fails if the
+// command does not exit with ExitCode, if its output does not match the
+// Output regular expression, or if it runs for longer than Timeout seconds.
+type ExecStep struct {
+	Command  string            `yaml:"command"`
+	Args     []string          `yaml:"args"`
+	Env      map[string]string `yaml:"env"`
+	Timeout  int               `yaml:"timeout"`
+	Output   string            `yaml:"output"`
+	ExitCode int               `yaml:"exit-code"`
+}
+
+type execWorkload struct {
+	steps map[string]ExecStep
+}
+
+func NewExecWorkload() *execWorkload {
+	return &execWorkload{}
+}
+
+func (e *execWorkload) DescribeParameters(config config.Config) {
+	e.steps = make(map[string]ExecStep)
+	config.SectionVar(&e.steps, "exec", "commands to register as exec:<name> workload steps")
+}
+
+func (e *execWorkload) Steps() []WorkloadStep {
+	names := make([]string, 0, len(e.steps))
+	for name := range e.steps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	steps := make([]WorkloadStep, 0, len(names))
+	for _, name := range names {
+		step := e.steps[name]
+		steps = append(steps, StepWithContext("exec:"+name, step.Run, strings.Join(append([]string{step.Command}, step.Args...), " ")))
+	}
+
+	return steps
+}
+
+func (s ExecStep) Run(ctx map[string]interface{}) error {
+	args := make([]string, len(s.Args))
+	for i, arg := range s.Args {
+		expanded, err := expand(arg, ctx)
+		if err != nil {
+			return err
+		}
+		args[i] = expanded
+	}
+
+	env := os.Environ()
+	for name, value := range s.Env {
+		expanded, err := expand(value, ctx)
+		if err != nil {
+			return err
+		}
+		env = append(env, name+"="+expanded)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(s.Command, args...)
+	cmd.Env = env
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	newProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exitCode, err := wait(cmd, time.Duration(s.Timeout)*time.Second)
+	if err != nil {
+		return fmt.Errorf("%s %s%s", s.Command, err, details(&stdout, &stderr))
+	}
+
+	if exitCode != s.ExitCode {
+		return fmt.Errorf("%s exited with status %d, expected %d%s", s.Command, exitCode, s.ExitCode, details(&stdout, &stderr))
+	}
+
+	if s.Output != "" {
+		expected, err := regexp.Compile(s.Output)
+		if err != nil {
+			return err
+		}
+
+		if !expected.Match(stdout.Bytes()) {
+			return fmt.Errorf("%s output did not match '%s'%s", s.Command, s.Output, details(&stdout, &stderr))
+		}
+	}
+
+	return nil
+}
+
+// Waits for the command to exit, killing it after timeout (if non-zero),
+// and returns its exit status. Where the platform allows, the command runs in
+// its own process group so that any children it started, which may still
+// hold its output pipes, are killed along with it.
+func wait(cmd *exec.Cmd, timeout time.Duration) (int, error) {
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+
+	var err error
+	select {
+	case err = <-done:
+	case <-expired:
+		killProcessGroup(cmd)
+		<-done
+		return 0, fmt.Errorf("timed out after %s", timeout)
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus(), nil
+		}
+	}
+
+	return 0, err
+}
+
+func details(stdout *bytes.Buffer, stderr *bytes.Buffer) string {
+	var b bytes.Buffer
+	if stdout.Len() > 0 {
+		fmt.Fprintf(&b, "\nstdout: %s", strings.TrimSpace(stdout.String()))
+	}
+	if stderr.Len() > 0 {
+		fmt.Fprintf(&b, "\nstderr: %s", strings.TrimSpace(stderr.String()))
+	}
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package workloads_test
+
+import (
+	"io/ioutil"
+
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Exec Workloads", func() {
+	var (
+		steps   map[string]WorkloadStep
+		context map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		ioutil.WriteFile("/tmp/exec-steps.yml", []byte(`
+exec:
+  echo:
+    command: sh
+    args: ["-c", "echo hello $GREETING {{name}}"]
+    env:
+      GREETING: "{{greeting}}"
+    output: "hello hi [a-z]+"
+  fail:
+    command: sh
+    args: ["-c", "echo oops >&2; exit 3"]
+  expect-fail:
+    command: sh
+    args: ["-c", "exit 3"]
+    exit-code: 3
+  slow:
+    command: sleep
+    args: ["5"]
+    timeout: 1
+  forks:
+    command: sh
+    args: ["-c", "sleep 600 & wait"]
+    timeout: 1
+`), 0755)
+
+		workload := NewExecWorkload()
+		c := config.NewConfig()
+		workload.DescribeParameters(c)
+		Ω(c.Parse([]string{"-config", "/tmp/exec-steps.yml"})).ShouldNot(HaveOccurred())
+
+		steps = make(map[string]WorkloadStep)
+		for _, step := range workload.Steps() {
+			steps[step.Name] = step
+		}
+
+		context = map[string]interface{}{"name": "world", "greeting": "hi"}
+	})
+
+	It("Registers a step for each command", func() {
+		Ω(steps).Should(HaveLen(5))
+		Ω(steps["exec:slow"].Description).Should(Equal("sleep 5"))
+	})
+
+	It("Substitutes context values into the arguments and environment", func() {
+		Ω(steps["exec:echo"].Fn(context)).ShouldNot(HaveOccurred())
+	})
+
+	It("Returns an error when the output does not match", func() {
+		context["greeting"] = "bye"
+		err := steps["exec:echo"].Fn(context)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("stdout: hello bye world"))
+	})
+
+	It("Returns an error including stderr when the command fails", func() {
+		err := steps["exec:fail"].Fn(context)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("exited with status 3"))
+		Ω(err.Error()).Should(ContainSubstring("stderr: oops"))
+	})
+
+	It("Accepts the configured exit code", func() {
+		Ω(steps["exec:expect-fail"].Fn(context)).ShouldNot(HaveOccurred())
+	})
+
+	It("Kills commands that run past their timeout", func() {
+		err := steps["exec:slow"].Fn(context)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("timed out"))
+	})
+
+	It("Kills the children of commands that run past their timeout", func() {
+		done := make(chan error, 1)
+		go func() { done <- steps["exec:forks"].Fn(context) }()
+
+		var err error
+		Eventually(done, 5).Should(Receive(&err))
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("timed out"))
+	})
+})
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package workloads
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Starts the command in a process group of its own, so that killProcessGroup
+// also kills any children it started.
+func newProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,14 @@
+package workloads
+
+import (
+	"os/exec"
+)
+
+// Windows has no process groups to kill at once, so only the command itself
+// is killed; any children it started are left running.
+func newProcessGroup(cmd *exec.Cmd) {
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,220 @@
+package workloads
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cloudfoundry-community/pat/config"
+	"github.com/nu7hatch/gouuid"
+)
+
+// A Fixture is set up before an experiment's first iteration and torn down
+// after its last, outside of the experiment's timings.
+type Fixture interface {
+	Setup() error
+	Teardown() error
+}
+
+// With rest:fixture set, the rest workload creates an org with its own quota,
+// the rest:space space and rest:fixture-users users before each experiment,
+// and deletes them all when it finishes. While the fixture exists rest:login
+// and rest3:login target the space in that org and log in as the created
+// users. Experiments running at the same time share one fixture, which is
+// deleted when the last of them finishes.
+type fixtureConfig struct {
+	enabled   bool
+	users     int
+	memory    int
+	refs      int
+	err       error
+	quotaGuid string
+	orgGuid   string
+	spaceGuid string
+	userIds   []string
+	created   []credentials
+	lock      sync.Mutex
+}
+
+func (f *fixtureConfig) DescribeParameters(config config.Config) {
+	config.BoolVar(&f.enabled, "rest:fixture", false, "create an org, quota, space and users before each experiment and delete them afterwards (rest:username must be an admin)")
+	config.IntVar(&f.users, "rest:fixture-users", 0, "number of users rest:fixture creates and rest:login logs in as (default: log in as rest:username)")
+	config.IntVar(&f.memory, "rest:fixture-memory", 10240, "memory limit (in MB) of the quota rest:fixture creates")
+}
+
+// The guid of the fixture's org, or "" if there is no fixture.
+func (f *fixtureConfig) org() string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.orgGuid
+}
+
+// The users created by the fixture.
+func (f *fixtureConfig) createdUsers() []credentials {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.created
+}
+
+func (r *rest) Setup() error {
+	f := r.fixture
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.refs++
+	if f.refs == 1 {
+		f.err = r.createFixture()
+	}
+
+	return f.err
+}
+
+// Deletes everything the fixture created, including anything a failed Setup
+// left behind.
+func (r *rest) Teardown() error {
+	f := r.fixture
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.refs--
+	if f.refs > 0 {
+		return nil
+	}
+
+	defer func() {
+		f.err, f.quotaGuid, f.orgGuid, f.spaceGuid, f.userIds, f.created = nil, "", "", "", nil, nil
+	}()
+
+	if f.quotaGuid == "" && f.orgGuid == "" && len(f.userIds) == 0 {
+		return nil
+	}
+
+	ctx, err := r.adminContext()
+	if err != nil {
+		return err
+	}
+
+	var failed error
+	remove := func(url string) {
+		err := r.DeleteSuccessfully(ctx["token"].(string), url, nil, nil, func(reply Reply) error {
+			return nil
+		})
+		if failed == nil {
+			failed = err
+		}
+	}
+
+	if f.orgGuid != "" {
+		remove(fmt.Sprintf("%s/v2/organizations/%s?recursive=true", ctx["apiEndpoint"], f.orgGuid))
+	}
+	if f.quotaGuid != "" {
+		remove(fmt.Sprintf("%s/v2/quota_definitions/%s", ctx["apiEndpoint"], f.quotaGuid))
+	}
+	for _, id := range f.userIds {
+		remove(fmt.Sprintf("%s/v2/users/%s", ctx["apiEndpoint"], id))
+		remove(fmt.Sprintf("%s/Users/%s", ctx["uaaEndpoint"], id))
+	}
+
+	return failed
+}
+
+// Called with the fixture lock held. Records each guid as soon as it is
+// created so that Teardown can remove it.
+func (r *rest) createFixture() error {
+	f := r.fixture
+	ctx, err := r.adminContext()
+	if err != nil {
+		return err
+	}
+
+	token := ctx["token"].(string)
+	id, _ := uuid.NewV4()
+	name := "pat-" + id.String()
+
+	quota := map[string]interface{}{
+		"name":                       name,
+		"non_basic_services_allowed": true,
+		"total_services":             -1,
+		"total_routes":               -1,
+		"memory_limit":               f.memory,
+		"instance_memory_limit":      -1,
+	}
+	if f.quotaGuid, err = r.createResource(token, fmt.Sprintf("%s/v2/quota_definitions", ctx["apiEndpoint"]), quota); err != nil {
+		return err
+	}
+
+	org := map[string]interface{}{"name": name, "quota_definition_guid": f.quotaGuid}
+	if f.orgGuid, err = r.createResource(token, fmt.Sprintf("%s/v2/organizations", ctx["apiEndpoint"]), org); err != nil {
+		return err
+	}
+
+	space := map[string]interface{}{"name": r.space_name, "organization_guid": f.orgGuid}
+	if f.spaceGuid, err = r.createResource(token, fmt.Sprintf("%s/v2/spaces", ctx["apiEndpoint"]), space); err != nil {
+		return err
+	}
+
+	for i := 1; i <= f.users; i++ {
+		password, _ := uuid.NewV4()
+		user := credentials{fmt.Sprintf("%s-user-%d", name, i), password.String()}
+		if err := r.createFixtureUser(ctx, user); err != nil {
+			return err
+		}
+		f.created = append(f.created, user)
+	}
+
+	return nil
+}
+
+// Creates the user in UAA and the Cloud Controller, and makes it a developer
+// in the fixture's space.
+func (r *rest) createFixtureUser(ctx map[string]interface{}, user credentials) error {
+	f := r.fixture
+	token := ctx["token"].(string)
+	ignore := func(reply Reply) error {
+		return nil
+	}
+
+	scim := map[string]interface{}{
+		"userName": user.username,
+		"password": user.password,
+		"emails":   []map[string]string{{"value": user.username + "@pat.invalid"}},
+	}
+	body := &ScimUserResponse{}
+	if err := r.PostSuccessfully(token, fmt.Sprintf("%s/Users", ctx["uaaEndpoint"]), scim, body, ignore); err != nil {
+		return err
+	}
+	f.userIds = append(f.userIds, body.Id)
+
+	if err := r.PostSuccessfully(token, fmt.Sprintf("%s/v2/users", ctx["apiEndpoint"]), map[string]string{"guid": body.Id}, nil, ignore); err != nil {
+		return err
+	}
+
+	if err := r.PutSuccessfully(token, fmt.Sprintf("%s/v2/organizations/%s/users/%s", ctx["apiEndpoint"], f.orgGuid, body.Id), nil, nil, ignore); err != nil {
+		return err
+	}
+
+	return r.PutSuccessfully(token, fmt.Sprintf("%s/v2/spaces/%s/developers/%s", ctx["apiEndpoint"], f.spaceGuid, body.Id), nil, nil, ignore)
+}
+
+func (r *rest) createResource(token string, url string, input interface{}) (string, error) {
+	body := &NamedResource{}
+	err := r.PostSuccessfully(token, url, input, body, func(reply Reply) error {
+		return nil
+	})
+	return body.Metadata.Guid, err
+}
+
+// Targets rest:target and logs in as rest:username, whatever rest:users and
+// the fixture's users say, to create and delete the fixture.
+func (r *rest) adminContext() (map[string]interface{}, error) {
+	ctx := make(map[string]interface{})
+	if err := r.Target(ctx); err != nil {
+		return nil, err
+	}
+
+	admin := credentials{r.username, r.password}
+	if err := r.requestToken(ctx, ctx["loginEndpoint"].(string), r.uaa.clientId+":"+r.username, r.uaa.grantInputs(admin)); err != nil {
+		return nil, err
+	}
+
+	return ctx, nil
+}
@@ -0,0 +1,141 @@
+package workloads_test
+
+import (
+	"net/url"
+
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Org and space fixtures", func() {
+	var (
+		client  *dummyClient
+		rest    workloads
+		fixture Fixture
+		args    []string
+		replies map[string]interface{}
+		context map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		args = []string{"-rest:fixture", "-rest:fixture-users", "2"}
+	})
+
+	JustBeforeEach(func() {
+		replies = make(map[string]interface{})
+		client = &dummyClient{replies, make(map[string]string), make(map[call]interface{})}
+		rest = NewRestWorkloadWithClient(client)
+		fixture = rest.(Fixture)
+		config := config.NewConfig()
+		rest.DescribeParameters(config)
+		Ω(config.Parse(append([]string{"-rest:target", "APISERVER", "-rest:username", "admin", "-rest:password", "pass"}, args...))).ShouldNot(HaveOccurred())
+		context = make(map[string]interface{})
+
+		replies["APISERVER/v2/info"] = TargetResponse{LoginEndpoint: "THELOGINSERVER/PATH", UaaEndpoint: "THEUAASERVER"}
+		replies["THELOGINSERVER/PATH/oauth/token"] = TokenResponse{"the-token", "", 3600}
+		replies["APISERVER/v2/quota_definitions"] = namedResource("THE-QUOTA", "")
+		replies["APISERVER/v2/organizations"] = namedResource("THE-ORG", "")
+		replies["APISERVER/v2/spaces"] = namedResource("THE-SPACE", "")
+		replies["THEUAASERVER/Users"] = ScimUserResponse{"THE-USER"}
+		replies["APISERVER/v2/users"] = ""
+		replies["APISERVER/v2/organizations/THE-ORG/users/THE-USER"] = ""
+		replies["APISERVER/v2/spaces/THE-SPACE/developers/THE-USER"] = ""
+		replies["APISERVER/v2/spaces?q=name:dev&q=organization_guid:THE-ORG"] = SpaceResponse{[]Resource{Resource{Metadata{"THE-SPACE"}}}}
+	})
+
+	Describe("Setting up", func() {
+		JustBeforeEach(func() {
+			Ω(fixture.Setup()).ShouldNot(HaveOccurred())
+		})
+
+		It("Creates a quota and an org which uses it", func() {
+			quota := mapOf(client.ShouldHaveBeenCalledWith("POST", "APISERVER/v2/quota_definitions"))
+			Ω(quota["name"]).Should(HavePrefix("pat-"))
+			Ω(quota["memory_limit"]).Should(BeEquivalentTo(10240))
+
+			org := mapOf(client.ShouldHaveBeenCalledWith("POST", "APISERVER/v2/organizations"))
+			Ω(org["name"]).Should(Equal(quota["name"]))
+			Ω(org["quota_definition_guid"]).Should(Equal("THE-QUOTA"))
+		})
+
+		It("Creates the rest:space space in the org", func() {
+			space := mapOf(client.ShouldHaveBeenCalledWith("POST", "APISERVER/v2/spaces"))
+			Ω(space["name"]).Should(Equal("dev"))
+			Ω(space["organization_guid"]).Should(Equal("THE-ORG"))
+		})
+
+		It("Creates users and makes them developers in the space", func() {
+			user := mapOf(client.ShouldHaveBeenCalledWith("POST", "THEUAASERVER/Users"))
+			Ω(user["userName"]).Should(ContainSubstring("-user-"))
+			Ω(mapOf(client.ShouldHaveBeenCalledWith("POST", "APISERVER/v2/users"))["guid"]).Should(Equal("THE-USER"))
+			client.ShouldHaveBeenCalledWith("PUT", "APISERVER/v2/organizations/THE-ORG/users/THE-USER")
+			client.ShouldHaveBeenCalledWith("PUT", "APISERVER/v2/spaces/THE-SPACE/developers/THE-USER")
+		})
+
+		It("Logs in as the created users and targets the space in the org", func() {
+			Ω(rest.Target(context)).ShouldNot(HaveOccurred())
+			Ω(rest.Login(context)).ShouldNot(HaveOccurred())
+			login := client.ShouldHaveBeenCalledWith("POST(uaa)", "THELOGINSERVER/PATH/oauth/token").(url.Values)
+			Ω(login["username"][0]).Should(ContainSubstring("-user-"))
+			Ω(context["space_guid"]).Should(Equal("THE-SPACE"))
+		})
+
+		Describe("And tearing down", func() {
+			JustBeforeEach(func() {
+				replies["APISERVER/v2/organizations/THE-ORG?recursive=true"] = ""
+				replies["APISERVER/v2/quota_definitions/THE-QUOTA"] = ""
+				replies["APISERVER/v2/users/THE-USER"] = ""
+				replies["THEUAASERVER/Users/THE-USER"] = ""
+				Ω(fixture.Teardown()).ShouldNot(HaveOccurred())
+			})
+
+			It("Deletes the org and everything in it, the quota and the users", func() {
+				client.ShouldHaveBeenCalledWith("DELETE", "APISERVER/v2/organizations/THE-ORG?recursive=true")
+				client.ShouldHaveBeenCalledWith("DELETE", "APISERVER/v2/quota_definitions/THE-QUOTA")
+				client.ShouldHaveBeenCalledWith("DELETE", "APISERVER/v2/users/THE-USER")
+				client.ShouldHaveBeenCalledWith("DELETE", "THEUAASERVER/Users/THE-USER")
+			})
+
+			It("Goes back to logging in as rest:username", func() {
+				replies["APISERVER/v2/spaces?q=name:dev"] = SpaceResponse{[]Resource{Resource{Metadata{"OTHER-SPACE"}}}}
+				Ω(rest.Target(context)).ShouldNot(HaveOccurred())
+				Ω(rest.Login(context)).ShouldNot(HaveOccurred())
+				login := client.ShouldHaveBeenCalledWith("POST(uaa)", "THELOGINSERVER/PATH/oauth/token").(url.Values)
+				Ω(login["username"]).Should(Equal([]string{"admin"}))
+				Ω(context["space_guid"]).Should(Equal("OTHER-SPACE"))
+			})
+		})
+
+		Context("When another experiment shares the fixture", func() {
+			JustBeforeEach(func() {
+				delete(client.calls, call{"POST", "APISERVER/v2/organizations"})
+				Ω(fixture.Setup()).ShouldNot(HaveOccurred())
+			})
+
+			It("Does not create another org", func() {
+				Ω(client.calls).ShouldNot(HaveKey(call{"POST", "APISERVER/v2/organizations"}))
+			})
+
+			It("Only deletes the org when the last experiment tears it down", func() {
+				replies["APISERVER/v2/organizations/THE-ORG?recursive=true"] = ""
+				Ω(fixture.Teardown()).ShouldNot(HaveOccurred())
+				Ω(client.calls).ShouldNot(HaveKey(call{"DELETE", "APISERVER/v2/organizations/THE-ORG?recursive=true"}))
+			})
+		})
+	})
+
+	Context("When the org cannot be created", func() {
+		JustBeforeEach(func() {
+			delete(replies, "APISERVER/v2/organizations")
+			replies["APISERVER/v2/quota_definitions/THE-QUOTA"] = ""
+		})
+
+		It("Returns an error, and tearing down deletes the quota", func() {
+			Ω(fixture.Setup()).Should(HaveOccurred())
+			Ω(fixture.Teardown()).ShouldNot(HaveOccurred())
+			client.ShouldHaveBeenCalledWith("DELETE", "APISERVER/v2/quota_definitions/THE-QUOTA")
+		})
+	})
+})
@@ -3,6 +3,7 @@ package workloads
 import (
 	"errors"
 	"math/rand"
+	"os"
 	"time"
 
 	"github.com/nu7hatch/gouuid"
@@ -31,6 +32,29 @@ func DummyWithErrors() error {
 
 func Push() error {
 	guid, _ := uuid.NewV4()
-	err := Cf("push", "pats-"+guid.String(), "patsapp", "-m", "64M", "-p", "assets/hello-world").ExpectOutput("App started")
-	return err
+	name := "pats-" + guid.String()
+
+	path, err := pushedApp.cliPath()
+	if err != nil {
+		return err
+	}
+	if pushedApp.size > 0 {
+		defer os.Remove(path)
+	}
+
+	env := pushedApp.environment()
+	args := pushedApp.cliArgs(name, path)
+	if len(env) == 0 {
+		return Cf(args...).ExpectOutput("App started")
+	}
+
+	if err := Cf(append(args, "--no-start")...).ExpectOutput("OK"); err != nil {
+		return err
+	}
+	for key, value := range env {
+		if err := Cf("set-env", name, key, value).ExpectOutput("OK"); err != nil {
+			return err
+		}
+	}
+	return Cf("start", name).ExpectOutput("App started")
 }
@@ -0,0 +1,215 @@
+package workloads
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/config"
+)
+
+// An HTTP request described in the "http" section of the configuration
+// file. Each one is registered as an http:<name> workload step. Url,
+// Headers and Body may refer to values in the step context as {{key}},
+// and Extract copies values out of a JSON response (by dotted path, e.g.
+// resources.0.metadata.guid) into the context for later steps. Requests
+// are made with the rest workload's TLS and proxy settings and time out
+// after Timeout seconds (by default rest:request-timeout, or 60).
+type HttpStep struct {
+	Method  string            `yaml:"method"`
+	Url     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+	Status  int               `yaml:"status"`
+	Extract map[string]string `yaml:"extract"`
+	Timeout int               `yaml:"timeout"`
+}
+
+const defaultHttpStepTimeout = 60 * time.Second
+
+type httpWorkload struct {
+	steps     map[string]HttpStep
+	transport *transportConfig
+}
+
+func NewHttpWorkload(r *rest) *httpWorkload {
+	return &httpWorkload{transport: r.transport}
+}
+
+func (h *httpWorkload) DescribeParameters(config config.Config) {
+	h.steps = make(map[string]HttpStep)
+	config.SectionVar(&h.steps, "http", "HTTP requests to register as http:<name> workload steps")
+}
+
+func (h *httpWorkload) Steps() []WorkloadStep {
+	names := make([]string, 0, len(h.steps))
+	for name := range h.steps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	steps := make([]WorkloadStep, 0, len(names))
+	for _, name := range names {
+		step := h.steps[name]
+		steps = append(steps, StepWithContext("http:"+name, h.run(step), fmt.Sprintf("%s %s", step.method(), step.Url)))
+	}
+
+	return steps
+}
+
+func (h *httpWorkload) run(step HttpStep) func(map[string]interface{}) error {
+	return func(ctx map[string]interface{}) error {
+		timeout := time.Duration(step.Timeout) * time.Second
+		if timeout == 0 {
+			timeout = time.Duration(h.transport.timeout) * time.Second
+		}
+		if timeout == 0 {
+			timeout = defaultHttpStepTimeout
+		}
+
+		client, err := h.transport.clientWithTimeout(ctx, timeout)
+		if err != nil {
+			return err
+		}
+
+		return step.Run(ctx, client)
+	}
+}
+
+func (s HttpStep) method() string {
+	if s.Method == "" {
+		return "GET"
+	}
+
+	return strings.ToUpper(s.Method)
+}
+
+func (s HttpStep) Run(ctx map[string]interface{}, client *http.Client) error {
+	url, err := expand(s.Url, ctx)
+	if err != nil {
+		return err
+	}
+
+	var body io.Reader
+	if s.Body != "" {
+		expanded, err := expand(s.Body, ctx)
+		if err != nil {
+			return err
+		}
+		body = strings.NewReader(expanded)
+	}
+
+	req, err := http.NewRequest(s.method(), url, body)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range s.Headers {
+		expanded, err := expand(value, ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(name, expanded)
+	}
+
+	var result error
+	if err := traceRequest(requestLog(ctx), client, req, func(resp *http.Response) {
+		result = s.handle(resp, ctx)
+	}); err != nil {
+		return err
+	}
+
+	return result
+}
+
+// Checks the response's status and extracts values from it.
+func (s HttpStep) handle(resp *http.Response, ctx map[string]interface{}) error {
+	if err := s.checkStatus(resp); err != nil {
+		return err
+	}
+
+	if len(s.Extract) == 0 {
+		return nil
+	}
+
+	var decoded interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return errors.New("Could not decode JSON response: " + err.Error())
+	}
+
+	for key, path := range s.Extract {
+		value, err := lookup(decoded, path)
+		if err != nil {
+			return err
+		}
+		ctx[key] = value
+	}
+
+	return nil
+}
+
+func (s HttpStep) checkStatus(resp *http.Response) error {
+	if s.Status == 0 {
+		return Reply{resp.StatusCode, resp.Status, ""}.checkError()
+	}
+
+	if resp.StatusCode != s.Status {
+		detail, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Expected status %d but got %s: %s", s.Status, resp.Status, strings.TrimSpace(string(detail)))
+	}
+
+	return nil
+}
+
+var placeholder = regexp.MustCompile(`{{\s*([^}\s]+)\s*}}`)
+
+// Replaces each {{key}} in template with ctx[key].
+func expand(template string, ctx map[string]interface{}) (string, error) {
+	var missing error
+	expanded := placeholder.ReplaceAllStringFunc(template, func(match string) string {
+		key := placeholder.FindStringSubmatch(match)[1]
+		value, ok := ctx[key]
+		if !ok {
+			missing = fmt.Errorf("No value for {{%s}} in the workload context", key)
+			return match
+		}
+
+		return fmt.Sprint(value)
+	})
+
+	return expanded, missing
+}
+
+// Finds the value at a dotted path (with an optional leading "$.") in a
+// decoded JSON document. Numeric segments index into arrays.
+func lookup(doc interface{}, path string) (interface{}, error) {
+	current := doc
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, fmt.Errorf("No '%s' in response", path)
+			}
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("No '%s' in response", path)
+			}
+			current = node[i]
+		default:
+			return nil, fmt.Errorf("No '%s' in response", path)
+		}
+	}
+
+	return current, nil
+}
@@ -0,0 +1,137 @@
+package workloads_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTP Workloads", func() {
+	var (
+		server   *httptest.Server
+		secure   *httptest.Server
+		received *http.Request
+		body     string
+		steps    map[string]WorkloadStep
+		context  map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			b, _ := ioutil.ReadAll(r.Body)
+			body = string(b)
+			if r.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.URL.Path == "/slow" {
+				time.Sleep(1500 * time.Millisecond)
+			}
+			fmt.Fprint(w, `{"resources": [{"metadata": {"guid": "first-guid"}}], "total_results": 1}`)
+		}))
+		secure = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{}`)
+		}))
+
+		ioutil.WriteFile("/tmp/http-steps.yml", []byte(`
+http:
+  list-apps:
+    url: "{{apiEndpoint}}/v2/apps"
+    headers:
+      Authorization: "bearer {{token}}"
+    extract:
+      first_app: resources.0.metadata.guid
+      count: $.total_results
+  create:
+    method: post
+    url: "{{apiEndpoint}}/v2/apps"
+    body: '{"name": "{{name}}"}'
+    status: 201
+  missing:
+    url: "{{apiEndpoint}}/missing"
+  slow:
+    url: "{{apiEndpoint}}/slow"
+    timeout: 1
+  secure:
+    url: "{{secureEndpoint}}"
+rest:
+  skip-ssl-validation: true
+`), 0755)
+
+		rest := NewRestWorkload()
+		workload := NewHttpWorkload(rest)
+		c := config.NewConfig()
+		rest.DescribeParameters(c)
+		workload.DescribeParameters(c)
+		Ω(c.Parse([]string{"-config", "/tmp/http-steps.yml"})).ShouldNot(HaveOccurred())
+
+		steps = make(map[string]WorkloadStep)
+		for _, step := range workload.Steps() {
+			steps[step.Name] = step
+		}
+
+		context = map[string]interface{}{"apiEndpoint": server.URL, "secureEndpoint": secure.URL, "token": "a-token", "name": "an-app"}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		secure.Close()
+	})
+
+	It("Registers a step for each request", func() {
+		Ω(steps).Should(HaveLen(5))
+		Ω(steps).Should(HaveKey("http:list-apps"))
+		Ω(steps["http:create"].Description).Should(Equal("POST {{apiEndpoint}}/v2/apps"))
+	})
+
+	It("Substitutes context values into the url and headers", func() {
+		Ω(steps["http:list-apps"].Fn(context)).ShouldNot(HaveOccurred())
+		Ω(received.Method).Should(Equal("GET"))
+		Ω(received.URL.Path).Should(Equal("/v2/apps"))
+		Ω(received.Header.Get("Authorization")).Should(Equal("bearer a-token"))
+	})
+
+	It("Extracts values from the JSON response into the context", func() {
+		steps["http:list-apps"].Fn(context)
+		Ω(context["first_app"]).Should(Equal("first-guid"))
+		Ω(context["count"]).Should(BeNumerically("==", 1))
+	})
+
+	It("Sends the templated body", func() {
+		steps["http:create"].Fn(context)
+		Ω(received.Method).Should(Equal("POST"))
+		Ω(body).Should(Equal(`{"name": "an-app"}`))
+	})
+
+	It("Returns an error when the status is not the expected one", func() {
+		err := steps["http:create"].Fn(context)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("Expected status 201"))
+	})
+
+	It("Returns an error for error statuses when none is expected", func() {
+		Ω(steps["http:missing"].Fn(context)).Should(HaveOccurred())
+	})
+
+	It("Uses the rest workload's TLS settings", func() {
+		Ω(steps["http:secure"].Fn(context)).ShouldNot(HaveOccurred())
+	})
+
+	It("Returns an error when the request takes longer than its timeout", func() {
+		err := steps["http:slow"].Fn(context)
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("Returns an error when a context value is missing", func() {
+		delete(context, "token")
+		Ω(steps["http:list-apps"].Fn(context)).Should(HaveOccurred())
+	})
+})
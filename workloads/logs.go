@@ -0,0 +1,159 @@
+package workloads
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/nu7hatch/gouuid"
+)
+
+// The log steps measure how quickly an app's logs reach its developers.
+// rest:tail-logs opens the app's stream on the Doppler endpoint advertised by
+// rest:target (or rest3:target), rest:log-marker requests the app's route
+// with a unique marker in the query string and waits for the marker to come
+// through the stream, and rest:recent-logs fetches the app's recent logs. The
+// marker shows up in the router's access log line for the request, and is
+// also printed by the generated app.
+
+const logMarkerPrefix = "pat-marker-"
+
+var logMarker = regexp.MustCompile(logMarkerPrefix + "[0-9a-f-]{36}")
+
+type logStream struct {
+	conn    *websocket.Conn
+	markers chan string
+}
+
+func (r *rest) TailLogs(ctx map[string]interface{}) error {
+	return checkLogs(ctx, func(token string, doppler string, appGuid string) error {
+		config, err := websocket.NewConfig(fmt.Sprintf("%s/apps/%s/stream", doppler, appGuid), "http://localhost/")
+		if err != nil {
+			return err
+		}
+		config.Header.Set("Authorization", "bearer "+token)
+		if config.TlsConfig, err = r.transport.tls(); err != nil {
+			return err
+		}
+
+		conn, err := websocket.DialConfig(config)
+		if err != nil {
+			return err
+		}
+
+		stream := &logStream{conn, make(chan string, 16)}
+		go stream.read()
+		time.AfterFunc(time.Duration(r.logTimeout)*time.Second, func() {
+			conn.Close()
+		})
+
+		ctx["log_stream"] = stream
+		return nil
+	})
+}
+
+// Passes on the markers found in the stream's messages, which are
+// protobuf-encoded envelopes with the log line as plain bytes inside.
+func (s *logStream) read() {
+	defer close(s.markers)
+	for {
+		var message []byte
+		if err := websocket.Message.Receive(s.conn, &message); err != nil {
+			return
+		}
+
+		for _, marker := range logMarker.FindAll(message, -1) {
+			select {
+			case s.markers <- string(marker):
+			default:
+			}
+		}
+	}
+}
+
+func (r *rest) LogMarker(ctx map[string]interface{}) error {
+	stream, ok := ctx["log_stream"].(*logStream)
+	if !ok {
+		return errors.New("Not tailing the app's logs")
+	}
+
+	if ctx["app_url"] == nil {
+		return errors.New("No route has been created")
+	}
+
+	client, err := r.transport.clientFor(r.iteration)
+	if err != nil {
+		return err
+	}
+
+	id, _ := uuid.NewV4()
+	marker := logMarkerPrefix + id.String()
+	req, err := http.NewRequest("GET", ctx["app_url"].(string)+"/?"+marker, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := traceRequest(r.requests, client, req, func(resp *http.Response) {}); err != nil {
+		return err
+	}
+
+	timeout := time.After(time.Duration(r.logTimeout) * time.Second)
+	for {
+		select {
+		case arrived, ok := <-stream.markers:
+			if !ok {
+				return errors.New("The log stream closed before the marker arrived")
+			}
+			if arrived == marker {
+				stream.conn.Close()
+				delete(ctx, "log_stream")
+				return nil
+			}
+		case <-timeout:
+			return errors.New("Timed out waiting for the marker in the app's logs")
+		}
+	}
+}
+
+func (r *rest) RecentLogs(ctx map[string]interface{}) error {
+	return checkLogs(ctx, func(token string, doppler string, appGuid string) error {
+		return r.GetSuccessfully(token, fmt.Sprintf("%s/apps/%s/recentlogs", httpScheme(doppler), appGuid), nil, nil, func(reply Reply) error {
+			return nil
+		})
+	})
+}
+
+// The doppler endpoint is advertised as a websocket URL.
+func httpScheme(endpoint string) string {
+	if strings.HasPrefix(endpoint, "ws") {
+		return "http" + strings.TrimPrefix(endpoint, "ws")
+	}
+
+	return endpoint
+}
+
+// Calls then with the token, the Doppler endpoint and the guid of the app
+// pushed by rest:push or rest3:push.
+func checkLogs(ctx map[string]interface{}, then func(token string, doppler string, appGuid string) error) error {
+	return checkLoggedIn(ctx, func(token string) error {
+		doppler, _ := ctx["dopplerEndpoint"].(string)
+		if doppler == "" {
+			return errors.New("No doppler endpoint advertised by the target")
+		}
+
+		if appGuid, ok := ctx["app_guid"].(string); ok {
+			return then(token, doppler, appGuid)
+		}
+
+		if appUri, ok := ctx["app_uri"].(string); ok {
+			return then(token, doppler, path.Base(appUri))
+		}
+
+		return errors.New("No app has been pushed")
+	})
+}
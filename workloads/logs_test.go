@@ -0,0 +1,134 @@
+package workloads_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type logWorkloads interface {
+	workloads
+	TailLogs(ctx map[string]interface{}) error
+	LogMarker(ctx map[string]interface{}) error
+	RecentLogs(ctx map[string]interface{}) error
+}
+
+var _ = Describe("Rest Log Workloads", func() {
+	var (
+		client   *dummyClient
+		rest     logWorkloads
+		replies  map[string]interface{}
+		context  map[string]interface{}
+		doppler  *httptest.Server
+		app      *httptest.Server
+		streamed chan *http.Request
+		deliver  bool
+	)
+
+	BeforeEach(func() {
+		deliver = true
+	})
+
+	JustBeforeEach(func() {
+		// The handlers keep their own copies, as a stream left open by one spec
+		// may still be served while the next one runs.
+		requests := make(chan string, 1)
+		streamed = make(chan *http.Request, 1)
+		streamed := streamed
+		deliver := deliver
+
+		app = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests <- r.URL.RawQuery
+		}))
+
+		doppler = httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+			streamed <- ws.Request()
+			websocket.Message.Send(ws, []byte("\x0a\x05noise"))
+			select {
+			case marker := <-requests:
+				if deliver {
+					websocket.Message.Send(ws, []byte("\x0a\x20GET /?"+marker+" HTTP/1.1\x10\x01"))
+				}
+			case <-time.After(2 * time.Second):
+			}
+			ws.Read(make([]byte, 1))
+		}))
+
+		replies = make(map[string]interface{})
+		client = &dummyClient{replies, make(map[string]string), make(map[call]interface{})}
+		rest = NewRestWorkloadWithClient(client)
+		config := config.NewConfig()
+		rest.DescribeParameters(config)
+		config.Parse([]string{"-rest:target", "APISERVER", "-rest:log-timeout", "1"})
+
+		replies["APISERVER/v2/info"] = TargetResponse{LoginEndpoint: "THELOGINSERVER/PATH", DopplerEndpoint: "ws://" + strings.TrimPrefix(doppler.URL, "http://")}
+		replies["THELOGINSERVER/PATH/oauth/token"] = LoginResponse{"THE-TOKEN"}
+		replies["APISERVER/v2/spaces?q=name:dev"] = SpaceResponse{[]Resource{Resource{Metadata{"THE-SPACE"}}}}
+
+		context = make(map[string]interface{})
+		rest.Target(context)
+		rest.Login(context)
+		context["app_uri"] = "/v2/apps/THE-APP"
+		context["app_url"] = app.URL
+	})
+
+	AfterEach(func() {
+		doppler.Close()
+		app.Close()
+	})
+
+	It("Streams the app's logs with the user's token", func() {
+		Ω(rest.TailLogs(context)).ShouldNot(HaveOccurred())
+		var request *http.Request
+		Eventually(streamed).Should(Receive(&request))
+		Ω(request.URL.Path).Should(Equal("/apps/THE-APP/stream"))
+		Ω(request.Header.Get("Authorization")).Should(Equal("bearer THE-TOKEN"))
+	})
+
+	It("Waits for the marker to arrive in the app's logs", func() {
+		Ω(rest.TailLogs(context)).ShouldNot(HaveOccurred())
+		Ω(rest.LogMarker(context)).ShouldNot(HaveOccurred())
+		Ω(context).ShouldNot(HaveKey("log_stream"))
+	})
+
+	Context("When the marker never arrives", func() {
+		BeforeEach(func() {
+			deliver = false
+		})
+
+		It("Returns an error after rest:log-timeout", func() {
+			Ω(rest.TailLogs(context)).ShouldNot(HaveOccurred())
+			start := time.Now()
+			Ω(rest.LogMarker(context)).Should(HaveOccurred())
+			Ω(time.Since(start)).Should(BeNumerically("<", 3*time.Second))
+		})
+	})
+
+	It("Requires the logs to be tailed before the marker is sent", func() {
+		Ω(rest.LogMarker(context)).Should(HaveOccurred())
+	})
+
+	It("Gets the recent logs over HTTP", func() {
+		replies[doppler.URL+"/apps/THE-APP/recentlogs"] = ""
+		Ω(rest.RecentLogs(context)).ShouldNot(HaveOccurred())
+		client.ShouldHaveBeenCalledWith("GET", doppler.URL+"/apps/THE-APP/recentlogs")
+	})
+
+	Context("When the target does not advertise a doppler endpoint", func() {
+		JustBeforeEach(func() {
+			context["dopplerEndpoint"] = ""
+		})
+
+		It("Returns an error", func() {
+			Ω(rest.TailLogs(context)).Should(HaveOccurred())
+			Ω(rest.RecentLogs(context)).Should(HaveOccurred())
+		})
+	})
+})
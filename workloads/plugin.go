@@ -0,0 +1,402 @@
+package workloads
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/config"
+)
+
+// Plugins are executables in the plugins directory (PAT_PLUGINS_DIR, or
+// ./plugins) which provide workload steps. PAT talks to each one over its
+// stdin and stdout, one JSON-RPC 2.0 message per line:
+//
+//   -> {"jsonrpc":"2.0","id":1,"method":"describe"}
+//   <- {"id":1,"result":{"steps":[{"name":"myapi:get","description":"..."}],
+//                        "parameters":[{"name":"myapi:url","default":"","description":"..."}]}}
+//   -> {"jsonrpc":"2.0","id":2,"method":"run","params":{"step":"myapi:get","context":{...},"parameters":{"myapi:url":"..."}}}
+//   <- {"id":2,"result":{"context":{...}}}    or    {"id":2,"error":{"message":"..."}}
+//
+// A plugin's steps and parameters are registered as <plugin>:<name>, where
+// <plugin> is its file name without any extension; names which already have
+// that prefix are kept as they are. Plugins named after the built-in
+// workloads (see builtinNamespaces) are not loaded.
+//
+// Only the string, number and boolean values of the step context are sent.
+// The values in the context returned by run are merged into the step
+// context, except that a plugin may not replace the values PAT keeps for
+// itself (see internalContextKeys). A plugin process handles one request at
+// a time; more are started as concurrent workers need them. A process which
+// does not answer within the plugin timeout is killed, and a new one is
+// started for the next request.
+
+const defaultPluginsDir = "plugins"
+
+const defaultPluginTimeout = 60
+
+// The prefixes of the built-in steps and parameters, which plugins may not use.
+var builtinNamespaces = []string{"rest", "rest3", "gcf", "app", "http", "exec"}
+
+// Context values set by PAT rather than by steps.
+var internalContextKeys = []string{"worker", "requests", "log_stream", iterationClientKey}
+
+type pluginStep struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type pluginParameter struct {
+	Name        string `json:"name"`
+	Default     string `json:"default"`
+	Description string `json:"description"`
+}
+
+type pluginDescription struct {
+	Steps      []pluginStep      `json:"steps"`
+	Parameters []pluginParameter `json:"parameters"`
+}
+
+type pluginRequest struct {
+	Id      int         `json:"id"`
+	JsonRpc string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type pluginResponse struct {
+	Id     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type pluginRun struct {
+	Step       string                 `json:"step"`
+	Context    map[string]interface{} `json:"context"`
+	Parameters map[string]string      `json:"parameters"`
+}
+
+type pluginRunResult struct {
+	Context map[string]interface{} `json:"context"`
+}
+
+type plugin struct {
+	path        string
+	namespace   string
+	timeout     *int
+	description pluginDescription
+	parameters  map[string]*string
+	idle        chan *pluginProcess
+}
+
+type pluginProcess struct {
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	out    *bufio.Reader
+	nextId int
+}
+
+type pluginWorkload struct {
+	dir        string
+	discovered bool
+	plugins    []*plugin
+	timeout    int
+	lock       sync.Mutex
+}
+
+func NewPluginWorkload(dir string) *pluginWorkload {
+	return &pluginWorkload{dir: dir, timeout: defaultPluginTimeout}
+}
+
+func pluginsDir() string {
+	if dir := os.Getenv("PAT_PLUGINS_DIR"); dir != "" {
+		return dir
+	}
+
+	return defaultPluginsDir
+}
+
+// Discovers the plugins (the first time it is called) and registers their
+// parameters.
+func (w *pluginWorkload) DescribeParameters(config config.Config) {
+	w.discover()
+	config.IntVar(&w.timeout, "plugin-timeout", defaultPluginTimeout, "seconds to wait for a plugin to answer a request before restarting it (0 for no limit)")
+	for _, p := range w.plugins {
+		for _, param := range p.description.Parameters {
+			config.StringVar(p.parameters[param.Name], p.qualified(param.Name), param.Default, param.Description)
+		}
+	}
+}
+
+func (w *pluginWorkload) Steps() []WorkloadStep {
+	steps := make([]WorkloadStep, 0)
+	for _, p := range w.plugins {
+		for _, s := range p.description.Steps {
+			steps = append(steps, StepWithContext(p.qualified(s.Name), p.runner(s.Name), s.Description))
+		}
+	}
+
+	return steps
+}
+
+func (w *pluginWorkload) discover() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.discovered {
+		return
+	}
+	w.discovered = true
+
+	files, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return
+	}
+
+	names := make([]string, 0)
+	for _, f := range files {
+		if f.Mode().IsRegular() && f.Mode()&0111 != 0 {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	namespaces := append([]string{}, builtinNamespaces...)
+	for _, name := range names {
+		namespace := strings.TrimSuffix(name, filepath.Ext(name))
+		if contains(namespaces, namespace) {
+			log.Printf("Could not load plugin %s: the name '%s' is already taken", name, namespace)
+			continue
+		}
+
+		p, err := loadPlugin(filepath.Join(w.dir, name), namespace, &w.timeout)
+		if err != nil {
+			log.Printf("Could not load plugin %s: %s", name, err)
+			continue
+		}
+		w.plugins = append(w.plugins, p)
+		namespaces = append(namespaces, namespace)
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func loadPlugin(path string, namespace string, timeout *int) (*plugin, error) {
+	p := &plugin{path: path, namespace: namespace, timeout: timeout, parameters: make(map[string]*string), idle: make(chan *pluginProcess, 64)}
+	if err := p.call("describe", nil, &p.description); err != nil {
+		return nil, err
+	}
+
+	for _, param := range p.description.Parameters {
+		value := param.Default
+		p.parameters[param.Name] = &value
+	}
+
+	return p, nil
+}
+
+// The name a step or parameter of the plugin is registered under.
+func (p *plugin) qualified(name string) string {
+	if strings.HasPrefix(name, p.namespace+":") {
+		return name
+	}
+
+	return p.namespace + ":" + name
+}
+
+func (p *plugin) runner(step string) func(ctx map[string]interface{}) error {
+	return func(ctx map[string]interface{}) error {
+		parameters := make(map[string]string)
+		for name, value := range p.parameters {
+			parameters[name] = *value
+		}
+
+		result := &pluginRunResult{}
+		if err := p.call("run", pluginRun{step, scalars(ctx), parameters}, result); err != nil {
+			return err
+		}
+
+		for k := range result.Context {
+			if internal(ctx, k) {
+				return fmt.Errorf("%s may not set the '%s' context value", step, k)
+			}
+		}
+
+		for k, v := range result.Context {
+			ctx[k] = v
+		}
+		return nil
+	}
+}
+
+// The values of ctx which can be sent to a plugin as they are.
+func scalars(ctx map[string]interface{}) map[string]interface{} {
+	sent := make(map[string]interface{})
+	for k, v := range ctx {
+		if isScalar(v) {
+			sent[k] = v
+		}
+	}
+
+	return sent
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func internal(ctx map[string]interface{}, key string) bool {
+	for _, k := range internalContextKeys {
+		if k == key {
+			return true
+		}
+	}
+
+	value, ok := ctx[key]
+	return ok && !isScalar(value)
+}
+
+// Makes a request of an idle plugin process, starting one if none is idle.
+func (p *plugin) call(method string, params interface{}, result interface{}) error {
+	var process *pluginProcess
+	select {
+	case process = <-p.idle:
+	default:
+		var err error
+		if process, err = startPluginProcess(p.path); err != nil {
+			return err
+		}
+	}
+
+	err := process.call(method, params, result, time.Duration(*p.timeout)*time.Second)
+	if _, broken := err.(brokenPluginError); broken {
+		process.close()
+		return err
+	}
+
+	select {
+	case p.idle <- process:
+	default:
+		process.close()
+	}
+
+	return err
+}
+
+type brokenPluginError struct {
+	error
+}
+
+func startPluginProcess(path string) (*pluginProcess, error) {
+	cmd := exec.Command(path)
+	cmd.Stderr = os.Stderr
+	newProcessGroup(cmd)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &pluginProcess{cmd: cmd, in: in, out: bufio.NewReader(out)}, nil
+}
+
+func (p *pluginProcess) call(method string, params interface{}, result interface{}, timeout time.Duration) error {
+	p.nextId++
+	request, err := json.Marshal(pluginRequest{p.nextId, "2.0", method, params})
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.in.Write(append(request, '\n')); err != nil {
+		return brokenPluginError{err}
+	}
+
+	line, err := p.read(timeout)
+	if err != nil {
+		return brokenPluginError{err}
+	}
+
+	response := &pluginResponse{}
+	if err := json.Unmarshal(line, response); err != nil {
+		return brokenPluginError{fmt.Errorf("Invalid response from plugin: %s", err)}
+	}
+
+	if response.Id != p.nextId {
+		return brokenPluginError{errors.New("Plugin response does not match the request")}
+	}
+
+	if response.Error != nil {
+		return errors.New(response.Error.Message)
+	}
+
+	if result == nil || len(response.Result) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(response.Result, result)
+}
+
+// Reads the next line the process writes, killing the process if it takes
+// longer than timeout (if non-zero). The reader is left to finish once the
+// process has been waited for, which closes its output.
+func (p *pluginProcess) read(timeout time.Duration) ([]byte, error) {
+	type reply struct {
+		line []byte
+		err  error
+	}
+	done := make(chan reply, 1)
+	go func() {
+		line, err := p.out.ReadBytes('\n')
+		done <- reply{line, err}
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+
+	select {
+	case r := <-done:
+		return r.line, r.err
+	case <-expired:
+		killProcessGroup(p.cmd)
+		return nil, fmt.Errorf("Plugin did not answer within %s", timeout)
+	}
+}
+
+func (p *pluginProcess) close() {
+	p.in.Close()
+	p.cmd.Wait()
+}
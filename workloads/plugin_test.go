@@ -0,0 +1,168 @@
+package workloads_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const greeterPlugin = `#!/bin/sh
+while read line; do
+  id=$(echo "$line" | sed 's/^{"id":\([0-9]*\).*/\1/')
+  case "$line" in
+    *'"method":"describe"'*)
+      echo '{"id":'$id',"result":{"steps":[{"name":"greeter:hello","description":"says hello"},{"name":"greeter:fail","description":"always fails"},{"name":"greeter:echo","description":"echoes the request"},{"name":"greeter:hijack","description":"replaces the request log"}],"parameters":[{"name":"greeter:greeting","default":"hello","description":"what to say"}]}}' ;;
+    *'"step":"greeter:fail"'*)
+      echo '{"id":'$id',"error":{"message":"greeter failed"}}' ;;
+    *'"step":"greeter:echo"'*)
+      escaped=$(echo "$line" | sed 's/\\/\\\\/g; s/"/\\"/g')
+      echo '{"id":'$id',"result":{"context":{"received":"'"$escaped"'"}}}' ;;
+    *'"step":"greeter:hijack"'*)
+      echo '{"id":'$id',"result":{"context":{"requests":"mine","greeted":"hijacked"}}}' ;;
+    *'"greeter:greeting":"bonjour"'*)
+      echo '{"id":'$id',"result":{"context":{"greeted":"bonjour"}}}' ;;
+    *)
+      echo '{"id":'$id',"result":{"context":{"greeted":"hello"}}}' ;;
+  esac
+done
+`
+
+// Describes steps and a parameter named after those of another plugin and a
+// built-in workload, and takes its time over sleepy:nap.
+const sleepyPlugin = `#!/bin/sh
+while read line; do
+  id=$(echo "$line" | sed 's/^{"id":\([0-9]*\).*/\1/')
+  case "$line" in
+    *'"method":"describe"'*)
+      echo '{"id":'$id',"result":{"steps":[{"name":"nap"},{"name":"greeter:hello"}],"parameters":[{"name":"rest:target","default":"","description":"shadows rest:target"}]}}' ;;
+    *'"step":"nap"'*)
+      sleep 10
+      echo '{"id":'$id',"result":{}}' ;;
+    *)
+      echo '{"id":'$id',"result":{"context":{"greeted":"yawn"}}}' ;;
+  esac
+done
+`
+
+var _ = Describe("Plugin Workloads", func() {
+	var (
+		args    []string
+		dir     string
+		plugins map[string]string
+		steps   map[string]WorkloadStep
+		context map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		args = []string{}
+		plugins = map[string]string{"greeter": greeterPlugin}
+	})
+
+	JustBeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "pat-plugins")
+		for name, script := range plugins {
+			ioutil.WriteFile(dir+"/"+name, []byte(script), 0755)
+		}
+		ioutil.WriteFile(dir+"/README", []byte("not a plugin"), 0644)
+
+		workload := NewPluginWorkload(dir)
+		c := config.NewConfig()
+		NewRestWorkload().DescribeParameters(c)
+		workload.DescribeParameters(c)
+		Ω(c.Parse(args)).ShouldNot(HaveOccurred())
+
+		steps = make(map[string]WorkloadStep)
+		for _, step := range workload.Steps() {
+			steps[step.Name] = step
+		}
+
+		context = make(map[string]interface{})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Registers the steps each plugin describes", func() {
+		Ω(steps).Should(HaveLen(4))
+		Ω(steps["greeter:hello"].Description).Should(Equal("says hello"))
+	})
+
+	It("Runs a step and updates the context from its result", func() {
+		Ω(steps["greeter:hello"].Fn(context)).ShouldNot(HaveOccurred())
+		Ω(context["greeted"]).Should(Equal("hello"))
+	})
+
+	It("Returns the error reported by the plugin", func() {
+		err := steps["greeter:fail"].Fn(context)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(Equal("greeter failed"))
+	})
+
+	It("Sends only the scalar values of the context", func() {
+		context["name"] = "an-app"
+		context["worker"] = 3
+		context["stream"] = make(chan bool)
+		Ω(steps["greeter:echo"].Fn(context)).ShouldNot(HaveOccurred())
+		Ω(context["received"]).Should(ContainSubstring(`"name":"an-app"`))
+		Ω(context["received"]).Should(ContainSubstring(`"worker":3`))
+		Ω(context["received"]).ShouldNot(ContainSubstring(`stream`))
+	})
+
+	It("Refuses to let a plugin replace internal context values", func() {
+		err := steps["greeter:hijack"].Fn(context)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("'requests'"))
+		Ω(context).ShouldNot(HaveKey("greeted"))
+	})
+
+	Context("When a plugin parameter is set", func() {
+		BeforeEach(func() {
+			args = []string{"-greeter:greeting", "bonjour"}
+		})
+
+		It("Passes it to the plugin", func() {
+			steps["greeter:hello"].Fn(context)
+			Ω(context["greeted"]).Should(Equal("bonjour"))
+		})
+	})
+	Context("When a plugin uses names which are already taken", func() {
+		BeforeEach(func() {
+			plugins["sleepy.sh"] = sleepyPlugin
+			plugins["rest"] = greeterPlugin
+			args = []string{"-sleepy:rest:target", "bed"}
+		})
+
+		It("Registers its steps and parameters under its own name", func() {
+			Ω(steps).Should(HaveKey("sleepy:nap"))
+			Ω(steps).Should(HaveKey("sleepy:greeter:hello"))
+			Ω(steps["greeter:hello"].Fn(context)).ShouldNot(HaveOccurred())
+			Ω(context["greeted"]).Should(Equal("hello"))
+		})
+
+		It("Does not load a plugin named after a built-in workload", func() {
+			Ω(steps).Should(HaveLen(6))
+			Ω(steps).ShouldNot(HaveKey("rest:greeter:hello"))
+		})
+	})
+
+	Context("When a plugin does not answer in time", func() {
+		BeforeEach(func() {
+			plugins["sleepy"] = sleepyPlugin
+			args = []string{"-plugin-timeout", "1"}
+		})
+
+		It("Kills it and starts another for the next request", func() {
+			err := steps["sleepy:nap"].Fn(context)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("did not answer within 1s"))
+
+			Ω(steps["sleepy:greeter:hello"].Fn(context)).ShouldNot(HaveOccurred())
+			Ω(context["greeted"]).Should(Equal("yawn"))
+		})
+	})
+})
@@ -1,13 +1,21 @@
 package workloads
 
 type TargetResponse struct {
-	LoginEndpoint string `json:"authorization_endpoint"`
+	LoginEndpoint   string `json:"authorization_endpoint"`
+	UaaEndpoint     string `json:"token_endpoint"`
+	DopplerEndpoint string `json:"doppler_logging_endpoint"`
 }
 
 type LoginResponse struct {
 	Token string `json:"access_token"`
 }
 
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
 type Metadata struct {
 	Guid string `json:"guid"`
 }
@@ -19,3 +27,60 @@ type Resource struct {
 type SpaceResponse struct {
 	Resources []Resource `json:"resources"`
 }
+
+type V3Link struct {
+	Href string `json:"href"`
+}
+
+type V3RootResponse struct {
+	Links struct {
+		Login   V3Link `json:"login"`
+		Uaa     V3Link `json:"uaa"`
+		Logging V3Link `json:"logging"`
+	} `json:"links"`
+}
+
+type V3Resource struct {
+	Guid  string `json:"guid"`
+	State string `json:"state"`
+}
+
+type V3ListResponse struct {
+	Resources []V3Resource `json:"resources"`
+}
+
+type V3BuildResponse struct {
+	Guid    string     `json:"guid"`
+	State   string     `json:"state"`
+	Error   string     `json:"error"`
+	Droplet V3Resource `json:"droplet"`
+}
+
+type LastOperation struct {
+	Type        string `json:"type"`
+	State       string `json:"state"`
+	Description string `json:"description"`
+}
+
+type ServiceInstanceResponse struct {
+	Metadata Metadata `json:"metadata"`
+	Entity   struct {
+		LastOperation LastOperation `json:"last_operation"`
+	} `json:"entity"`
+}
+
+type NamedResource struct {
+	Metadata Metadata `json:"metadata"`
+	Entity   struct {
+		Name  string `json:"name"`
+		Label string `json:"label"`
+	} `json:"entity"`
+}
+
+type NamedResourcesResponse struct {
+	Resources []NamedResource `json:"resources"`
+}
+
+type ScimUserResponse struct {
+	Id string `json:"id"`
+}
@@ -1,7 +1,6 @@
 package workloads
 
 import (
-	"archive/zip"
 	"bytes"
 	"errors"
 	"fmt"
@@ -14,21 +13,35 @@ import (
 )
 
 type rest struct {
-	username   string
-	password   string
-	target     string
-	space_name string
-	client     httpclient
+	username    string
+	password    string
+	target      string
+	space_name  string
+	instances   int
+	memory      int
+	service     string
+	servicePlan string
+	domain      string
+	reachable   int
+	reachBody   string
+	logTimeout  int
+	app         *appConfig
+	uaa         *uaaConfig
+	fixture     *fixtureConfig
+	transport   *transportConfig
+	requests    *RequestLog
+	iteration   map[string]interface{}
+	client      httpclient
 }
 
 func NewRestWorkload() *rest {
-	ctx := &rest{}
+	ctx := &rest{app: pushedApp, uaa: newUaaConfig(), fixture: &fixtureConfig{}, transport: &transportConfig{}}
 	ctx.client = ctx
 	return ctx
 }
 
 func NewRestWorkloadWithClient(client httpclient) *rest {
-	ctx := &rest{}
+	ctx := &rest{app: pushedApp, uaa: newUaaConfig(), fixture: &fixtureConfig{}, transport: &transportConfig{}}
 	ctx.client = client
 	return ctx
 }
@@ -38,31 +51,72 @@ func (r *rest) DescribeParameters(config config.Config) {
 	config.StringVar(&r.username, "rest:username", "", "username for REST api")
 	config.StringVar(&r.password, "rest:password", "", "password for REST api")
 	config.StringVar(&r.space_name, "rest:space", "dev", "space to target for REST api")
+	r.transport.DescribeParameters(config)
+	config.IntVar(&r.instances, "rest:scale-instances", 2, "number of instances rest:scale scales the app to")
+	config.IntVar(&r.memory, "rest:scale-memory", 256, "memory (in MB) rest:scale gives each instance")
+	config.StringVar(&r.service, "rest:service", "", "label of the marketplace service rest:create-service provisions")
+	config.StringVar(&r.servicePlan, "rest:service-plan", "", "name of the service plan rest:create-service provisions")
+	r.uaa.DescribeParameters(config)
+	r.app.DescribeParameters(config)
+	config.StringVar(&r.domain, "rest:domain", "", "shared domain rest:create-route creates routes in (default: the first shared domain)")
+	config.IntVar(&r.reachable, "rest:reachable-interval", 100, "milliseconds rest:reachable waits between requests to the app's route")
+	config.StringVar(&r.reachBody, "rest:reachable-body", "", "text the app's response must contain for rest:reachable to count it as reachable (default: any 2xx response will do)")
+	r.fixture.DescribeParameters(config)
+	config.IntVar(&r.logTimeout, "rest:log-timeout", 60, "seconds rest:log-marker waits for its marker to arrive, and rest:tail-logs keeps the log stream open")
+}
+
+// Wraps a step so that it runs on a copy of r which records the requests
+// it makes in the step's RequestLog, and makes them with the iteration's
+// client.
+func (r *rest) step(fn func(*rest, map[string]interface{}) error) func(map[string]interface{}) error {
+	return func(ctx map[string]interface{}) error {
+		return fn(r.tracing(ctx), ctx)
+	}
+}
+
+func (r *rest) tracing(ctx map[string]interface{}) *rest {
+	traced := *r
+	traced.requests = requestLog(ctx)
+	traced.iteration = ctx
+	if r.client == httpclient(r) {
+		traced.client = &traced
+	}
+	return &traced
 }
 
 func (r *rest) Target(ctx map[string]interface{}) error {
 	body := &TargetResponse{}
 	return r.GetSuccessfully("", r.target+"/v2/info", nil, body, func(reply Reply) error {
 		ctx["loginEndpoint"] = body.LoginEndpoint
+		ctx["uaaEndpoint"] = body.UaaEndpoint
+		if body.UaaEndpoint == "" {
+			ctx["uaaEndpoint"] = body.LoginEndpoint
+		}
 		ctx["apiEndpoint"] = r.target
+		ctx["dopplerEndpoint"] = body.DopplerEndpoint
 		return nil
 	})
 }
 
 func (r *rest) Login(ctx map[string]interface{}) error {
-	body := &LoginResponse{}
 	return checkTargetted(ctx, func(loginEndpoint string, apiEndpoint string) error {
-		return r.PostToUaaSuccessfully(fmt.Sprintf("%s/oauth/token", ctx["loginEndpoint"]), r.oauthInputs(), body, func(reply Reply) error {
-			ctx["token"] = body.Token
-			return r.targetSpace(ctx)
-		})
+		if err := r.authenticate(ctx, loginEndpoint); err != nil {
+			return err
+		}
+
+		return r.targetSpace(ctx)
 	})
 }
 
 func (r *rest) targetSpace(ctx map[string]interface{}) error {
+	url := fmt.Sprintf("%s/v2/spaces?q=name:%s", ctx["apiEndpoint"], r.space_name)
+	if org := r.fixture.org(); org != "" {
+		url += "&q=organization_guid:" + org
+	}
+
 	replyBody := &SpaceResponse{}
 	return checkLoggedIn(ctx, func(token string) error {
-		return r.GetSuccessfully(token, fmt.Sprintf("%s/v2/spaces?q=name:%s", ctx["apiEndpoint"], r.space_name), nil, replyBody, func(reply Reply) error {
+		return r.GetSuccessfully(token, url, nil, replyBody, func(reply Reply) error {
 			return checkSpaceExists(replyBody, func() error {
 				ctx["space_guid"] = replyBody.Resources[0].Metadata.Guid
 				return nil
@@ -74,6 +128,7 @@ func (r *rest) targetSpace(ctx map[string]interface{}) error {
 func (r *rest) Push(ctx map[string]interface{}) error {
 	return checkLoggedIn(ctx, func(token string) error {
 		return r.createAppSuccessfully(ctx, func(appUri string) error {
+			ctx["app_uri"] = appUri
 			return r.uploadAppBitsSuccessfully(ctx, appUri, func() error {
 				return r.start(ctx, appUri, func() error {
 					return r.trackAppStart(ctx, appUri)
@@ -85,7 +140,7 @@ func (r *rest) Push(ctx map[string]interface{}) error {
 
 func (r *rest) uploadAppBitsSuccessfully(ctx map[string]interface{}, appUri string, then func() error) error {
 	return checkLoggedIn(ctx, func(token string) error {
-		return withGeneratedAppBits(func(b *bytes.Buffer, m *multipart.Writer) error {
+		return r.app.withBits("application", func(b *bytes.Buffer, m *multipart.Writer) error {
 			return r.MultipartPutSuccessfully(token, m, fmt.Sprintf("%s%s/bits", ctx["apiEndpoint"], appUri), b, nil, func(reply Reply) error {
 				return then()
 			})
@@ -123,39 +178,56 @@ func (r *rest) trackAppStart(ctx map[string]interface{}, appUri string) error {
 	})
 }
 
-func withGeneratedAppBits(fn func(b *bytes.Buffer, m *multipart.Writer) error) error {
-	var b bytes.Buffer
-	multi := multipart.NewWriter(&b)
-	appbits, _ := multi.CreateFormFile("application", "app.zip")
-	zipper := zip.NewWriter(appbits)
-	configru, _ := zipper.Create("config.ru")
-	configru.Write([]byte("app = lambda do |env|\n body = 'Hello, World!'\n [200, { 'Content-Type' => 'text/plain', 'Content-Length' => body.length.to_s }, [body] ]\n end\n\n run app"))
-	gemfile, _ := zipper.Create("Gemfile")
-	gemfile.Write([]byte("source \"https://rubgems.org\" \n\ngem \"rack\""))
-	gemfilelock, _ := zipper.Create("Gemfile.lock")
-	gemfilelock.Write([]byte("GEM\n  remote: https://rubygems.org/\n  specs:\n    track (1.5.2)\n\nPLATFORMS\n  ruby\n\nDEPENDENCIES\n  rack"))
-	zipper.Close()
-	resources, _ := multi.CreateFormField("resources")
-	resources.Write([]byte("[]"))
-	multi.Close()
-
-	return fn(&b, multi)
-}
-
 func (r *rest) createAppSuccessfully(ctx map[string]interface{}, thenWithLocation func(appUri string) error) error {
 	uuid, _ := uuid.NewV4()
-	createApp := struct {
-		Name      string `json:"name"`
-		SpaceGuid string `json:"space_guid"`
-	}{uuid.String(), ctx["space_guid"].(string)}
+	createApp := map[string]interface{}{
+		"name":       uuid.String(),
+		"space_guid": ctx["space_guid"].(string),
+	}
+	if r.app.memory > 0 {
+		createApp["memory"] = r.app.memory
+	}
+	if r.app.instances > 0 {
+		createApp["instances"] = r.app.instances
+	}
+	if r.app.disk > 0 {
+		createApp["disk_quota"] = r.app.disk
+	}
+	if r.app.buildpack != "" {
+		createApp["buildpack"] = r.app.buildpack
+	}
+	if env := r.app.environment(); len(env) > 0 {
+		createApp["environment_json"] = env
+	}
 
 	return checkLoggedIn(ctx, func(token string) error {
-		return r.PostSuccessfully(token, fmt.Sprintf("%s/v2/apps", ctx["apiEndpoint"]), createApp, nil, func(reply Reply) error {
-			return thenWithLocation(reply.Location)
+		return r.withStack(ctx, token, func(stackGuid string) error {
+			if stackGuid != "" {
+				createApp["stack_guid"] = stackGuid
+			}
+
+			return r.PostSuccessfully(token, fmt.Sprintf("%s/v2/apps", ctx["apiEndpoint"]), createApp, nil, func(reply Reply) error {
+				return thenWithLocation(reply.Location)
+			})
 		})
 	})
 }
 
+func (r *rest) withStack(ctx map[string]interface{}, token string, then func(stackGuid string) error) error {
+	if r.app.stack == "" {
+		return then("")
+	}
+
+	stacks := &NamedResourcesResponse{}
+	return r.GetSuccessfully(token, fmt.Sprintf("%s/v2/stacks?q=name:%s", ctx["apiEndpoint"], url.QueryEscape(r.app.stack)), nil, stacks, func(reply Reply) error {
+		if len(stacks.Resources) == 0 {
+			return errors.New("No stack found with the given name")
+		}
+
+		return then(stacks.Resources[0].Metadata.Guid)
+	})
+}
+
 func checkSpaceExists(s *SpaceResponse, then func() error) error {
 	if !s.SpaceExists() {
 		return errors.New("No space found with the given name")
@@ -192,9 +264,10 @@ func checkSuccessfulReply(reply Reply, then func() error) error {
 	return then()
 }
 
+// A zero Code means no response was received at all.
 func (r Reply) checkError() error {
-	if r.Code > 399 {
-		return errors.New(r.Message)
+	if r.Code == 0 || r.Code > 399 {
+		return &ReplyError{r.Code, r.Message}
 	}
 
 	return nil
@@ -203,13 +276,3 @@ func (r Reply) checkError() error {
 func (s SpaceResponse) SpaceExists() bool {
 	return len(s.Resources) > 0
 }
-
-func (r *rest) oauthInputs() url.Values {
-	values := make(url.Values)
-	values.Add("grant_type", "password")
-	values.Add("username", r.username)
-	values.Add("password", r.password)
-	values.Add("scope", "")
-
-	return values
-}
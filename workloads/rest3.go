@@ -0,0 +1,335 @@
+package workloads
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"time"
+
+	"github.com/nu7hatch/gouuid"
+)
+
+const (
+	v3PollInterval = 2 * time.Second
+	v3PollTimeout  = 5 * time.Minute
+)
+
+// rest3 drives the Cloud Controller v3 API. It shares its target,
+// credentials and space with the v2 rest workload, and stores the same
+// loginEndpoint, uaaEndpoint, apiEndpoint, token and space_guid context
+// keys, so rest: and rest3: steps can be mixed in one workload.
+type rest3 struct {
+	*rest
+}
+
+func NewRest3Workload(r *rest) *rest3 {
+	return &rest3{r}
+}
+
+func (r *rest3) step(fn func(*rest3, map[string]interface{}) error) func(map[string]interface{}) error {
+	return func(ctx map[string]interface{}) error {
+		return fn(&rest3{r.tracing(ctx)}, ctx)
+	}
+}
+
+func (r *rest3) Target(ctx map[string]interface{}) error {
+	body := &V3RootResponse{}
+	return r.GetSuccessfully("", r.target+"/", nil, body, func(reply Reply) error {
+		loginEndpoint := body.Links.Login.Href
+		if loginEndpoint == "" {
+			loginEndpoint = body.Links.Uaa.Href
+		}
+		if loginEndpoint == "" {
+			return errors.New("No login endpoint advertised by the target")
+		}
+
+		ctx["loginEndpoint"] = loginEndpoint
+		ctx["uaaEndpoint"] = body.Links.Uaa.Href
+		if body.Links.Uaa.Href == "" {
+			ctx["uaaEndpoint"] = loginEndpoint
+		}
+		ctx["apiEndpoint"] = r.target
+		ctx["dopplerEndpoint"] = body.Links.Logging.Href
+		return nil
+	})
+}
+
+func (r *rest3) Login(ctx map[string]interface{}) error {
+	return checkTargetted(ctx, func(loginEndpoint string, apiEndpoint string) error {
+		if err := r.authenticate(ctx, loginEndpoint); err != nil {
+			return err
+		}
+
+		return r.targetSpace(ctx)
+	})
+}
+
+func (r *rest3) targetSpace(ctx map[string]interface{}) error {
+	spaces := fmt.Sprintf("%s/v3/spaces?names=%s", ctx["apiEndpoint"], url.QueryEscape(r.space_name))
+	if org := r.fixture.org(); org != "" {
+		spaces += "&organization_guids=" + org
+	}
+
+	body := &V3ListResponse{}
+	return checkLoggedIn(ctx, func(token string) error {
+		return r.GetSuccessfully(token, spaces, nil, body, func(reply Reply) error {
+			if len(body.Resources) == 0 {
+				return errors.New("No space found with the given name")
+			}
+
+			ctx["space_guid"] = body.Resources[0].Guid
+			return nil
+		})
+	})
+}
+
+func (r *rest3) Push(ctx map[string]interface{}) error {
+	for _, step := range []func(map[string]interface{}) error{
+		r.CreateApp,
+		r.CreatePackage,
+		r.UploadBits,
+		r.CreateBuild,
+		r.SetDroplet,
+		r.Start,
+		r.WaitForProcesses,
+	} {
+		if err := step(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *rest3) CreateApp(ctx map[string]interface{}) error {
+	if ctx["space_guid"] == nil {
+		return errors.New("No space targetted")
+	}
+
+	name, _ := uuid.NewV4()
+	input := map[string]interface{}{
+		"name":          name.String(),
+		"relationships": relationship("space", ctx["space_guid"].(string)),
+	}
+	if env := r.app.environment(); len(env) > 0 {
+		input["environment_variables"] = env
+	}
+	if r.app.buildpack != "" || r.app.stack != "" {
+		lifecycle := make(map[string]interface{})
+		if r.app.buildpack != "" {
+			lifecycle["buildpacks"] = []string{r.app.buildpack}
+		}
+		if r.app.stack != "" {
+			lifecycle["stack"] = r.app.stack
+		}
+		input["lifecycle"] = map[string]interface{}{"type": "buildpack", "data": lifecycle}
+	}
+
+	body := &V3Resource{}
+	return checkLoggedIn(ctx, func(token string) error {
+		return r.PostSuccessfully(token, fmt.Sprintf("%s/v3/apps", ctx["apiEndpoint"]), input, body, func(reply Reply) error {
+			ctx["app_guid"] = body.Guid
+			return nil
+		})
+	})
+}
+
+func (r *rest3) CreatePackage(ctx map[string]interface{}) error {
+	return checkApp(ctx, func(token string, appGuid string) error {
+		input := map[string]interface{}{
+			"type":          "bits",
+			"relationships": relationship("app", appGuid),
+		}
+
+		body := &V3Resource{}
+		return r.PostSuccessfully(token, fmt.Sprintf("%s/v3/packages", ctx["apiEndpoint"]), input, body, func(reply Reply) error {
+			ctx["package_guid"] = body.Guid
+			return nil
+		})
+	})
+}
+
+func (r *rest3) UploadBits(ctx map[string]interface{}) error {
+	return checkPackage(ctx, func(token string, packageGuid string) error {
+		return r.app.withBits("bits", func(b *bytes.Buffer, m *multipart.Writer) error {
+			url := fmt.Sprintf("%s/v3/packages/%s", ctx["apiEndpoint"], packageGuid)
+			return r.MultipartPostSuccessfully(token, m, url+"/upload", b, nil, func(reply Reply) error {
+				return r.pollState(token, url, "READY", "FAILED")
+			})
+		})
+	})
+}
+
+func (r *rest3) CreateBuild(ctx map[string]interface{}) error {
+	return checkPackage(ctx, func(token string, packageGuid string) error {
+		input := map[string]interface{}{
+			"package": map[string]string{"guid": packageGuid},
+		}
+
+		body := &V3BuildResponse{}
+		return r.PostSuccessfully(token, fmt.Sprintf("%s/v3/builds", ctx["apiEndpoint"]), input, body, func(reply Reply) error {
+			ctx["build_guid"] = body.Guid
+			return r.trackBuild(ctx, token, body.Guid)
+		})
+	})
+}
+
+func (r *rest3) trackBuild(ctx map[string]interface{}, token string, buildGuid string) error {
+	return poll(func() (bool, error) {
+		body := &V3BuildResponse{}
+		if err := r.GetSuccessfully(token, fmt.Sprintf("%s/v3/builds/%s", ctx["apiEndpoint"], buildGuid), nil, body, func(reply Reply) error {
+			return nil
+		}); err != nil {
+			return false, err
+		}
+
+		switch body.State {
+		case "STAGED":
+			ctx["droplet_guid"] = body.Droplet.Guid
+			return true, nil
+		case "FAILED":
+			return false, errors.New("App Failed to Stage: " + body.Error)
+		}
+
+		return false, nil
+	})
+}
+
+func (r *rest3) SetDroplet(ctx map[string]interface{}) error {
+	return checkApp(ctx, func(token string, appGuid string) error {
+		if ctx["droplet_guid"] == nil {
+			return errors.New("No droplet has been staged")
+		}
+
+		input := map[string]interface{}{
+			"data": map[string]string{"guid": ctx["droplet_guid"].(string)},
+		}
+
+		return r.PatchSuccessfully(token, fmt.Sprintf("%s/v3/apps/%s/relationships/current_droplet", ctx["apiEndpoint"], appGuid), input, nil, func(reply Reply) error {
+			return nil
+		})
+	})
+}
+
+// Scales the web process to whichever of the instances, memory and disk are
+// configured, then starts the app.
+func (r *rest3) Start(ctx map[string]interface{}) error {
+	return checkApp(ctx, func(token string, appGuid string) error {
+		start := func() error {
+			return r.PostSuccessfully(token, fmt.Sprintf("%s/v3/apps/%s/actions/start", ctx["apiEndpoint"], appGuid), nil, nil, func(reply Reply) error {
+				return nil
+			})
+		}
+
+		scale := make(map[string]interface{})
+		if r.app.instances > 0 {
+			scale["instances"] = r.app.instances
+		}
+		if r.app.memory > 0 {
+			scale["memory_in_mb"] = r.app.memory
+		}
+		if r.app.disk > 0 {
+			scale["disk_in_mb"] = r.app.disk
+		}
+		if len(scale) == 0 {
+			return start()
+		}
+
+		return r.PostSuccessfully(token, fmt.Sprintf("%s/v3/apps/%s/processes/web/actions/scale", ctx["apiEndpoint"], appGuid), scale, nil, func(reply Reply) error {
+			return start()
+		})
+	})
+}
+
+func (r *rest3) WaitForProcesses(ctx map[string]interface{}) error {
+	return checkApp(ctx, func(token string, appGuid string) error {
+		return poll(func() (bool, error) {
+			body := &V3ListResponse{}
+			if err := r.GetSuccessfully(token, fmt.Sprintf("%s/v3/apps/%s/processes/web/stats", ctx["apiEndpoint"], appGuid), nil, body, func(reply Reply) error {
+				return nil
+			}); err != nil {
+				return false, err
+			}
+
+			for _, instance := range body.Resources {
+				switch instance.State {
+				case "RUNNING":
+					return true, nil
+				case "CRASHED":
+					return false, errors.New("App Crashed")
+				}
+			}
+
+			return false, nil
+		})
+	})
+}
+
+func (r *rest3) pollState(token string, url string, want string, failed string) error {
+	return poll(func() (bool, error) {
+		body := &V3Resource{}
+		if err := r.GetSuccessfully(token, url, nil, body, func(reply Reply) error {
+			return nil
+		}); err != nil {
+			return false, err
+		}
+
+		if body.State == failed {
+			return false, fmt.Errorf("%s is %s", url, failed)
+		}
+
+		return body.State == want, nil
+	})
+}
+
+func poll(fn func() (bool, error)) error {
+	return pollEvery(v3PollInterval, "the Cloud Controller", fn)
+}
+
+// Calls fn every interval until it is done or fails, or v3PollTimeout has
+// passed, in which case the error says what was being waited for.
+func pollEvery(interval time.Duration, waitingFor string, fn func() (bool, error)) error {
+	deadline := time.Now().Add(v3PollTimeout)
+	for {
+		done, err := fn()
+		if err != nil || done {
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			return errors.New("Timed out waiting for " + waitingFor)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func relationship(name string, guid string) map[string]interface{} {
+	return map[string]interface{}{
+		name: map[string]interface{}{
+			"data": map[string]string{"guid": guid},
+		},
+	}
+}
+
+func checkApp(ctx map[string]interface{}, then func(token string, appGuid string) error) error {
+	return checkLoggedIn(ctx, func(token string) error {
+		if ctx["app_guid"] == nil {
+			return errors.New("No app has been created")
+		}
+
+		return then(token, ctx["app_guid"].(string))
+	})
+}
+
+func checkPackage(ctx map[string]interface{}, then func(token string, packageGuid string) error) error {
+	return checkLoggedIn(ctx, func(token string) error {
+		if ctx["package_guid"] == nil {
+			return errors.New("No package has been created")
+		}
+
+		return then(token, ctx["package_guid"].(string))
+	})
+}
@@ -0,0 +1,167 @@
+package workloads_test
+
+import (
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rest v3 Workloads", func() {
+	var (
+		client  *dummyClient
+		rest3   workloads
+		replies map[string]interface{}
+		context map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		replies = make(map[string]interface{})
+		client = &dummyClient{replies, make(map[string]string), make(map[call]interface{})}
+		rest3 = NewRest3Workload(NewRestWorkloadWithClient(client))
+		config := config.NewConfig()
+		rest3.DescribeParameters(config)
+		config.Parse([]string{"-rest:target", "APISERVER", "-rest:space", "thespace"})
+		context = make(map[string]interface{})
+
+		replies["APISERVER/"] = map[string]interface{}{"links": map[string]interface{}{"login": map[string]string{"href": "THELOGINSERVER/PATH"}}}
+		replies["THELOGINSERVER/PATH/oauth/token"] = LoginResponse{"blah blah"}
+		replies["APISERVER/v3/spaces?names=thespace"] = V3ListResponse{[]V3Resource{V3Resource{Guid: "THE-SPACE"}}}
+	})
+
+	Describe("Targetting and logging in", func() {
+		It("Uses the login link from the API root", func() {
+			Ω(rest3.Target(context)).ShouldNot(HaveOccurred())
+			Ω(context["loginEndpoint"]).Should(Equal("THELOGINSERVER/PATH"))
+			Ω(context["apiEndpoint"]).Should(Equal("APISERVER"))
+		})
+
+		It("Uses the UAA link, when there is one, for managing users", func() {
+			rest3.Target(context)
+			Ω(context["uaaEndpoint"]).Should(Equal("THELOGINSERVER/PATH"))
+
+			replies["APISERVER/"] = map[string]interface{}{"links": map[string]interface{}{
+				"login": map[string]string{"href": "THELOGINSERVER/PATH"},
+				"uaa":   map[string]string{"href": "THEUAASERVER"},
+			}}
+			rest3.Target(context)
+			Ω(context["uaaEndpoint"]).Should(Equal("THEUAASERVER"))
+		})
+
+		It("Finds the space through the v3 API", func() {
+			rest3.Target(context)
+			Ω(rest3.Login(context)).ShouldNot(HaveOccurred())
+			Ω(context["token"]).Should(Equal("blah blah"))
+			Ω(context["space_guid"]).Should(Equal("THE-SPACE"))
+		})
+
+		It("Escapes the space name", func() {
+			config := config.NewConfig()
+			rest3.DescribeParameters(config)
+			config.Parse([]string{"-rest:target", "APISERVER", "-rest:space", "dev & test"})
+			replies["APISERVER/v3/spaces?names=dev+%26+test"] = V3ListResponse{[]V3Resource{V3Resource{Guid: "THE-TEST-SPACE"}}}
+			rest3.Target(context)
+			Ω(rest3.Login(context)).ShouldNot(HaveOccurred())
+			Ω(context["space_guid"]).Should(Equal("THE-TEST-SPACE"))
+		})
+
+		It("Returns an error when the space does not exist", func() {
+			replies["APISERVER/v3/spaces?names=thespace"] = V3ListResponse{}
+			rest3.Target(context)
+			Ω(rest3.Login(context)).Should(HaveOccurred())
+		})
+	})
+
+	Describe("Pushing an app", func() {
+		Context("When the user has not logged in", func() {
+			It("Returns an error", func() {
+				Ω(rest3.Push(context)).Should(HaveOccurred())
+			})
+		})
+
+		Context("After logging in", func() {
+			BeforeEach(func() {
+				replies["APISERVER/v3/apps"] = V3Resource{Guid: "THE-APP"}
+				replies["APISERVER/v3/packages"] = V3Resource{Guid: "THE-PACKAGE"}
+				replies["APISERVER/v3/packages/THE-PACKAGE/upload"] = V3Resource{}
+				replies["APISERVER/v3/packages/THE-PACKAGE"] = V3Resource{Guid: "THE-PACKAGE", State: "READY"}
+				replies["APISERVER/v3/builds"] = V3BuildResponse{Guid: "THE-BUILD", State: "STAGING"}
+				replies["APISERVER/v3/builds/THE-BUILD"] = V3BuildResponse{Guid: "THE-BUILD", State: "STAGED", Droplet: V3Resource{Guid: "THE-DROPLET"}}
+				replies["APISERVER/v3/apps/THE-APP/relationships/current_droplet"] = ""
+				replies["APISERVER/v3/apps/THE-APP/processes/web/actions/scale"] = ""
+				replies["APISERVER/v3/apps/THE-APP/actions/start"] = ""
+				replies["APISERVER/v3/apps/THE-APP/processes/web/stats"] = V3ListResponse{[]V3Resource{V3Resource{State: "RUNNING"}}}
+
+				Ω(rest3.Target(context)).ShouldNot(HaveOccurred())
+				Ω(rest3.Login(context)).ShouldNot(HaveOccurred())
+			})
+
+			It("Doesn't return an error", func() {
+				Ω(rest3.Push(context)).ShouldNot(HaveOccurred())
+			})
+
+			It("Creates the app in the chosen space", func() {
+				rest3.Push(context)
+				m := mapOf(client.ShouldHaveBeenCalledWith("POST", "APISERVER/v3/apps"))
+				Ω(m).Should(HaveKey("name"))
+				Ω(m["relationships"]).Should(Equal(map[string]interface{}{
+					"space": map[string]interface{}{"data": map[string]interface{}{"guid": "THE-SPACE"}},
+				}))
+				Ω(context["app_guid"]).Should(Equal("THE-APP"))
+			})
+
+			It("Uploads the bits to a new package", func() {
+				rest3.Push(context)
+				m := mapOf(client.ShouldHaveBeenCalledWith("POST", "APISERVER/v3/packages"))
+				Ω(m["type"]).Should(Equal("bits"))
+				Ω(client.ShouldHaveBeenCalledWith("POST(multipart)", "APISERVER/v3/packages/THE-PACKAGE/upload")).ShouldNot(BeNil())
+			})
+
+			It("Sets the staged droplet as current and starts the app", func() {
+				rest3.Push(context)
+				m := mapOf(client.ShouldHaveBeenCalledWith("PATCH", "APISERVER/v3/apps/THE-APP/relationships/current_droplet"))
+				Ω(m["data"]).Should(Equal(map[string]interface{}{"guid": "THE-DROPLET"}))
+				client.ShouldHaveBeenCalledWith("POST", "APISERVER/v3/apps/THE-APP/actions/start")
+				client.ShouldHaveBeenCalledWith("GET", "APISERVER/v3/apps/THE-APP/processes/web/stats")
+			})
+
+			It("Leaves the web process's scale to the platform by default", func() {
+				rest3.Push(context)
+				Ω(client.calls).ShouldNot(HaveKey(call{"POST", "APISERVER/v3/apps/THE-APP/processes/web/actions/scale"}))
+			})
+
+			It("Scales the web process to the configured instances and memory", func() {
+				config := config.NewConfig()
+				rest3.DescribeParameters(config)
+				config.Parse([]string{"-rest:target", "APISERVER", "-rest:space", "thespace", "-app:memory", "512", "-app:instances", "2"})
+				rest3.Push(context)
+				scale := mapOf(client.ShouldHaveBeenCalledWith("POST", "APISERVER/v3/apps/THE-APP/processes/web/actions/scale"))
+				Ω(scale["memory_in_mb"]).Should(BeNumerically("==", 512))
+				Ω(scale["instances"]).Should(BeNumerically("==", 2))
+				Ω(scale).ShouldNot(HaveKey("disk_in_mb"))
+			})
+
+			Context("When staging fails", func() {
+				BeforeEach(func() {
+					replies["APISERVER/v3/builds/THE-BUILD"] = V3BuildResponse{Guid: "THE-BUILD", State: "FAILED", Error: "NoAppDetectedError"}
+				})
+
+				It("Returns an error", func() {
+					err := rest3.Push(context)
+					Ω(err).Should(HaveOccurred())
+					Ω(err.Error()).Should(ContainSubstring("NoAppDetectedError"))
+				})
+			})
+
+			Context("When the app crashes", func() {
+				BeforeEach(func() {
+					replies["APISERVER/v3/apps/THE-APP/processes/web/stats"] = V3ListResponse{[]V3Resource{V3Resource{State: "CRASHED"}}}
+				})
+
+				It("Returns an error", func() {
+					Ω(rest3.Push(context)).Should(HaveOccurred())
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,94 @@
+package workloads
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nu7hatch/gouuid"
+)
+
+// The steps in this file operate on the app pushed earlier in the same
+// iteration by rest:push, which leaves its location in ctx["app_uri"].
+
+func (r *rest) Stop(ctx map[string]interface{}) error {
+	return r.updateApp(ctx, map[string]interface{}{"state": "STOPPED"})
+}
+
+func (r *rest) Start(ctx map[string]interface{}) error {
+	return checkPushed(ctx, func(token string, appUri string) error {
+		return r.start(ctx, appUri, func() error {
+			return r.trackAppStart(ctx, appUri)
+		})
+	})
+}
+
+func (r *rest) Restart(ctx map[string]interface{}) error {
+	if err := r.Stop(ctx); err != nil {
+		return err
+	}
+
+	return r.Start(ctx)
+}
+
+func (r *rest) Scale(ctx map[string]interface{}) error {
+	return r.updateApp(ctx, map[string]interface{}{"instances": r.instances, "memory": r.memory})
+}
+
+func (r *rest) Rename(ctx map[string]interface{}) error {
+	name, _ := uuid.NewV4()
+	return r.updateApp(ctx, map[string]interface{}{"name": name.String()})
+}
+
+func (r *rest) UpdateEnv(ctx map[string]interface{}) error {
+	value, _ := uuid.NewV4()
+	return r.updateApp(ctx, map[string]interface{}{"environment_json": map[string]string{"PAT_VALUE": value.String()}})
+}
+
+func (r *rest) DeleteApp(ctx map[string]interface{}) error {
+	return checkPushed(ctx, func(token string, appUri string) error {
+		return r.DeleteSuccessfully(token, fmt.Sprintf("%s%s", ctx["apiEndpoint"], appUri), nil, nil, func(reply Reply) error {
+			delete(ctx, "app_uri")
+			return nil
+		})
+	})
+}
+
+func (r *rest) ListApps(ctx map[string]interface{}) error {
+	return checkLoggedIn(ctx, func(token string) error {
+		if ctx["space_guid"] == nil {
+			return errors.New("No space targetted")
+		}
+
+		body := &SpaceResponse{}
+		return r.GetSuccessfully(token, fmt.Sprintf("%s/v2/spaces/%s/apps", ctx["apiEndpoint"], ctx["space_guid"]), nil, body, func(reply Reply) error {
+			return nil
+		})
+	})
+}
+
+func (r *rest) Summary(ctx map[string]interface{}) error {
+	return checkPushed(ctx, func(token string, appUri string) error {
+		body := make(map[string]interface{})
+		return r.GetSuccessfully(token, fmt.Sprintf("%s%s/summary", ctx["apiEndpoint"], appUri), nil, &body, func(reply Reply) error {
+			return nil
+		})
+	})
+}
+
+func (r *rest) updateApp(ctx map[string]interface{}, input map[string]interface{}) error {
+	return checkPushed(ctx, func(token string, appUri string) error {
+		return r.PutSuccessfully(token, fmt.Sprintf("%s%s", ctx["apiEndpoint"], appUri), input, nil, func(reply Reply) error {
+			return nil
+		})
+	})
+}
+
+func checkPushed(ctx map[string]interface{}, then func(token string, appUri string) error) error {
+	return checkLoggedIn(ctx, func(token string) error {
+		if ctx["app_uri"] == nil {
+			return errors.New("No app has been pushed")
+		}
+
+		return then(token, ctx["app_uri"].(string))
+	})
+}
@@ -0,0 +1,116 @@
+package workloads_test
+
+import (
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type lifecycleWorkloads interface {
+	workloads
+	Stop(ctx map[string]interface{}) error
+	Start(ctx map[string]interface{}) error
+	Restart(ctx map[string]interface{}) error
+	Scale(ctx map[string]interface{}) error
+	Rename(ctx map[string]interface{}) error
+	UpdateEnv(ctx map[string]interface{}) error
+	DeleteApp(ctx map[string]interface{}) error
+	ListApps(ctx map[string]interface{}) error
+	Summary(ctx map[string]interface{}) error
+}
+
+var _ = Describe("Rest App Lifecycle Workloads", func() {
+	var (
+		client  *dummyClient
+		rest    lifecycleWorkloads
+		replies map[string]interface{}
+		context map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		replies = make(map[string]interface{})
+		replyWithLocation := make(map[string]string)
+		client = &dummyClient{replies, replyWithLocation, make(map[call]interface{})}
+		rest = NewRestWorkloadWithClient(client)
+		config := config.NewConfig()
+		rest.DescribeParameters(config)
+		config.Parse([]string{"-rest:target", "APISERVER", "-rest:scale-instances", "3", "-rest:scale-memory", "512"})
+		context = make(map[string]interface{})
+
+		replies["APISERVER/v2/info"] = TargetResponse{LoginEndpoint: "THELOGINSERVER/PATH"}
+		replies["THELOGINSERVER/PATH/oauth/token"] = LoginResponse{"blah blah"}
+		replies["APISERVER/v2/spaces?q=name:dev"] = SpaceResponse{[]Resource{Resource{Metadata{"THE-SPACE"}}}}
+		replyWithLocation["APISERVER/v2/apps"] = "/THE-APP-URI"
+		replies["APISERVER/THE-APP-URI"] = ""
+		replies["APISERVER/THE-APP-URI/bits"] = ""
+		replies["APISERVER/THE-APP-URI/instances"] = ""
+
+		rest.Target(context)
+		rest.Login(context)
+	})
+
+	Context("Before an app has been pushed", func() {
+		It("Returns an error", func() {
+			Ω(rest.Stop(context)).Should(HaveOccurred())
+			Ω(rest.Summary(context)).Should(HaveOccurred())
+		})
+	})
+
+	Context("After pushing an app", func() {
+		BeforeEach(func() {
+			Ω(rest.Push(context)).ShouldNot(HaveOccurred())
+		})
+
+		It("Remembers the app's location", func() {
+			Ω(context["app_uri"]).Should(Equal("/THE-APP-URI"))
+		})
+
+		It("Stops the app", func() {
+			Ω(rest.Stop(context)).ShouldNot(HaveOccurred())
+			m := mapOf(client.ShouldHaveBeenCalledWith("PUT", "APISERVER/THE-APP-URI"))
+			Ω(m["state"]).Should(Equal("STOPPED"))
+		})
+
+		It("Restarts the app", func() {
+			Ω(rest.Restart(context)).ShouldNot(HaveOccurred())
+			m := mapOf(client.ShouldHaveBeenCalledWith("PUT", "APISERVER/THE-APP-URI"))
+			Ω(m["state"]).Should(Equal("STARTED"))
+		})
+
+		It("Scales the app to the configured instances and memory", func() {
+			Ω(rest.Scale(context)).ShouldNot(HaveOccurred())
+			m := mapOf(client.ShouldHaveBeenCalledWith("PUT", "APISERVER/THE-APP-URI"))
+			Ω(m["instances"]).Should(BeNumerically("==", 3))
+			Ω(m["memory"]).Should(BeNumerically("==", 512))
+		})
+
+		It("Renames the app", func() {
+			Ω(rest.Rename(context)).ShouldNot(HaveOccurred())
+			m := mapOf(client.ShouldHaveBeenCalledWith("PUT", "APISERVER/THE-APP-URI"))
+			Ω(m["name"]).ShouldNot(BeEmpty())
+		})
+
+		It("Updates the app's environment", func() {
+			Ω(rest.UpdateEnv(context)).ShouldNot(HaveOccurred())
+			m := mapOf(client.ShouldHaveBeenCalledWith("PUT", "APISERVER/THE-APP-URI"))
+			Ω(m["environment_json"]).Should(HaveKey("PAT_VALUE"))
+		})
+
+		It("Gets the app summary", func() {
+			replies["APISERVER/THE-APP-URI/summary"] = map[string]string{"name": "foo"}
+			Ω(rest.Summary(context)).ShouldNot(HaveOccurred())
+		})
+
+		It("Lists the apps in the space", func() {
+			replies["APISERVER/v2/spaces/THE-SPACE/apps"] = SpaceResponse{}
+			Ω(rest.ListApps(context)).ShouldNot(HaveOccurred())
+		})
+
+		It("Deletes the app and forgets it", func() {
+			Ω(rest.DeleteApp(context)).ShouldNot(HaveOccurred())
+			client.ShouldHaveBeenCalledWith("DELETE", "APISERVER/THE-APP-URI")
+			Ω(context).ShouldNot(HaveKey("app_uri"))
+		})
+	})
+})
@@ -0,0 +1,95 @@
+package workloads
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nu7hatch/gouuid"
+)
+
+func (r *rest) CreateRoute(ctx map[string]interface{}) error {
+	return checkLoggedIn(ctx, func(token string) error {
+		if ctx["space_guid"] == nil {
+			return errors.New("No space targetted")
+		}
+
+		return r.findDomain(ctx, token, func(domainGuid string, domainName string) error {
+			host, _ := uuid.NewV4()
+			input := map[string]string{
+				"host":        host.String(),
+				"domain_guid": domainGuid,
+				"space_guid":  ctx["space_guid"].(string),
+			}
+
+			body := &Resource{}
+			return r.PostSuccessfully(token, fmt.Sprintf("%s/v2/routes", ctx["apiEndpoint"]), input, body, func(reply Reply) error {
+				ctx["route_guid"] = body.Metadata.Guid
+				ctx["app_url"] = fmt.Sprintf("http://%s.%s", host.String(), domainName)
+				return nil
+			})
+		})
+	})
+}
+
+func (r *rest) findDomain(ctx map[string]interface{}, token string, then func(guid string, name string) error) error {
+	query := ""
+	if r.domain != "" {
+		query = "?q=name:" + url.QueryEscape(r.domain)
+	}
+
+	domains := &NamedResourcesResponse{}
+	return r.GetSuccessfully(token, fmt.Sprintf("%s/v2/shared_domains%s", ctx["apiEndpoint"], query), nil, domains, func(reply Reply) error {
+		if len(domains.Resources) == 0 {
+			return errors.New("No shared domain found")
+		}
+
+		return then(domains.Resources[0].Metadata.Guid, domains.Resources[0].Entity.Name)
+	})
+}
+
+func (r *rest) MapRoute(ctx map[string]interface{}) error {
+	return checkPushed(ctx, func(token string, appUri string) error {
+		if ctx["route_guid"] == nil {
+			return errors.New("No route has been created")
+		}
+
+		return r.PutSuccessfully(token, fmt.Sprintf("%s/v2/routes/%s/apps/%s", ctx["apiEndpoint"], ctx["route_guid"], path.Base(appUri)), nil, nil, func(reply Reply) error {
+			return nil
+		})
+	})
+}
+
+// Polls the mapped route every rest:reachable-interval until the app answers
+// with a 2xx status (and, if set, a body containing rest:reachable-body), so
+// the step's time is the time to the first successful request.
+func (r *rest) WaitUntilReachable(ctx map[string]interface{}) error {
+	if ctx["app_url"] == nil {
+		return errors.New("No route has been created")
+	}
+
+	client, err := r.transport.clientFor(r.iteration)
+	if err != nil {
+		return err
+	}
+
+	appUrl := ctx["app_url"].(string)
+	return pollEvery(time.Duration(r.reachable)*time.Millisecond, "the app to answer at "+appUrl, func() (bool, error) {
+		req, err := http.NewRequest("GET", appUrl, nil)
+		if err != nil {
+			return false, err
+		}
+
+		reachable := false
+		traceRequest(r.requests, client, req, func(resp *http.Response) {
+			body, _ := ioutil.ReadAll(resp.Body)
+			reachable = resp.StatusCode/100 == 2 && strings.Contains(string(body), r.reachBody)
+		})
+		return reachable, nil
+	})
+}
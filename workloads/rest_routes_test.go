@@ -0,0 +1,140 @@
+package workloads_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type routeWorkloads interface {
+	workloads
+	CreateRoute(ctx map[string]interface{}) error
+	MapRoute(ctx map[string]interface{}) error
+	WaitUntilReachable(ctx map[string]interface{}) error
+}
+
+var _ = Describe("Rest Route Workloads", func() {
+	var (
+		client  *dummyClient
+		rest    routeWorkloads
+		replies map[string]interface{}
+		context map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		replies = make(map[string]interface{})
+		replyWithLocation := make(map[string]string)
+		client = &dummyClient{replies, replyWithLocation, make(map[call]interface{})}
+		rest = NewRestWorkloadWithClient(client)
+		config := config.NewConfig()
+		rest.DescribeParameters(config)
+		config.Parse([]string{"-rest:target", "APISERVER", "-rest:domain", "apps.example.com"})
+		context = make(map[string]interface{})
+
+		replies["APISERVER/v2/info"] = TargetResponse{LoginEndpoint: "THELOGINSERVER/PATH"}
+		replies["THELOGINSERVER/PATH/oauth/token"] = LoginResponse{"blah blah"}
+		replies["APISERVER/v2/spaces?q=name:dev"] = SpaceResponse{[]Resource{Resource{Metadata{"THE-SPACE"}}}}
+		replyWithLocation["APISERVER/v2/apps"] = "/v2/apps/THE-APP"
+		replies["APISERVER/v2/apps/THE-APP"] = ""
+		replies["APISERVER/v2/apps/THE-APP/bits"] = ""
+		replies["APISERVER/v2/apps/THE-APP/instances"] = ""
+		replies["APISERVER/v2/shared_domains?q=name:apps.example.com"] = NamedResourcesResponse{[]NamedResource{namedResource("THE-DOMAIN", "apps.example.com")}}
+		replies["APISERVER/v2/routes"] = ServiceInstanceResponse{Metadata: Metadata{"THE-ROUTE"}}
+		replies["APISERVER/v2/routes/THE-ROUTE/apps/THE-APP"] = ""
+
+		rest.Target(context)
+		rest.Login(context)
+		rest.Push(context)
+	})
+
+	It("Creates a route in the configured domain", func() {
+		Ω(rest.CreateRoute(context)).ShouldNot(HaveOccurred())
+		m := mapOf(client.ShouldHaveBeenCalledWith("POST", "APISERVER/v2/routes"))
+		Ω(m["domain_guid"]).Should(Equal("THE-DOMAIN"))
+		Ω(m["space_guid"]).Should(Equal("THE-SPACE"))
+		Ω(context["app_url"]).Should(Equal(fmt.Sprintf("http://%s.apps.example.com", m["host"])))
+	})
+
+	It("Maps the route to the pushed app", func() {
+		rest.CreateRoute(context)
+		Ω(rest.MapRoute(context)).ShouldNot(HaveOccurred())
+		client.ShouldHaveBeenCalledWith("PUT", "APISERVER/v2/routes/THE-ROUTE/apps/THE-APP")
+	})
+
+	It("Cannot map a route before creating one", func() {
+		Ω(rest.MapRoute(context)).Should(HaveOccurred())
+	})
+
+	It("Waits until the app answers on its route", func() {
+		requests := 0
+		app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			fmt.Fprint(w, "Hello, World!")
+		}))
+		defer app.Close()
+
+		context["app_url"] = app.URL
+		Ω(rest.WaitUntilReachable(context)).ShouldNot(HaveOccurred())
+		Ω(requests).Should(Equal(1))
+	})
+
+	It("Polls the route at the configured interval", func() {
+		config := config.NewConfig()
+		rest.DescribeParameters(config)
+		config.Parse([]string{"-rest:target", "APISERVER", "-rest:reachable-interval", "10"})
+
+		requests := 0
+		app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests < 5 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, "Hello, World!")
+		}))
+		defer app.Close()
+
+		context["app_url"] = app.URL
+		start := time.Now()
+		Ω(rest.WaitUntilReachable(context)).ShouldNot(HaveOccurred())
+		Ω(requests).Should(Equal(5))
+		Ω(time.Since(start)).Should(BeNumerically("<", time.Second))
+	})
+
+	It("Counts any 2xx response as reachable", func() {
+		app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer app.Close()
+
+		context["app_url"] = app.URL
+		Ω(rest.WaitUntilReachable(context)).ShouldNot(HaveOccurred())
+	})
+
+	It("Waits for the configured body", func() {
+		config := config.NewConfig()
+		rest.DescribeParameters(config)
+		config.Parse([]string{"-rest:target", "APISERVER", "-rest:reachable-interval", "10", "-rest:reachable-body", "ready"})
+
+		requests := 0
+		app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests < 3 {
+				fmt.Fprint(w, "starting")
+				return
+			}
+			fmt.Fprint(w, "ready")
+		}))
+		defer app.Close()
+
+		context["app_url"] = app.URL
+		Ω(rest.WaitUntilReachable(context)).ShouldNot(HaveOccurred())
+		Ω(requests).Should(Equal(3))
+	})
+})
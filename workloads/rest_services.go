@@ -0,0 +1,154 @@
+package workloads
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/nu7hatch/gouuid"
+)
+
+// The service steps create an instance of the rest:service offering's
+// rest:service-plan plan and bind it to the app pushed by rest:push. The
+// instance and binding guids are kept in the context so later steps in the
+// same iteration can unbind and delete them.
+
+func (r *rest) Marketplace(ctx map[string]interface{}) error {
+	return checkLoggedIn(ctx, func(token string) error {
+		if ctx["space_guid"] == nil {
+			return errors.New("No space targetted")
+		}
+
+		body := &NamedResourcesResponse{}
+		return r.GetSuccessfully(token, fmt.Sprintf("%s/v2/spaces/%s/services", ctx["apiEndpoint"], ctx["space_guid"]), nil, body, func(reply Reply) error {
+			return nil
+		})
+	})
+}
+
+func (r *rest) CreateService(ctx map[string]interface{}) error {
+	return checkLoggedIn(ctx, func(token string) error {
+		if ctx["space_guid"] == nil {
+			return errors.New("No space targetted")
+		}
+
+		return r.findServicePlan(ctx, token, func(planGuid string) error {
+			name, _ := uuid.NewV4()
+			input := map[string]string{
+				"name":              name.String(),
+				"space_guid":        ctx["space_guid"].(string),
+				"service_plan_guid": planGuid,
+			}
+
+			body := &ServiceInstanceResponse{}
+			return r.PostSuccessfully(token, fmt.Sprintf("%s/v2/service_instances?accepts_incomplete=true", ctx["apiEndpoint"]), input, body, func(reply Reply) error {
+				ctx["service_instance_guid"] = body.Metadata.Guid
+				return r.trackLastOperation(ctx, token, body.Metadata.Guid)
+			})
+		})
+	})
+}
+
+func (r *rest) findServicePlan(ctx map[string]interface{}, token string, then func(planGuid string) error) error {
+	services := &NamedResourcesResponse{}
+	return r.GetSuccessfully(token, fmt.Sprintf("%s/v2/services?q=label:%s", ctx["apiEndpoint"], url.QueryEscape(r.service)), nil, services, func(reply Reply) error {
+		if len(services.Resources) == 0 {
+			return errors.New("No service found with the given label")
+		}
+
+		plans := &NamedResourcesResponse{}
+		return r.GetSuccessfully(token, fmt.Sprintf("%s/v2/services/%s/service_plans", ctx["apiEndpoint"], services.Resources[0].Metadata.Guid), nil, plans, func(reply Reply) error {
+			for _, plan := range plans.Resources {
+				if plan.Entity.Name == r.servicePlan {
+					return then(plan.Metadata.Guid)
+				}
+			}
+
+			return errors.New("No service plan found with the given name")
+		})
+	})
+}
+
+func (r *rest) BindService(ctx map[string]interface{}) error {
+	return checkPushed(ctx, func(token string, appUri string) error {
+		return checkServiceInstance(ctx, func(instanceGuid string) error {
+			input := map[string]string{
+				"service_instance_guid": instanceGuid,
+				"app_guid":              path.Base(appUri),
+			}
+
+			body := &ServiceInstanceResponse{}
+			return r.PostSuccessfully(token, fmt.Sprintf("%s/v2/service_bindings", ctx["apiEndpoint"]), input, body, func(reply Reply) error {
+				ctx["service_binding_guid"] = body.Metadata.Guid
+				return nil
+			})
+		})
+	})
+}
+
+func (r *rest) Restage(ctx map[string]interface{}) error {
+	return checkPushed(ctx, func(token string, appUri string) error {
+		return r.PostSuccessfully(token, fmt.Sprintf("%s%s/restage", ctx["apiEndpoint"], appUri), nil, nil, func(reply Reply) error {
+			return r.trackAppStart(ctx, appUri)
+		})
+	})
+}
+
+func (r *rest) UnbindService(ctx map[string]interface{}) error {
+	return checkLoggedIn(ctx, func(token string) error {
+		if ctx["service_binding_guid"] == nil {
+			return errors.New("No service has been bound")
+		}
+
+		return r.DeleteSuccessfully(token, fmt.Sprintf("%s/v2/service_bindings/%s", ctx["apiEndpoint"], ctx["service_binding_guid"]), nil, nil, func(reply Reply) error {
+			delete(ctx, "service_binding_guid")
+			return nil
+		})
+	})
+}
+
+func (r *rest) DeleteService(ctx map[string]interface{}) error {
+	return checkLoggedIn(ctx, func(token string) error {
+		return checkServiceInstance(ctx, func(instanceGuid string) error {
+			return r.DeleteSuccessfully(token, fmt.Sprintf("%s/v2/service_instances/%s?accepts_incomplete=true", ctx["apiEndpoint"], instanceGuid), nil, nil, func(reply Reply) error {
+				delete(ctx, "service_instance_guid")
+				return r.trackLastOperation(ctx, token, instanceGuid)
+			})
+		})
+	})
+}
+
+// Polls the instance until its last operation succeeds. A deleted instance
+// eventually disappears, which also counts as success.
+func (r *rest) trackLastOperation(ctx map[string]interface{}, token string, instanceGuid string) error {
+	return poll(func() (bool, error) {
+		body := &ServiceInstanceResponse{}
+		reply := r.client.Get(token, fmt.Sprintf("%s/v2/service_instances/%s", ctx["apiEndpoint"], instanceGuid), nil, body)
+		if reply.Code == http.StatusNotFound {
+			return true, nil
+		}
+
+		if err := reply.checkError(); err != nil {
+			return false, err
+		}
+
+		switch body.Entity.LastOperation.State {
+		case "failed":
+			return false, errors.New("Service operation failed: " + body.Entity.LastOperation.Description)
+		case "in progress":
+			return false, nil
+		}
+
+		return true, nil
+	})
+}
+
+func checkServiceInstance(ctx map[string]interface{}, then func(instanceGuid string) error) error {
+	if ctx["service_instance_guid"] == nil {
+		return errors.New("No service instance has been created")
+	}
+
+	return then(ctx["service_instance_guid"].(string))
+}
@@ -0,0 +1,141 @@
+package workloads_test
+
+import (
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type serviceWorkloads interface {
+	workloads
+	Marketplace(ctx map[string]interface{}) error
+	CreateService(ctx map[string]interface{}) error
+	BindService(ctx map[string]interface{}) error
+	Restage(ctx map[string]interface{}) error
+	UnbindService(ctx map[string]interface{}) error
+	DeleteService(ctx map[string]interface{}) error
+}
+
+func namedResource(guid string, name string) NamedResource {
+	r := NamedResource{Metadata: Metadata{guid}}
+	r.Entity.Name = name
+	return r
+}
+
+func serviceInstance(guid string, state string) ServiceInstanceResponse {
+	r := ServiceInstanceResponse{Metadata: Metadata{guid}}
+	r.Entity.LastOperation.State = state
+	return r
+}
+
+var _ = Describe("Rest Service Workloads", func() {
+	var (
+		client  *dummyClient
+		rest    serviceWorkloads
+		replies map[string]interface{}
+		context map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		replies = make(map[string]interface{})
+		replyWithLocation := make(map[string]string)
+		client = &dummyClient{replies, replyWithLocation, make(map[call]interface{})}
+		rest = NewRestWorkloadWithClient(client)
+		config := config.NewConfig()
+		rest.DescribeParameters(config)
+		config.Parse([]string{"-rest:target", "APISERVER", "-rest:service", "mysql", "-rest:service-plan", "small"})
+		context = make(map[string]interface{})
+
+		replies["APISERVER/v2/info"] = TargetResponse{LoginEndpoint: "THELOGINSERVER/PATH"}
+		replies["THELOGINSERVER/PATH/oauth/token"] = LoginResponse{"blah blah"}
+		replies["APISERVER/v2/spaces?q=name:dev"] = SpaceResponse{[]Resource{Resource{Metadata{"THE-SPACE"}}}}
+		replyWithLocation["APISERVER/v2/apps"] = "/v2/apps/THE-APP"
+		replies["APISERVER/v2/apps/THE-APP"] = ""
+		replies["APISERVER/v2/apps/THE-APP/bits"] = ""
+		replies["APISERVER/v2/apps/THE-APP/instances"] = ""
+
+		replies["APISERVER/v2/spaces/THE-SPACE/services"] = NamedResourcesResponse{}
+		replies["APISERVER/v2/services?q=label:mysql"] = NamedResourcesResponse{[]NamedResource{namedResource("THE-SERVICE", "")}}
+		replies["APISERVER/v2/services/THE-SERVICE/service_plans"] = NamedResourcesResponse{[]NamedResource{
+			namedResource("LARGE-PLAN", "large"),
+			namedResource("SMALL-PLAN", "small"),
+		}}
+		replies["APISERVER/v2/service_instances?accepts_incomplete=true"] = serviceInstance("THE-INSTANCE", "in progress")
+		replies["APISERVER/v2/service_instances/THE-INSTANCE"] = serviceInstance("THE-INSTANCE", "succeeded")
+		replies["APISERVER/v2/service_instances/THE-INSTANCE?accepts_incomplete=true"] = ""
+		replies["APISERVER/v2/service_bindings"] = ServiceInstanceResponse{Metadata: Metadata{"THE-BINDING"}}
+		replies["APISERVER/v2/service_bindings/THE-BINDING"] = ""
+		replies["APISERVER/v2/apps/THE-APP/restage"] = ""
+
+		rest.Target(context)
+		rest.Login(context)
+	})
+
+	It("Lists the marketplace", func() {
+		Ω(rest.Marketplace(context)).ShouldNot(HaveOccurred())
+		client.ShouldHaveBeenCalledWith("GET", "APISERVER/v2/spaces/THE-SPACE/services")
+	})
+
+	Describe("Creating a service instance", func() {
+		It("Provisions the configured plan in the space", func() {
+			Ω(rest.CreateService(context)).ShouldNot(HaveOccurred())
+			m := mapOf(client.ShouldHaveBeenCalledWith("POST", "APISERVER/v2/service_instances?accepts_incomplete=true"))
+			Ω(m["service_plan_guid"]).Should(Equal("SMALL-PLAN"))
+			Ω(m["space_guid"]).Should(Equal("THE-SPACE"))
+			Ω(context["service_instance_guid"]).Should(Equal("THE-INSTANCE"))
+		})
+
+		It("Waits for the last operation", func() {
+			rest.CreateService(context)
+			client.ShouldHaveBeenCalledWith("GET", "APISERVER/v2/service_instances/THE-INSTANCE")
+		})
+
+		It("Returns an error when provisioning fails", func() {
+			replies["APISERVER/v2/service_instances/THE-INSTANCE"] = serviceInstance("THE-INSTANCE", "failed")
+			Ω(rest.CreateService(context)).Should(HaveOccurred())
+		})
+
+		It("Escapes the service label", func() {
+			config := config.NewConfig()
+			rest.DescribeParameters(config)
+			config.Parse([]string{"-rest:target", "APISERVER", "-rest:service", "my sql+", "-rest:service-plan", "small"})
+			replies["APISERVER/v2/services?q=label:my+sql%2B"] = replies["APISERVER/v2/services?q=label:mysql"]
+			Ω(rest.CreateService(context)).ShouldNot(HaveOccurred())
+		})
+
+		It("Returns an error when the plan does not exist", func() {
+			replies["APISERVER/v2/services/THE-SERVICE/service_plans"] = NamedResourcesResponse{}
+			Ω(rest.CreateService(context)).Should(HaveOccurred())
+		})
+	})
+
+	Describe("Binding", func() {
+		BeforeEach(func() {
+			Ω(rest.Push(context)).ShouldNot(HaveOccurred())
+			Ω(rest.CreateService(context)).ShouldNot(HaveOccurred())
+		})
+
+		It("Binds the instance to the pushed app, restages, unbinds and deletes", func() {
+			Ω(rest.BindService(context)).ShouldNot(HaveOccurred())
+			m := mapOf(client.ShouldHaveBeenCalledWith("POST", "APISERVER/v2/service_bindings"))
+			Ω(m["app_guid"]).Should(Equal("THE-APP"))
+			Ω(m["service_instance_guid"]).Should(Equal("THE-INSTANCE"))
+
+			Ω(rest.Restage(context)).ShouldNot(HaveOccurred())
+			client.ShouldHaveBeenCalledWith("POST", "APISERVER/v2/apps/THE-APP/restage")
+
+			Ω(rest.UnbindService(context)).ShouldNot(HaveOccurred())
+			client.ShouldHaveBeenCalledWith("DELETE", "APISERVER/v2/service_bindings/THE-BINDING")
+
+			Ω(rest.DeleteService(context)).ShouldNot(HaveOccurred())
+			client.ShouldHaveBeenCalledWith("DELETE", "APISERVER/v2/service_instances/THE-INSTANCE?accepts_incomplete=true")
+			Ω(context).ShouldNot(HaveKey("service_instance_guid"))
+		})
+	})
+
+	It("Cannot bind before a service instance is created", func() {
+		rest.Push(context)
+		Ω(rest.BindService(context)).Should(HaveOccurred())
+	})
+})
@@ -40,7 +40,7 @@ var _ = Describe("Rest Workloads", func() {
 		args = []string{"-rest:target", "APISERVER"}
 		context = make(map[string]interface{})
 
-		replies["APISERVER/v2/info"] = TargetResponse{"THELOGINSERVER/PATH"}
+		replies["APISERVER/v2/info"] = TargetResponse{LoginEndpoint: "THELOGINSERVER/PATH"}
 	})
 
 	Describe("Pushing an app", func() {
@@ -240,6 +240,18 @@ func (d *dummyClient) Post(token string, host string, data interface{}, s interf
 	return d.Req("POST", host, data, s)
 }
 
+func (d *dummyClient) MultipartPost(token string, m *multipart.Writer, host string, data *bytes.Buffer, s interface{}) (reply Reply) {
+	return d.Req("POST(multipart)", host, data, s)
+}
+
+func (d *dummyClient) Patch(token string, host string, data interface{}, s interface{}) (reply Reply) {
+	return d.Req("PATCH", host, data, s)
+}
+
+func (d *dummyClient) Delete(token string, host string, data interface{}, s interface{}) (reply Reply) {
+	return d.Req("DELETE", host, data, s)
+}
+
 func (d *dummyClient) PostToUaa(host string, data url.Values, s interface{}) (reply Reply) {
 	return d.Req("POST(uaa)", host, data, s)
 }
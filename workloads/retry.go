@@ -0,0 +1,157 @@
+package workloads
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/config"
+)
+
+// How often, and on which errors, a workload step is tried again before its
+// iteration fails. The wait between attempts starts at Backoff and doubles
+// after each retry. RetryOn lists the classes of error which are retried:
+//
+//	5xx      the server replied with a 5xx status (e.g. a 502 from the router)
+//	4xx      the server replied with a 4xx status
+//	network  no reply, because the connection failed or timed out
+//	other    anything else, such as an app which never started
+//	any      all of the above
+type RetryPolicy struct {
+	Attempts int      `yaml:"attempts"`
+	Backoff  string   `yaml:"backoff"`
+	RetryOn  []string `yaml:"retry-on"`
+}
+
+// The error returned by a REST or HTTP step when the server replied with a
+// failing status. Code is 0 when there was no reply at all.
+type ReplyError struct {
+	Code    int
+	Message string
+}
+
+func (e *ReplyError) Error() string {
+	return e.Message
+}
+
+type retryConfig struct {
+	attempts int
+	backoff  string
+	on       string
+	steps    map[string]RetryPolicy
+}
+
+func newRetryConfig() *retryConfig {
+	return &retryConfig{}
+}
+
+func (r *retryConfig) DescribeParameters(config config.Config) {
+	r.steps = make(map[string]RetryPolicy)
+	config.IntVar(&r.attempts, "retry-attempts", 1, "times to try each workload step before failing the iteration (1 to never retry)")
+	config.StringVar(&r.backoff, "retry-backoff", "1s", "wait before the first retry of a step, doubled for each retry after it")
+	config.StringVar(&r.on, "retry-on", "5xx,network", "comma-separated classes of error to retry: 5xx, 4xx, network, other or any")
+	config.SectionVar(&r.steps, "retries", "retry policies (attempts, backoff, retry-on) for individual workload steps, by step name")
+}
+
+// The policy for the named step: its own policy from the "retries" section,
+// with anything it leaves out taken from the retry-* settings.
+func (r *retryConfig) policy(name string) RetryPolicy {
+	policy := r.steps[name]
+	if policy.Attempts == 0 {
+		policy.Attempts = r.attempts
+	}
+	if policy.Backoff == "" {
+		policy.Backoff = r.backoff
+	}
+	if len(policy.RetryOn) == 0 && r.on != "" {
+		policy.RetryOn = strings.Split(r.on, ",")
+	}
+
+	return policy
+}
+
+// Checks the policy can be run: no negative attempts, a valid backoff when
+// there are retries, and only known classes of error to retry.
+func (p RetryPolicy) Validate() error {
+	if p.Attempts < 0 {
+		return fmt.Errorf("Invalid retry attempts %d: must not be negative", p.Attempts)
+	}
+
+	if p.Attempts > 1 {
+		if _, err := time.ParseDuration(p.Backoff); err != nil {
+			return errors.New("Invalid retry backoff '" + p.Backoff + "': " + err.Error())
+		}
+	}
+
+	for _, on := range p.RetryOn {
+		switch strings.TrimSpace(on) {
+		case "5xx", "4xx", "network", "other", "any":
+		default:
+			return errors.New("Invalid class of error to retry: '" + on + "'")
+		}
+	}
+
+	return nil
+}
+
+// Calls fn until it succeeds, fails with an error the policy does not retry,
+// or has been tried Attempts times. Returns the number of attempts made and
+// how long the first one took, along with fn's last error.
+func (p RetryPolicy) Run(fn func() error) (attempts int, first time.Duration, err error) {
+	var backoff time.Duration
+	if p.Attempts > 1 {
+		if backoff, err = time.ParseDuration(p.Backoff); err != nil {
+			return 0, 0, errors.New("Invalid retry backoff '" + p.Backoff + "': " + err.Error())
+		}
+	}
+
+	for {
+		start := time.Now()
+		err = fn()
+		attempts = attempts + 1
+		if attempts == 1 {
+			first = time.Now().Sub(start)
+		}
+
+		if err == nil || attempts >= p.Attempts || !p.retries(err) {
+			return attempts, first, err
+		}
+
+		time.Sleep(backoff)
+		backoff = backoff * 2
+	}
+}
+
+func (p RetryPolicy) retries(err error) bool {
+	class := ErrorClass(err)
+	for _, on := range p.RetryOn {
+		on = strings.TrimSpace(on)
+		if on == class || on == "any" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The class of a step's error, as used by RetryPolicy.RetryOn.
+func ErrorClass(err error) string {
+	if reply, ok := err.(*ReplyError); ok {
+		switch {
+		case reply.Code == 0:
+			return "network"
+		case reply.Code >= 500:
+			return "5xx"
+		case reply.Code >= 400:
+			return "4xx"
+		}
+	}
+
+	if _, ok := err.(net.Error); ok {
+		return "network"
+	}
+
+	return "other"
+}
@@ -0,0 +1,141 @@
+package workloads_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retry policies", func() {
+	Describe("Classifying errors", func() {
+		It("Classifies failing replies by their status", func() {
+			Ω(ErrorClass(&ReplyError{Code: 502, Message: "502 Bad Gateway"})).Should(Equal("5xx"))
+			Ω(ErrorClass(&ReplyError{Code: 404, Message: "404 Not Found"})).Should(Equal("4xx"))
+			Ω(ErrorClass(&ReplyError{Code: 0, Message: "connection refused"})).Should(Equal("network"))
+		})
+
+		It("Classifies network errors and anything else", func() {
+			_, err := net.Dial("tcp", "127.0.0.1:1")
+			Ω(ErrorClass(err)).Should(Equal("network"))
+			Ω(ErrorClass(errors.New("App never started"))).Should(Equal("other"))
+		})
+	})
+
+	Describe("Running a step", func() {
+		It("Runs the step once when there is no policy", func() {
+			calls := 0
+			attempts, _, err := RetryPolicy{}.Run(func() error { calls++; return &ReplyError{Code: 502, Message: "502"} })
+			Ω(err).Should(HaveOccurred())
+			Ω(attempts).Should(Equal(1))
+			Ω(calls).Should(Equal(1))
+		})
+
+		It("Doubles the backoff after each retry", func() {
+			var times []time.Time
+			policy := RetryPolicy{Attempts: 3, Backoff: "100ms", RetryOn: []string{"any"}}
+			attempts, _, err := policy.Run(func() error {
+				times = append(times, time.Now())
+				return errors.New("broken")
+			})
+			Ω(err).Should(HaveOccurred())
+			Ω(attempts).Should(Equal(3))
+			Ω(times[1].Sub(times[0]).Seconds()).Should(BeNumerically("~", 0.1, 0.05))
+			Ω(times[2].Sub(times[1]).Seconds()).Should(BeNumerically("~", 0.2, 0.05))
+		})
+
+		It("Fails without running the step when the backoff is invalid", func() {
+			_, _, err := RetryPolicy{Attempts: 2, Backoff: "soon"}.Run(func() error { return nil })
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	Describe("Configuring policies", func() {
+		var (
+			steps *stepList
+			yml   string
+			args  []string
+			err   error
+		)
+
+		BeforeEach(func() {
+			yml = ""
+			args = []string{}
+		})
+
+		JustBeforeEach(func() {
+			workloads := DefaultWorkloadList()
+			flags := config.NewConfig()
+			workloads.DescribeParameters(flags)
+			if yml != "" {
+				file, _ := ioutil.TempFile("", "retries")
+				defer os.Remove(file.Name())
+				file.WriteString(yml)
+				file.Close()
+				args = append([]string{"-config", file.Name()}, args...)
+			}
+			Ω(flags.Parse(args)).ShouldNot(HaveOccurred())
+
+			steps = &stepList{make(map[string]WorkloadStep)}
+			err = workloads.DescribeWorkloads(steps)
+		})
+
+		It("Does not retry by default", func() {
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(steps.steps["rest:push"].Retry.Attempts).Should(Equal(1))
+		})
+
+		Context("When the retry settings are given", func() {
+			BeforeEach(func() {
+				args = []string{"-retry-attempts", "3", "-retry-backoff", "2s", "-retry-on", "5xx,4xx"}
+			})
+
+			It("Applies them to every step", func() {
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(steps.steps["rest:login"].Retry).Should(Equal(RetryPolicy{3, "2s", []string{"5xx", "4xx"}}))
+				Ω(steps.steps["dummy"].Retry).Should(Equal(RetryPolicy{3, "2s", []string{"5xx", "4xx"}}))
+			})
+		})
+
+		Context("When a step has its own policy", func() {
+			BeforeEach(func() {
+				yml = "retries:\n  rest:push:\n    attempts: 5\n    retry-on: [network]\n"
+				args = []string{"-retry-backoff", "3s"}
+			})
+
+			It("Uses it, with anything it leaves out taken from the retry settings", func() {
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(steps.steps["rest:push"].Retry).Should(Equal(RetryPolicy{5, "3s", []string{"network"}}))
+				Ω(steps.steps["rest:login"].Retry.Attempts).Should(Equal(1))
+			})
+		})
+
+		Context("When the backoff is invalid", func() {
+			BeforeEach(func() {
+				args = []string{"-retry-attempts", "3", "-retry-backoff", "soon"}
+			})
+
+			It("Fails before adding any step", func() {
+				Ω(err).Should(MatchError(ContainSubstring("Invalid retry backoff 'soon'")))
+				Ω(steps.steps).Should(BeEmpty())
+			})
+		})
+
+		Context("When a step's policy retries an unknown class of error", func() {
+			BeforeEach(func() {
+				yml = "retries:\n  rest:push:\n    retry-on: [timeout]\n"
+			})
+
+			It("Fails, naming the step", func() {
+				Ω(err).Should(MatchError(ContainSubstring("rest:push")))
+				Ω(err).Should(MatchError(ContainSubstring("'timeout'")))
+			})
+		})
+	})
+})
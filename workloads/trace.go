@@ -0,0 +1,136 @@
+package workloads
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// The timings of one HTTP request made by a workload step. FirstByte and
+// Total are measured from the start of the request. Dns, Connect and Tls are
+// the durations of those phases, and are zero when a connection was reused.
+// SpanId is only set when the step is part of a trace.
+type RequestTrace struct {
+	Start     time.Time
+	SpanId    string
+	Method    string
+	Url       string
+	Status    int
+	RequestId string
+	Dns       time.Duration
+	Connect   time.Duration
+	Tls       time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+}
+
+// The requests made by one step. The worker puts a new RequestLog in the
+// context, as "requests", before it runs each step. When the iteration is
+// traced, TraceId and SpanId identify the step's span, and each request is
+// sent with a W3C traceparent header naming a child span of it.
+type RequestLog struct {
+	TraceId  string
+	SpanId   string
+	requests []RequestTrace
+	lock     sync.Mutex
+}
+
+func (l *RequestLog) Add(trace RequestTrace) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.requests = append(l.requests, trace)
+}
+
+func (l *RequestLog) Requests() []RequestTrace {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.requests
+}
+
+// A random W3C trace id (16 bytes, hex encoded).
+func NewTraceId() string {
+	return randomHex(16)
+}
+
+// A random W3C span id (8 bytes, hex encoded).
+func NewSpanId() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func requestLog(ctx map[string]interface{}) *RequestLog {
+	log, _ := ctx["requests"].(*RequestLog)
+	return log
+}
+
+// Makes the request, calling read with the response, and records the
+// request's timings in log (when there is one).
+func traceRequest(log *RequestLog, client *http.Client, req *http.Request, read func(resp *http.Response)) error {
+	if log == nil {
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		read(resp)
+		return nil
+	}
+
+	// The trace functions may be called from other goroutines.
+	trace := RequestTrace{Method: req.Method, Url: req.URL.String()}
+	if log.TraceId != "" {
+		trace.SpanId = NewSpanId()
+		req.Header.Set("traceparent", "00-"+log.TraceId+"-"+trace.SpanId+"-01")
+	}
+
+	var lock sync.Mutex
+	var start, dnsStart, connectStart, tlsStart time.Time
+	mark := func(t *time.Time) {
+		lock.Lock()
+		defer lock.Unlock()
+		*t = time.Now()
+	}
+	since := func(d *time.Duration, t *time.Time) {
+		lock.Lock()
+		defer lock.Unlock()
+		*d = time.Now().Sub(*t)
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { mark(&dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { since(&trace.Dns, &dnsStart) },
+		ConnectStart:         func(string, string) { mark(&connectStart) },
+		ConnectDone:          func(string, string, error) { since(&trace.Connect, &connectStart) },
+		TLSHandshakeStart:    func() { mark(&tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { since(&trace.Tls, &tlsStart) },
+		GotFirstResponseByte: func() { since(&trace.FirstByte, &start) },
+	}))
+
+	mark(&start)
+	trace.Start = start
+	resp, err := client.Do(req)
+	if err == nil {
+		read(resp)
+		resp.Body.Close()
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	trace.Total = time.Now().Sub(start)
+	if err == nil {
+		trace.Status = resp.StatusCode
+		trace.RequestId = resp.Header.Get("X-Vcap-Request-Id")
+	}
+	log.Add(trace)
+	return err
+}
@@ -0,0 +1,88 @@
+package workloads_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type stepList struct {
+	steps map[string]WorkloadStep
+}
+
+func (l *stepList) AddWorkloadStep(step WorkloadStep) {
+	l.steps[step.Name] = step
+}
+
+var _ = Describe("Request tracing", func() {
+	var (
+		server      *httptest.Server
+		requests    *RequestLog
+		context     map[string]interface{}
+		traceparent string
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceparent = r.Header.Get("traceparent")
+			w.Header().Set("X-Vcap-Request-Id", "THE-REQUEST-ID")
+			fmt.Fprint(w, `{"authorization_endpoint":"THELOGINSERVER"}`)
+		}))
+		requests = &RequestLog{}
+		context = map[string]interface{}{"requests": requests}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Records the requests made by HTTP steps", func() {
+		Ω(HttpStep{Url: server.URL + "/foo"}.Run(context, http.DefaultClient)).ShouldNot(HaveOccurred())
+		Ω(requests.Requests()).Should(HaveLen(1))
+
+		trace := requests.Requests()[0]
+		Ω(trace.Method).Should(Equal("GET"))
+		Ω(trace.Url).Should(Equal(server.URL + "/foo"))
+		Ω(trace.Status).Should(Equal(200))
+		Ω(trace.RequestId).Should(Equal("THE-REQUEST-ID"))
+		Ω(trace.Connect).ShouldNot(BeZero())
+		Ω(trace.FirstByte).ShouldNot(BeZero())
+		Ω(trace.Total).Should(BeNumerically(">=", trace.FirstByte))
+	})
+
+	It("Does not send a traceparent header when the iteration is not traced", func() {
+		Ω(HttpStep{Url: server.URL}.Run(context, http.DefaultClient)).ShouldNot(HaveOccurred())
+		Ω(traceparent).Should(BeEmpty())
+		Ω(requests.Requests()[0].SpanId).Should(BeEmpty())
+	})
+
+	It("Propagates the trace to the server when the iteration is traced", func() {
+		requests.TraceId, requests.SpanId = NewTraceId(), NewSpanId()
+		Ω(HttpStep{Url: server.URL}.Run(context, http.DefaultClient)).ShouldNot(HaveOccurred())
+
+		trace := requests.Requests()[0]
+		Ω(trace.SpanId).Should(HaveLen(16))
+		Ω(trace.SpanId).ShouldNot(Equal(requests.SpanId))
+		Ω(traceparent).Should(Equal("00-" + requests.TraceId + "-" + trace.SpanId + "-01"))
+	})
+
+	It("Records the requests made by REST steps", func() {
+		workloads := DefaultWorkloadList()
+		config := config.NewConfig()
+		workloads.DescribeParameters(config)
+		Ω(config.Parse([]string{"-rest:target", server.URL})).ShouldNot(HaveOccurred())
+
+		steps := &stepList{make(map[string]WorkloadStep)}
+		Ω(workloads.DescribeWorkloads(steps)).ShouldNot(HaveOccurred())
+		Ω(steps.steps["rest:target"].Fn(context)).ShouldNot(HaveOccurred())
+
+		Ω(requests.Requests()).Should(HaveLen(1))
+		Ω(requests.Requests()[0].Url).Should(Equal(server.URL + "/v2/info"))
+		Ω(requests.Requests()[0].RequestId).Should(Equal("THE-REQUEST-ID"))
+	})
+})
@@ -0,0 +1,147 @@
+package workloads
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/config"
+)
+
+// How the rest workloads talk HTTP: TLS validation, proxy, connection pooling
+// and timeouts. The client is built on first use, after the configuration
+// has been parsed, and is shared by all workers, except that when
+// rest:connections is iteration each iteration is given a client of its own.
+type transportConfig struct {
+	skipSslValidation bool
+	caCert            string
+	proxy             string
+	maxIdle           int
+	timeout           int
+	connections       string
+	client            *http.Client
+	tlsConfig         *tls.Config
+	proxyFunc         func(*http.Request) (*url.URL, error)
+	err               error
+	lock              sync.Mutex
+}
+
+// The context key an iteration's own client is kept under.
+const iterationClientKey = "http_client"
+
+// A client used by only one iteration. The worker closes it, along with
+// its connections, when the iteration ends.
+type iterationClient struct {
+	*http.Client
+}
+
+func (c *iterationClient) Close() error {
+	c.Transport.(*http.Transport).CloseIdleConnections()
+	return nil
+}
+
+func (t *transportConfig) DescribeParameters(config config.Config) {
+	config.BoolVar(&t.skipSslValidation, "rest:skip-ssl-validation", false, "do not validate the target's SSL certificates (for self-signed foundations)")
+	config.StringVar(&t.caCert, "rest:ca-cert", "", "PEM file of the CA certificates to trust instead of the system ones")
+	config.StringVar(&t.proxy, "rest:proxy", "", "HTTP proxy URL for REST requests (default: from HTTP_PROXY/HTTPS_PROXY)")
+	config.IntVar(&t.maxIdle, "rest:max-idle-connections", http.DefaultMaxIdleConnsPerHost, "idle connections kept open to each host for reuse")
+	config.IntVar(&t.timeout, "rest:request-timeout", 0, "seconds after which a REST request fails (default: no timeout)")
+	config.StringVar(&t.connections, "rest:connections", "reuse", "reuse (share connections between iterations), iteration (open new connections in each iteration) or request (a new connection for every request)")
+	t.client, t.err = nil, nil
+}
+
+// The shared client, built the first time it is needed.
+func (t *transportConfig) httpClient() (*http.Client, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.client == nil && t.err == nil {
+		t.client, t.err = t.build()
+	}
+
+	return t.client, t.err
+}
+
+// The client for the iteration ctx belongs to: the shared client or, when
+// each iteration uses new connections, the iteration's own client, which is
+// created the first time it is needed and kept in ctx.
+func (t *transportConfig) clientFor(ctx map[string]interface{}) (*http.Client, error) {
+	shared, err := t.httpClient()
+	if err != nil || t.connections != "iteration" || ctx == nil {
+		return shared, err
+	}
+
+	if own, ok := ctx[iterationClientKey].(*iterationClient); ok {
+		return own.Client, nil
+	}
+
+	own := &iterationClient{&http.Client{Transport: t.newTransport(), Timeout: shared.Timeout}}
+	ctx[iterationClientKey] = own
+	return own.Client, nil
+}
+
+// A client using the same connections as clientFor(ctx), but with its own
+// timeout.
+func (t *transportConfig) clientWithTimeout(ctx map[string]interface{}, timeout time.Duration) (*http.Client, error) {
+	client, err := t.clientFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Transport: client.Transport, Timeout: timeout}, nil
+}
+
+// The TLS settings, for connections not made by the client (the log stream).
+func (t *transportConfig) tls() (*tls.Config, error) {
+	if _, err := t.httpClient(); err != nil {
+		return nil, err
+	}
+
+	return t.tlsConfig, nil
+}
+
+func (t *transportConfig) build() (*http.Client, error) {
+	switch t.connections {
+	case "reuse", "iteration", "request":
+	default:
+		return nil, errors.New("rest:connections must be reuse, iteration or request")
+	}
+
+	t.tlsConfig = &tls.Config{InsecureSkipVerify: t.skipSslValidation}
+	if t.caCert != "" {
+		pem, err := ioutil.ReadFile(t.caCert)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("No certificates found in " + t.caCert)
+		}
+		t.tlsConfig.RootCAs = pool
+	}
+
+	t.proxyFunc = http.ProxyFromEnvironment
+	if t.proxy != "" {
+		proxyUrl, err := url.Parse(t.proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.proxyFunc = http.ProxyURL(proxyUrl)
+	}
+
+	return &http.Client{Transport: t.newTransport(), Timeout: time.Duration(t.timeout) * time.Second}, nil
+}
+
+func (t *transportConfig) newTransport() *http.Transport {
+	return &http.Transport{
+		Proxy:               t.proxyFunc,
+		TLSClientConfig:     t.tlsConfig,
+		MaxIdleConnsPerHost: t.maxIdle,
+		DisableKeepAlives:   t.connections == "request",
+	}
+}
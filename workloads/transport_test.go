@@ -0,0 +1,164 @@
+package workloads_test
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/benchmarker"
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("REST transport settings", func() {
+	var (
+		rest        workloads
+		server      *httptest.Server
+		args        []string
+		connections int
+		closed      int32
+		delay       time.Duration
+	)
+
+	target := func() error {
+		return rest.Target(make(map[string]interface{}))
+	}
+
+	BeforeEach(func() {
+		connections = 0
+		closed = 0
+		delay = 0
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			fmt.Fprint(w, `{"authorization_endpoint":"THELOGINSERVER"}`)
+		}))
+		server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				connections++
+			}
+			if state == http.StateClosed {
+				atomic.AddInt32(&closed, 1)
+			}
+		}
+		server.StartTLS()
+		args = []string{"-rest:target", server.URL}
+	})
+
+	JustBeforeEach(func() {
+		rest = NewRestWorkload()
+		config := config.NewConfig()
+		rest.DescribeParameters(config)
+		Ω(config.Parse(args)).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Validates SSL certificates by default", func() {
+		Ω(target()).Should(HaveOccurred())
+	})
+
+	Context("When SSL validation is skipped", func() {
+		BeforeEach(func() {
+			args = append(args, "-rest:skip-ssl-validation")
+		})
+
+		It("Accepts self-signed certificates", func() {
+			Ω(target()).ShouldNot(HaveOccurred())
+		})
+
+		It("Reuses connections between iterations", func() {
+			target()
+			target()
+			Ω(connections).Should(Equal(1))
+		})
+
+		Context("And each iteration uses new connections", func() {
+			BeforeEach(func() {
+				args = append(args, "-rest:connections", "iteration")
+			})
+
+			It("Opens a connection per iteration, and closes it when the iteration ends", func() {
+				workloads := DefaultWorkloadList()
+				config := config.NewConfig()
+				workloads.DescribeParameters(config)
+				Ω(config.Parse(args)).ShouldNot(HaveOccurred())
+				worker := benchmarker.NewWorker()
+				Ω(workloads.DescribeWorkloads(worker)).ShouldNot(HaveOccurred())
+
+				Ω(worker.Time("rest:target", nil).Error).ShouldNot(HaveOccurred())
+				Eventually(func() int32 { return atomic.LoadInt32(&closed) }).Should(Equal(int32(1)))
+				Ω(worker.Time("rest:target", nil).Error).ShouldNot(HaveOccurred())
+				Eventually(func() int32 { return atomic.LoadInt32(&closed) }).Should(Equal(int32(2)))
+				Ω(connections).Should(Equal(2))
+			})
+
+			It("Shares connections between the requests of an iteration", func() {
+				workloads := DefaultWorkloadList()
+				config := config.NewConfig()
+				workloads.DescribeParameters(config)
+				Ω(config.Parse(args)).ShouldNot(HaveOccurred())
+				steps := &stepList{make(map[string]WorkloadStep)}
+				Ω(workloads.DescribeWorkloads(steps)).ShouldNot(HaveOccurred())
+
+				context := make(map[string]interface{})
+				Ω(steps.steps["rest:target"].Fn(context)).ShouldNot(HaveOccurred())
+				Ω(steps.steps["rest:target"].Fn(context)).ShouldNot(HaveOccurred())
+				Ω(connections).Should(Equal(1))
+			})
+		})
+
+		Context("And each request uses a new connection", func() {
+			BeforeEach(func() {
+				args = append(args, "-rest:connections", "request")
+			})
+
+			It("Opens a connection per request", func() {
+				target()
+				target()
+				Ω(connections).Should(Equal(2))
+			})
+		})
+
+		Context("And requests time out", func() {
+			BeforeEach(func() {
+				delay = 2 * time.Second
+				args = append(args, "-rest:request-timeout", "1")
+			})
+
+			It("Fails slow requests", func() {
+				Ω(target()).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Context("When the server's CA is trusted", func() {
+		BeforeEach(func() {
+			cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.TLS.Certificates[0].Certificate[0]})
+			ioutil.WriteFile("/tmp/pat-ca.pem", cert, 0644)
+			args = append(args, "-rest:ca-cert", "/tmp/pat-ca.pem")
+		})
+
+		It("Accepts its certificates", func() {
+			Ω(target()).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("When rest:connections is not recognised", func() {
+		BeforeEach(func() {
+			args = append(args, "-rest:skip-ssl-validation", "-rest:connections", "sometimes")
+		})
+
+		It("Returns an error", func() {
+			Ω(target()).Should(HaveOccurred())
+		})
+	})
+})
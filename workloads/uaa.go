@@ -0,0 +1,199 @@
+package workloads
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudfoundry-community/pat/config"
+)
+
+// Tokens are treated as expired this long before UAA says they expire, so
+// that they do not expire part way through an iteration.
+const tokenExpiryMargin = 30 * time.Second
+
+type credentials struct {
+	username string
+	password string
+}
+
+type token struct {
+	access  string
+	refresh string
+	expires time.Time
+}
+
+type tokenCache struct {
+	tokens map[string]*token
+	lock   sync.Mutex
+}
+
+// A pool of users to log in as, loaded from the rest:users CSV file.
+type userPool struct {
+	path  string
+	users []credentials
+	err   error
+	next  uint32
+	lock  sync.Mutex
+}
+
+type uaaConfig struct {
+	grantType      string
+	clientId       string
+	clientSecret   string
+	usersFile      string
+	userAssignment string
+	reuseTokens    bool
+	tokens         *tokenCache
+	pool           *userPool
+}
+
+func newUaaConfig() *uaaConfig {
+	return &uaaConfig{tokens: &tokenCache{tokens: make(map[string]*token)}, pool: &userPool{}}
+}
+
+func (u *uaaConfig) DescribeParameters(config config.Config) {
+	config.StringVar(&u.grantType, "rest:grant-type", "password", "OAuth grant used by rest:login: password or client_credentials")
+	config.StringVar(&u.clientId, "rest:client-id", "cf", "OAuth client used by rest:login")
+	config.StringVar(&u.clientSecret, "rest:client-secret", "", "secret of the OAuth client used by rest:login")
+	config.StringVar(&u.usersFile, "rest:users", "", "CSV file of username,password pairs to log in as instead of rest:username")
+	config.StringVar(&u.userAssignment, "rest:user-assignment", "round-robin", "how users from rest:users are chosen: round-robin (each login takes the next user) or worker (each concurrent worker keeps its own user)")
+	config.BoolVar(&u.reuseTokens, "rest:reuse-tokens", false, "keep tokens between iterations, refreshing them when they expire, rather than logging in every iteration")
+}
+
+// Logs in, or reuses or refreshes a cached token, and sets ctx["token"].
+func (r *rest) authenticate(ctx map[string]interface{}, loginEndpoint string) error {
+	user, err := r.credentialsFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	key := r.uaa.clientId + ":" + user.username
+	if r.uaa.reuseTokens {
+		if cached := r.uaa.tokens.get(key); cached != nil {
+			if time.Now().Before(cached.expires) {
+				ctx["token"] = cached.access
+				return nil
+			}
+
+			if cached.refresh != "" {
+				values := make(url.Values)
+				values.Add("grant_type", "refresh_token")
+				values.Add("refresh_token", cached.refresh)
+				if err := r.requestToken(ctx, loginEndpoint, key, values); err == nil {
+					return nil
+				}
+			}
+		}
+	}
+
+	return r.requestToken(ctx, loginEndpoint, key, r.uaa.grantInputs(user))
+}
+
+func (r *rest) requestToken(ctx map[string]interface{}, loginEndpoint string, key string, values url.Values) error {
+	body := &TokenResponse{}
+	return r.PostToUaaSuccessfully(fmt.Sprintf("%s/oauth/token", loginEndpoint), values, body, func(reply Reply) error {
+		ctx["token"] = body.AccessToken
+		if r.uaa.reuseTokens {
+			r.uaa.tokens.put(key, &token{body.AccessToken, body.RefreshToken, time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - tokenExpiryMargin)})
+		}
+		return nil
+	})
+}
+
+func (u *uaaConfig) grantInputs(user credentials) url.Values {
+	values := make(url.Values)
+	if u.grantType == "client_credentials" {
+		values.Add("grant_type", "client_credentials")
+		return values
+	}
+
+	values.Add("grant_type", "password")
+	values.Add("username", user.username)
+	values.Add("password", user.password)
+	values.Add("scope", "")
+	return values
+}
+
+func (r *rest) credentialsFor(ctx map[string]interface{}) (credentials, error) {
+	if users := r.fixture.createdUsers(); len(users) > 0 {
+		return r.uaa.assign(ctx, users), nil
+	}
+
+	if r.uaa.usersFile == "" {
+		return credentials{r.username, r.password}, nil
+	}
+
+	users, err := r.uaa.pool.load(r.uaa.usersFile)
+	if err != nil {
+		return credentials{}, err
+	}
+
+	return r.uaa.assign(ctx, users), nil
+}
+
+func (u *uaaConfig) assign(ctx map[string]interface{}, users []credentials) credentials {
+	if u.userAssignment == "worker" {
+		worker, _ := ctx["worker"].(int)
+		return users[worker%len(users)]
+	}
+
+	return users[int(atomic.AddUint32(&u.pool.next, 1)-1)%len(users)]
+}
+
+func (p *userPool) load(path string) ([]credentials, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.path == path {
+		return p.users, p.err
+	}
+
+	p.path, p.users, p.err = path, nil, nil
+	file, err := os.Open(path)
+	if err != nil {
+		p.err = err
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		p.err = err
+		return nil, err
+	}
+
+	for i, record := range records {
+		if len(record) < 2 {
+			p.err = fmt.Errorf("%s line %d: expected username,password", path, i+1)
+			return nil, p.err
+		}
+		if i == 0 && strings.EqualFold(record[0], "username") {
+			continue
+		}
+		p.users = append(p.users, credentials{record[0], record[1]})
+	}
+
+	if len(p.users) == 0 {
+		p.err = errors.New("No users found in " + path)
+	}
+
+	return p.users, p.err
+}
+
+func (c *tokenCache) get(key string) *token {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tokens[key]
+}
+
+func (c *tokenCache) put(key string, t *token) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tokens[key] = t
+}
@@ -0,0 +1,117 @@
+package workloads_test
+
+import (
+	"io/ioutil"
+	"net/url"
+
+	"github.com/cloudfoundry-community/pat/config"
+	. "github.com/cloudfoundry-community/pat/workloads"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UAA tokens", func() {
+	var (
+		client  *dummyClient
+		rest    workloads
+		args    []string
+		replies map[string]interface{}
+	)
+
+	login := func(worker int) url.Values {
+		context := map[string]interface{}{"worker": worker}
+		Ω(rest.Target(context)).ShouldNot(HaveOccurred())
+		delete(client.calls, call{"POST(uaa)", "THELOGINSERVER/PATH/oauth/token"})
+		Ω(rest.Login(context)).ShouldNot(HaveOccurred())
+		if data, ok := client.calls[call{"POST(uaa)", "THELOGINSERVER/PATH/oauth/token"}]; ok {
+			return data.(url.Values)
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		args = []string{}
+		ioutil.WriteFile("/tmp/pat-users.csv", []byte("username,password\nalice,a-pass\nbob,b-pass\ncarol,c-pass\n"), 0644)
+	})
+
+	JustBeforeEach(func() {
+		replies = make(map[string]interface{})
+		client = &dummyClient{replies, make(map[string]string), make(map[call]interface{})}
+		rest = NewRestWorkloadWithClient(client)
+		config := config.NewConfig()
+		rest.DescribeParameters(config)
+		Ω(config.Parse(append([]string{"-rest:target", "APISERVER", "-rest:username", "dev", "-rest:password", "pass"}, args...))).ShouldNot(HaveOccurred())
+
+		replies["APISERVER/v2/info"] = TargetResponse{LoginEndpoint: "THELOGINSERVER/PATH"}
+		replies["THELOGINSERVER/PATH/oauth/token"] = TokenResponse{"the-token", "the-refresh-token", 3600}
+		replies["APISERVER/v2/spaces?q=name:dev"] = SpaceResponse{[]Resource{Resource{Metadata{"THE-SPACE"}}}}
+	})
+
+	It("Logs in every iteration by default", func() {
+		Ω(login(0)["username"]).Should(Equal([]string{"dev"}))
+		Ω(login(0)["username"]).Should(Equal([]string{"dev"}))
+	})
+
+	Context("When tokens are reused", func() {
+		BeforeEach(func() {
+			args = []string{"-rest:reuse-tokens"}
+		})
+
+		It("Only logs in once while the token is valid", func() {
+			Ω(login(0)).ShouldNot(BeNil())
+			Ω(login(0)).Should(BeNil())
+		})
+
+		Context("And the token has expired", func() {
+			JustBeforeEach(func() {
+				replies["THELOGINSERVER/PATH/oauth/token"] = TokenResponse{"the-token", "the-refresh-token", 0}
+			})
+
+			It("Uses the refresh token", func() {
+				login(0)
+				refresh := login(0)
+				Ω(refresh["grant_type"]).Should(Equal([]string{"refresh_token"}))
+				Ω(refresh["refresh_token"]).Should(Equal([]string{"the-refresh-token"}))
+			})
+		})
+	})
+
+	Context("When using the client credentials grant", func() {
+		BeforeEach(func() {
+			args = []string{"-rest:grant-type", "client_credentials", "-rest:client-id", "pat", "-rest:client-secret", "s3cret"}
+		})
+
+		It("Does not send a username or password", func() {
+			data := login(0)
+			Ω(data["grant_type"]).Should(Equal([]string{"client_credentials"}))
+			Ω(data).ShouldNot(HaveKey("username"))
+		})
+	})
+
+	Context("When a pool of users is given", func() {
+		BeforeEach(func() {
+			args = []string{"-rest:users", "/tmp/pat-users.csv"}
+		})
+
+		It("Assigns them round-robin", func() {
+			Ω(login(0)["username"]).Should(Equal([]string{"alice"}))
+			Ω(login(0)["username"]).Should(Equal([]string{"bob"}))
+			Ω(login(0)["username"]).Should(Equal([]string{"carol"}))
+			Ω(login(0)["username"]).Should(Equal([]string{"alice"}))
+			Ω(login(0)["password"]).Should(Equal([]string{"b-pass"}))
+		})
+
+		Context("And users are assigned per worker", func() {
+			BeforeEach(func() {
+				args = append(args, "-rest:user-assignment", "worker")
+			})
+
+			It("Always logs a worker in as the same user", func() {
+				Ω(login(1)["username"]).Should(Equal([]string{"bob"}))
+				Ω(login(1)["username"]).Should(Equal([]string{"bob"}))
+				Ω(login(0)["username"]).Should(Equal([]string{"alice"}))
+				Ω(login(4)["username"]).Should(Equal([]string{"bob"}))
+			})
+		})
+	})
+})
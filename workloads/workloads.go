@@ -1,6 +1,8 @@
 package workloads
 
 import (
+	"fmt"
+
 	"github.com/cloudfoundry-community/pat/config"
 )
 
@@ -12,6 +14,7 @@ type WorkloadStep struct {
 	Name        string
 	Fn          func(context map[string]interface{}) error
 	Description string
+	Retry       RetryPolicy
 }
 
 type WorkloadList struct {
@@ -19,12 +22,48 @@ type WorkloadList struct {
 }
 
 var restContext = NewRestWorkload()
+var rest3Context = NewRest3Workload(restContext)
+var httpContext = NewHttpWorkload(restContext)
+var execContext = NewExecWorkload()
+var pluginContext = NewPluginWorkload(pluginsDir())
+var retryContext = newRetryConfig()
 
 func DefaultWorkloadList() *WorkloadList {
 	return &WorkloadList{[]WorkloadStep{
-		StepWithContext("rest:target", restContext.Target, "Sets the CF target"),
-		StepWithContext("rest:login", restContext.Login, "Performs a login to the REST api. This option requires rest:target to be included in the list of workloads"),
-		StepWithContext("rest:push", restContext.Push, "Pushes a simple Ruby application using the REST api. This option requires both rest:target and rest:login to be included in the list of workloads"),
+		StepWithContext("rest:target", restContext.step((*rest).Target), "Sets the CF target"),
+		StepWithContext("rest:login", restContext.step((*rest).Login), "Performs a login to the REST api. This option requires rest:target to be included in the list of workloads"),
+		StepWithContext("rest:push", restContext.step((*rest).Push), "Pushes a simple Ruby application using the REST api. This option requires both rest:target and rest:login to be included in the list of workloads"),
+		StepWithContext("rest:stop", restContext.step((*rest).Stop), "Stops the app pushed by rest:push"),
+		StepWithContext("rest:start", restContext.step((*rest).Start), "Starts the app pushed by rest:push and waits for it to run"),
+		StepWithContext("rest:restart", restContext.step((*rest).Restart), "Stops and then starts the app pushed by rest:push"),
+		StepWithContext("rest:scale", restContext.step((*rest).Scale), "Scales the instances and memory of the app pushed by rest:push (see rest:scale-instances and rest:scale-memory)"),
+		StepWithContext("rest:rename", restContext.step((*rest).Rename), "Gives the app pushed by rest:push a new random name"),
+		StepWithContext("rest:env", restContext.step((*rest).UpdateEnv), "Sets an environment variable on the app pushed by rest:push"),
+		StepWithContext("rest:delete", restContext.step((*rest).DeleteApp), "Deletes the app pushed by rest:push"),
+		StepWithContext("rest:list", restContext.step((*rest).ListApps), "Lists the apps in the targetted space. This option requires rest:login"),
+		StepWithContext("rest:summary", restContext.step((*rest).Summary), "Gets the summary of the app pushed by rest:push"),
+		StepWithContext("rest:create-route", restContext.step((*rest).CreateRoute), "Creates a route with a random host in the rest:domain shared domain"),
+		StepWithContext("rest:map-route", restContext.step((*rest).MapRoute), "Maps the route created by rest:create-route to the app pushed by rest:push"),
+		StepWithContext("rest:reachable", restContext.step((*rest).WaitUntilReachable), "Polls the route mapped by rest:map-route until the app answers (see rest:reachable-body)"),
+		StepWithContext("rest:tail-logs", restContext.step((*rest).TailLogs), "Opens a stream of the pushed app's logs from Doppler"),
+		StepWithContext("rest:log-marker", restContext.step((*rest).LogMarker), "Requests the route mapped by rest:map-route with a unique marker and waits for the marker to arrive on the stream opened by rest:tail-logs"),
+		StepWithContext("rest:recent-logs", restContext.step((*rest).RecentLogs), "Fetches the pushed app's recent logs from Doppler"),
+		StepWithContext("rest:marketplace", restContext.step((*rest).Marketplace), "Lists the services available in the targetted space. This option requires rest:login"),
+		StepWithContext("rest:create-service", restContext.step((*rest).CreateService), "Creates an instance of the rest:service-plan plan of rest:service and waits for it to be provisioned"),
+		StepWithContext("rest:bind-service", restContext.step((*rest).BindService), "Binds the service instance created by rest:create-service to the app pushed by rest:push"),
+		StepWithContext("rest:restage", restContext.step((*rest).Restage), "Restages the app pushed by rest:push and waits for it to run"),
+		StepWithContext("rest:unbind-service", restContext.step((*rest).UnbindService), "Unbinds the service bound by rest:bind-service"),
+		StepWithContext("rest:delete-service", restContext.step((*rest).DeleteService), "Deletes the service instance created by rest:create-service and waits for it to be deprovisioned"),
+		StepWithContext("rest3:target", rest3Context.step((*rest3).Target), "Sets the CF target using the v3 API"),
+		StepWithContext("rest3:login", rest3Context.step((*rest3).Login), "Performs a login and finds the space using the v3 API. This option requires rest3:target to be included in the list of workloads"),
+		StepWithContext("rest3:create-app", rest3Context.step((*rest3).CreateApp), "Creates an app in the targetted space using the v3 API"),
+		StepWithContext("rest3:create-package", rest3Context.step((*rest3).CreatePackage), "Creates a bits package for the app created by rest3:create-app"),
+		StepWithContext("rest3:upload", rest3Context.step((*rest3).UploadBits), "Uploads a simple Ruby application to the package created by rest3:create-package and waits until it is ready"),
+		StepWithContext("rest3:build", rest3Context.step((*rest3).CreateBuild), "Stages the uploaded package and waits for the droplet"),
+		StepWithContext("rest3:set-droplet", rest3Context.step((*rest3).SetDroplet), "Sets the staged droplet as the app's current droplet"),
+		StepWithContext("rest3:start", rest3Context.step((*rest3).Start), "Starts the app created by rest3:create-app"),
+		StepWithContext("rest3:wait", rest3Context.step((*rest3).WaitForProcesses), "Waits until an instance of the app's web process is running"),
+		StepWithContext("rest3:push", rest3Context.step((*rest3).Push), "Pushes a simple Ruby application using the v3 API (all of the steps above). This option requires both rest3:target and rest3:login to be included in the list of workloads"),
 		Step("gcf:push", Push, "Pushes a simple Ruby application using the CF command-line"),
 		Step("dummy", Dummy, "An empty workload that can be used when a CF environment is not available"),
 		Step("dummyWithErrors", DummyWithErrors, "An empty workload that generates errors. This can be used when a CF environment is not available"),
@@ -32,19 +71,48 @@ func DefaultWorkloadList() *WorkloadList {
 }
 
 func Step(name string, fn func() error, description string) WorkloadStep {
-	return WorkloadStep{name, func(ctx map[string]interface{}) error { return fn() }, description}
+	return WorkloadStep{name, func(ctx map[string]interface{}) error { return fn() }, description, RetryPolicy{}}
 }
 
 func StepWithContext(name string, fn func(map[string]interface{}) error, description string) WorkloadStep {
-	return WorkloadStep{name, fn, description}
+	return WorkloadStep{name, fn, description, RetryPolicy{}}
 }
 
-func (self *WorkloadList) DescribeWorkloads(to WorkloadAdder) {
-	for _, workload := range self.workloads {
+// Adds each step, with its retry policy, to the worker. Fails, before
+// adding any step, if a retry policy is invalid.
+func (self *WorkloadList) DescribeWorkloads(to WorkloadAdder) error {
+	steps := append([]WorkloadStep{}, self.workloads...)
+	steps = append(steps, httpContext.Steps()...)
+	steps = append(steps, execContext.Steps()...)
+	steps = append(steps, pluginContext.Steps()...)
+	for i := range steps {
+		steps[i].Retry = retryContext.policy(steps[i].Name)
+		if err := steps[i].Retry.Validate(); err != nil {
+			return fmt.Errorf("Invalid retry policy for %s: %s", steps[i].Name, err)
+		}
+	}
+
+	for _, workload := range steps {
 		to.AddWorkloadStep(workload)
 	}
+
+	return nil
+}
+
+// The fixtures to set up before, and tear down after, each experiment.
+func (self *WorkloadList) Fixtures() []Fixture {
+	fixtures := make([]Fixture, 0)
+	if restContext.fixture.enabled {
+		fixtures = append(fixtures, restContext)
+	}
+
+	return fixtures
 }
 
 func (self *WorkloadList) DescribeParameters(config config.Config) {
 	restContext.DescribeParameters(config)
+	httpContext.DescribeParameters(config)
+	execContext.DescribeParameters(config)
+	pluginContext.DescribeParameters(config)
+	retryContext.DescribeParameters(config)
 }
@@ -23,7 +23,7 @@ var _ = Describe("Workloads", func() {
 		workloadList := WorkloadList{testList}
 
 		worker := &dummyWorkloadReceiver{}
-		workloadList.DescribeWorkloads(worker)
+		Ω(workloadList.DescribeWorkloads(worker)).ShouldNot(HaveOccurred())
 
 		for i, w := range testList {
 			Ω(worker.Workloads[i].Name).Should(Equal(w.Name))